@@ -5,6 +5,8 @@ import (
 	"github.com/spf13/viper"
 	"os"
 	"roci/pkg/libcontainer"
+	"roci/pkg/libcontainer/network"
+	"roci/pkg/libcontainer/rootfs"
 	"roci/pkg/model"
 	"roci/pkg/util"
 )
@@ -32,7 +34,16 @@ func ContainerPreRunE(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
-	if confs, err = libcontainer.NewContainerFS(viper.GetString(containerDirFlag)); err != nil {
+	var networkConfig network.Config
+	if err = viper.UnmarshalKey(networkConfigKey, &networkConfig); err != nil {
+		return err
+	}
+	var mountPolicy rootfs.MountPolicy
+	if err = viper.UnmarshalKey(mountsConfigKey, &mountPolicy); err != nil {
+		return err
+	}
+
+	if confs, err = libcontainer.NewContainerFS(viper.GetString(containerDirFlag), networkConfig, mountPolicy); err != nil {
 		return err
 	}
 
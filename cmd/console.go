@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"roci/pkg/libcontainer/console"
+	"roci/pkg/logger"
+	"syscall"
+	"time"
+)
+
+// consoleReceiveTimeout is the maximum time to wait for the pty master after the process was created
+const consoleReceiveTimeout = 5 * time.Second
+
+// consoleProxy is used by foreground processes with terminal that have no console socket.
+// It receives the pty master on a temporary console socket and connects it to the stdio of roci.
+type consoleProxy struct {
+	dir      string
+	listener *net.UnixListener
+	received chan *os.File
+	errCh    chan error
+	master   *os.File
+	restore  func() error
+	copied   chan struct{}
+	log      *zap.Logger
+}
+
+// newConsoleProxy creates the temporary console socket and starts to wait for the pty master.
+func newConsoleProxy() (p *consoleProxy, err error) {
+	p = &consoleProxy{
+		received: make(chan *os.File, 1),
+		errCh:    make(chan error, 1),
+		copied:   make(chan struct{}),
+		restore:  func() error { return nil },
+		log:      logger.Log().Named("console"),
+	}
+
+	if p.dir, err = os.MkdirTemp("", "roci-console"); err != nil {
+		return nil, err
+	}
+	p.listener, err = net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(p.dir, "console.sock"), Net: "unix"})
+	if err != nil {
+		os.RemoveAll(p.dir)
+		return nil, err
+	}
+
+	go func() {
+		conn, err := p.listener.AcceptUnix()
+		if err != nil {
+			p.errCh <- err
+			return
+		}
+		defer conn.Close()
+
+		master, err := console.ReceiveMaster(conn)
+		if err != nil {
+			p.errCh <- err
+			return
+		}
+		p.received <- master
+	}()
+
+	return p, nil
+}
+
+// SocketPath returns the path of the temporary console socket.
+func (p *consoleProxy) SocketPath() string {
+	return p.listener.Addr().String()
+}
+
+// Attach waits for the pty master and copies stdin into it and its output to stdout.
+// If stdin is a terminal, it is put into raw mode and its size is applied to the pty.
+func (p *consoleProxy) Attach() (err error) {
+	select {
+	case p.master = <-p.received:
+	case err = <-p.errCh:
+		return fmt.Errorf("failed to receive console: %w", err)
+	case <-time.After(consoleReceiveTimeout):
+		return fmt.Errorf("timeout while waiting for console")
+	}
+
+	if console.IsTerminal(os.Stdin) {
+		p.resize()
+		if p.restore, err = console.MakeRaw(os.Stdin); err != nil {
+			return err
+		}
+		go p.handleResize()
+	}
+
+	go func() {
+		_, _ = io.Copy(p.master, os.Stdin)
+	}()
+	go func() {
+		defer close(p.copied)
+		// the read fails with EIO after the last slave fd is closed
+		_, _ = io.Copy(os.Stdout, p.master)
+	}()
+	return nil
+}
+
+// Wait waits until the remaining output of the pty is copied.
+func (p *consoleProxy) Wait() {
+	if p.master == nil {
+		return
+	}
+	select {
+	case <-p.copied:
+	case <-time.After(time.Second):
+		p.log.Debug("timeout while copying the remaining console output")
+	}
+}
+
+// Close restores the terminal and removes the temporary console socket.
+func (p *consoleProxy) Close() {
+	if err := p.restore(); err != nil {
+		p.log.Warn("failed to restore terminal", zap.Error(err))
+	}
+	if p.master != nil {
+		p.master.Close()
+	}
+	p.listener.Close()
+	os.RemoveAll(p.dir)
+}
+
+// handleResize applies the size of stdin to the pty whenever the terminal is resized.
+func (p *consoleProxy) handleResize() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-signals:
+			p.resize()
+		case <-p.copied:
+			return
+		}
+	}
+}
+
+// resize applies the size of stdin to the pty.
+func (p *consoleProxy) resize() {
+	size, err := console.Size(os.Stdin)
+	if err == nil {
+		err = console.SetSize(p.master, size)
+	}
+	if err != nil {
+		p.log.Debug("failed to resize console", zap.Error(err))
+	}
+}
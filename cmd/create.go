@@ -26,36 +26,17 @@ command(s) that get executed on start, edit the args parameter of the spec.
 	PreRunE: ContainerPreRunE,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var (
-			containerId  = args[0]
-			bundle       = MustGetString(cmd, "bundle")
-			pidFile      = MustGetString(cmd, "pid-file")
-			writePidFile = pidFile != ""
-			log          = logger.Log().Named("create")
+			containerId = args[0]
+			log         = logger.Log().Named("create")
 		)
-		log.Debug("create called", zap.String("containerId", containerId), zap.String("bundle", bundle))
 		defer log.Debug("create call handled")
 
-		bundleAbs, err := filepath.Abs(bundle)
+		opts, err := processOpts(cmd)
 		if err != nil {
 			return err
 		}
-
-		log.Debug("creating container")
-		c, err := libcontainer.CreateContainer(confs, containerId, bundleAbs)
-		if err != nil {
-			return err
-		}
-
-		if writePidFile {
-			pid := c.State().Pid
-			log.Debug("writing pid file", zap.Int("pid", pid))
-			err = writePid(pidFile, pid)
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
+		_, err = createContainer(cmd, containerId, opts)
+		return err
 	},
 }
 
@@ -64,6 +45,56 @@ func init() {
 
 	createCmd.Flags().StringP("bundle", "b", ".", `path to the root of the bundle directory, defaults to the current directory`)
 	createCmd.Flags().String("pid-file", "", `specify the file to write the process id to`)
+	createCmd.Flags().String("console-socket", "", `path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal`)
+	createCmd.Flags().Uint("preserve-fds", 0, `pass N additional file descriptors to the container (stdio + $LISTEN_FDS + N in total)`)
+	createCmd.Flags().Bool("no-pivot", false, `do not use pivot root to jail process inside rootfs. This should be used whenever the rootfs is on top of a ramdisk`)
+}
+
+// processOpts returns the process options set by the flags of the create and run command.
+func processOpts(cmd *cobra.Command) (opts libcontainer.ProcessOpts, err error) {
+	preserveFds, err := cmd.Flags().GetUint("preserve-fds")
+	if err != nil {
+		return opts, err
+	}
+	return libcontainer.ProcessOpts{
+		ConsoleSocket: MustGetString(cmd, "console-socket"),
+		PreserveFds:   int(preserveFds),
+		NoPivot:       MustGetBool(cmd, "no-pivot"),
+	}, nil
+}
+
+// createContainer creates the container with the bundle and pid-file flags of cmd.
+// It is shared by the create and run command.
+func createContainer(cmd *cobra.Command, containerId string, opts libcontainer.ProcessOpts) (c *libcontainer.Container, err error) {
+	var (
+		bundle       = MustGetString(cmd, "bundle")
+		pidFile      = MustGetString(cmd, "pid-file")
+		writePidFile = pidFile != ""
+		log          = logger.Log().Named(cmd.Name())
+	)
+	log.Debug("create called", zap.String("containerId", containerId), zap.String("bundle", bundle))
+
+	bundleAbs, err := filepath.Abs(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("creating container")
+	c, err = libcontainer.CreateContainer(confs, containerId, bundleAbs, opts)
+	if err != nil {
+		return c, err
+	}
+
+	if writePidFile {
+		pid := c.State().Pid
+		log.Debug("writing pid file", zap.Int("pid", pid))
+		err = writePid(pidFile, pid)
+		if err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
 }
 
 func writePid(pidFile string, pid int) error {
@@ -5,10 +5,19 @@ import (
 	"roci/pkg/logger"
 	"roci/pkg/model"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+const (
+	// forceDeleteAttempts is the number of attempts to kill and remove a container with --force
+	forceDeleteAttempts = 10
+
+	// forceDeleteDelay is the delay between the attempts
+	forceDeleteDelay = 100 * time.Millisecond
+)
+
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
 	Use:   "delete [command options] <container-id>",
@@ -28,20 +37,21 @@ status of "ubuntu01" as "stopped" the following will delete resources held for
 		)
 		log.Debug("delete called", zap.String("containerId", containerId), zap.Bool("force", forceFlag))
 
-		for {
+		for attempt := 1; ; attempt++ {
 			if forceFlag {
 				log.Debug("force delete called")
+				// a failed kill is reported by the remove, if the container is still running
 				err = confs.Kill(containerId, syscall.SIGKILL)
 				if err != nil && err != model.ErrNotRunning {
 					log.Debug("failed to kill process", zap.Error(err))
-					continue
 				}
 			}
 
 			log.Debug("remove container")
 			err = confs.Remove(containerId)
-			if forceFlag && err == model.ErrRunning {
+			if forceFlag && err == model.ErrRunning && attempt < forceDeleteAttempts {
 				log.Debug("failed to remove container", zap.Error(err))
+				time.Sleep(forceDeleteDelay)
 				continue
 			}
 			if err == model.ErrNotExist {
@@ -49,10 +59,6 @@ status of "ubuntu01" as "stopped" the following will delete resources held for
 				return nil
 			}
 
-			if forceFlag && err != nil {
-				continue
-			}
-
 			return err
 		}
 	},
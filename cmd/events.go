@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"roci/pkg/libcontainer/events"
+	"roci/pkg/logger"
+	"time"
+)
+
+// eventsCmd represents the events command
+var eventsCmd = &cobra.Command{
+	Use:   "events [command options] <container-id>",
+	Short: "display container events such as OOM notifications, state changes and resource stats",
+	Long: `The events command displays information about the container. By default the
+resource stats are displayed every 5 seconds together with OOM notifications and
+state changes. The events are printed as one json object per line in the format of runc.
+The command returns after the container stopped.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			containerId = args[0]
+			stats       = MustGetBool(cmd, "stats")
+			log         = logger.Log().Named("events")
+		)
+		interval, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return fmt.Errorf("duration interval must be greater than 0")
+		}
+		log.Debug("events called", zap.String("containerId", containerId), zap.Duration("interval", interval))
+
+		encoder := json.NewEncoder(os.Stdout)
+		if stats {
+			s, err := confs.Stats(containerId)
+			if err != nil {
+				return err
+			}
+			return encoder.Encode(events.Event{Type: events.TypeStats, ID: containerId, Data: s})
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		ch, err := confs.Events(containerId, interval, done)
+		if err != nil {
+			return err
+		}
+		for event := range ch {
+			if err = encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+
+	eventsCmd.Flags().Duration("interval", 5*time.Second, "set the stats collection interval")
+	eventsCmd.Flags().Bool("stats", false, "display the container's stats then exit")
+}
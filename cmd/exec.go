@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"roci/pkg/libcontainer"
+	"roci/pkg/logger"
+	"roci/pkg/model"
+	"roci/pkg/util"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec [command options] <container-id> [-- <command> [args...]]",
+	Short: "execute new process inside the container",
+	Long: `The exec command executes a new process inside the namespaces of a running container.
+The process is either defined by the command and its arguments or by a process.json
+file passed with --process. The exit status of the process is used as exit status of roci.`,
+	Example: `For example, if the container id is "ubuntu01" the following will output
+a list of processes running in the container:
+
+       # roci exec ubuntu01 -- ps`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var (
+			containerId = args[0]
+			processFile = MustGetString(cmd, "process")
+			pidFile     = MustGetString(cmd, "pid-file")
+			detach      = MustGetBool(cmd, "detach")
+			log         = logger.Log().Named("exec")
+		)
+		log.Debug("exec called", zap.String("containerId", containerId), zap.Strings("args", args))
+
+		var processArgs = args[1:]
+		if len(processArgs) > 0 && processArgs[0] == "--" {
+			processArgs = processArgs[1:]
+		}
+
+		var process *specs.Process
+		if processFile != "" {
+			process = new(specs.Process)
+			err = util.ReadJsonFile(processFile, process)
+		} else {
+			process, err = execProcessFromArgs(cmd, containerId, processArgs)
+		}
+		if err != nil {
+			return err
+		}
+
+		if cmd.Flags().Changed("tty") {
+			process.Terminal = MustGetBool(cmd, "tty")
+		}
+
+		opts := libcontainer.ProcessOpts{ConsoleSocket: MustGetString(cmd, "console-socket")}
+
+		// a foreground process with terminal is attached to the stdio of roci
+		var proxy *consoleProxy
+		if process.Terminal && !detach && opts.ConsoleSocket == "" {
+			if proxy, err = newConsoleProxy(); err != nil {
+				return err
+			}
+			defer proxy.Close()
+			opts.ConsoleSocket = proxy.SocketPath()
+		}
+
+		p, err := confs.Exec(containerId, process, opts)
+		if err != nil {
+			return err
+		}
+
+		if proxy != nil {
+			if err = proxy.Attach(); err != nil {
+				_ = syscall.Kill(p.Pid(), syscall.SIGKILL)
+				return err
+			}
+		}
+
+		if pidFile != "" {
+			log.Debug("writing pid file", zap.Int("pid", p.Pid()))
+			err = writePid(pidFile, p.Pid())
+			if err != nil {
+				return err
+			}
+		}
+
+		if detach {
+			return nil
+		}
+
+		stopForwarding := forwardSignals(p.Pid())
+		status, err := p.Wait()
+		stopForwarding()
+		if proxy != nil {
+			proxy.Wait()
+		}
+		if err != nil {
+			return err
+		}
+		log.Debug("process exited", zap.Int("status", status))
+		if status != 0 {
+			cmd.SilenceErrors = true
+			return &model.ProcessExitError{Status: status}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	// flags after the container id belong to the executed command
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().StringP("process", "p", "", `path to the process.json`)
+	execCmd.Flags().BoolP("detach", "d", false, `detach from the container's process`)
+	execCmd.Flags().String("pid-file", "", `specify the file to write the process id to`)
+	execCmd.Flags().String("cwd", "", `current working directory in the container`)
+	execCmd.Flags().StringArrayP("env", "e", nil, `set environment variables`)
+	execCmd.Flags().StringP("user", "u", "", `UID (format: <uid>[:<gid>])`)
+	execCmd.Flags().BoolP("tty", "t", false, `allocate a pseudo-TTY`)
+	execCmd.Flags().String("console-socket", "", `path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal`)
+}
+
+// execProcessFromArgs creates the process from the command line arguments.
+// Fields that are not set are inherited from the process of the container, except for the terminal.
+func execProcessFromArgs(cmd *cobra.Command, containerId string, args []string) (*specs.Process, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("exec requires a command or --process")
+	}
+
+	spec, err := confs.Spec(containerId)
+	if err != nil {
+		return nil, err
+	}
+
+	process := new(specs.Process)
+	if spec.Process != nil {
+		*process = *spec.Process
+	}
+	process.Args = args
+	// the process only gets a terminal with --tty, like with runc
+	process.Terminal = false
+
+	if cwd := MustGetString(cmd, "cwd"); cwd != "" {
+		process.Cwd = cwd
+	}
+
+	env, err := cmd.Flags().GetStringArray("env")
+	if err != nil {
+		return nil, err
+	}
+	process.Env = mergeEnv(process.Env, env)
+
+	if user := MustGetString(cmd, "user"); user != "" {
+		process.User, err = parseUser(user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return process, nil
+}
+
+// mergeEnv returns env with the variables of overrides added or replaced.
+func mergeEnv(env, overrides []string) []string {
+	merged := make([]string, 0, len(env)+len(overrides))
+	index := make(map[string]int, len(env)+len(overrides))
+	for _, e := range slices.Concat(env, overrides) {
+		key, _, _ := strings.Cut(e, "=")
+		if i, exists := index[key]; exists {
+			merged[i] = e
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// parseUser parses a user in the format <uid>[:<gid>]
+func parseUser(user string) (u specs.User, err error) {
+	uidStr, gidStr, hasGid := strings.Cut(user, ":")
+	uid, err := strconv.ParseUint(uidStr, 10, 32)
+	if err != nil {
+		return u, fmt.Errorf("invalid uid: %w", err)
+	}
+	u.UID = uint32(uid)
+
+	if hasGid {
+		gid, err := strconv.ParseUint(gidStr, 10, 32)
+		if err != nil {
+			return u, fmt.Errorf("invalid gid: %w", err)
+		}
+		u.GID = uint32(gid)
+	}
+	return u, nil
+}
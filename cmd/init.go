@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"roci/pkg/libcontainer"
+	"roci/pkg/libcontainer/initp"
 )
 
 const (
-	InitCommandName = "init"
+	InitCommandName     = "init"
+	ExecInitCommandName = "exec-init"
 )
 
 // ExecuteInit starts the init process with the data from the specified state directory.
@@ -13,3 +15,9 @@ const (
 func ExecuteInit(stateDir string) (err error) {
 	return libcontainer.InitFromStateDir(stateDir)
 }
+
+// ExecuteExecInit sets up and executes the process of an exec inside the container.
+// This is called by main.main().
+func ExecuteExecInit() (err error) {
+	return initp.ExecInit()
+}
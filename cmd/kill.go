@@ -39,7 +39,7 @@ signal to the init process of the "ubuntu01" container:
 }
 
 func getSignal(args []string, position int) string {
-	if position-1 >= len(args) {
+	if position < len(args) {
 		return args[position]
 	} else {
 		return defaultSignal
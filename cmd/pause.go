@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"roci/pkg/logger"
+)
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause <container-id>",
+	Short: "pause suspends all processes inside the container",
+	Long: `The pause command suspends all processes in the instance of the container
+by freezing its cgroup. Use roci list to identify instances of containers and
+their current status.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			containerId = args[0]
+			log         = logger.Log().Named("pause")
+		)
+		log.Debug("pause called", zap.String("containerId", containerId))
+
+		return confs.Pause(containerId)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+}
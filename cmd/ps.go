@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"os/user"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// psDefaultColumns are the columns of "ps -ef"
+var psDefaultColumns = []string{"uid", "pid", "ppid", "c", "stime", "tty", "time", "cmd"}
+
+// psProcess contains the information of a single process shown by ps
+type psProcess struct {
+	stat     procfs.ProcStat
+	status   procfs.ProcStatus
+	args     []string
+	bootTime time.Time
+}
+
+// psColumn defines a column that can be selected with "ps -o"
+type psColumn struct {
+	header string
+	value  func(p *psProcess) string
+}
+
+// psColumns maps the ps column names to their definition
+var psColumns = map[string]psColumn{
+	"pid":     {"PID", func(p *psProcess) string { return strconv.Itoa(p.stat.Pid) }},
+	"ppid":    {"PPID", func(p *psProcess) string { return strconv.Itoa(p.stat.PPid) }},
+	"pgid":    {"PGID", func(p *psProcess) string { return strconv.Itoa(p.stat.Pgrp) }},
+	"sid":     {"SID", func(p *psProcess) string { return strconv.Itoa(p.stat.Session) }},
+	"uid":     {"UID", func(p *psProcess) string { return userName(p.status.Uids[1]) }},
+	"euid":    {"EUID", func(p *psProcess) string { return strconv.FormatUint(uint64(p.status.Uids[1]), 10) }},
+	"user":    {"USER", func(p *psProcess) string { return userName(p.status.Uids[1]) }},
+	"gid":     {"GID", func(p *psProcess) string { return strconv.FormatUint(uint64(p.status.Gids[1]), 10) }},
+	"group":   {"GROUP", func(p *psProcess) string { return groupName(p.status.Gids[1]) }},
+	"comm":    {"COMMAND", func(p *psProcess) string { return p.stat.Comm }},
+	"cmd":     {"CMD", psArgs},
+	"args":    {"COMMAND", psArgs},
+	"command": {"COMMAND", psArgs},
+	"s":       {"S", func(p *psProcess) string { return p.stat.State }},
+	"stat":    {"STAT", func(p *psProcess) string { return p.stat.State }},
+	"state":   {"S", func(p *psProcess) string { return p.stat.State }},
+	"c":       {"C", func(p *psProcess) string { return strconv.Itoa(int(p.cpuPercent())) }},
+	"pcpu":    {"%CPU", func(p *psProcess) string { return strconv.FormatFloat(p.cpuPercent(), 'f', 1, 64) }},
+	"time":    {"TIME", func(p *psProcess) string { return formatCpuTime(p.cpuTime()) }},
+	"stime":   {"STIME", func(p *psProcess) string { return formatStartTime(p.startTime()) }},
+	"start":   {"STARTED", func(p *psProcess) string { return formatStartTime(p.startTime()) }},
+	"etime":   {"ELAPSED", func(p *psProcess) string { return formatElapsed(time.Since(p.startTime())) }},
+	"tty":     {"TTY", func(p *psProcess) string { return ttyName(p.stat.TtyNr) }},
+	"tt":      {"TT", func(p *psProcess) string { return ttyName(p.stat.TtyNr) }},
+	"rss":     {"RSS", func(p *psProcess) string { return strconv.FormatUint(p.status.VmRSS/1024, 10) }},
+	"vsz":     {"VSZ", func(p *psProcess) string { return strconv.FormatUint(p.stat.VSize/1024, 10) }},
+	"nlwp":    {"NLWP", func(p *psProcess) string { return strconv.Itoa(p.stat.NumThreads) }},
+}
+
+// psCmd represents the ps command
+var psCmd = &cobra.Command{
+	Use:   "ps [command options] <container-id> [ps options]",
+	Short: "ps displays the processes running inside a container",
+	Long: `The ps command displays the processes running inside a container.
+With --format json only the host process IDs are printed as json array.
+The table format supports the ps options -e, -f and -o <columns> and defaults to "-ef".`,
+	Example: `For example, to show the pid, user and command of the processes in the container "ubuntu01":
+
+       # roci ps ubuntu01 -o pid,user,args`,
+	Args:    cobra.MinimumNArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			containerId = args[0]
+			format      = MustGetString(cmd, "format")
+			log         = logger.Log().Named("ps")
+		)
+		log.Debug("ps called", zap.String("containerId", containerId), zap.Strings("args", args))
+
+		pids, err := confs.Pids(containerId)
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case "json":
+			if len(args) > 1 {
+				return fmt.Errorf("ps options are not supported with format json")
+			}
+			if pids == nil {
+				pids = []int{}
+			}
+			return json.NewEncoder(os.Stdout).Encode(pids)
+		case "table":
+			columns, err := parsePsArgs(args[1:])
+			if err != nil {
+				return err
+			}
+			return printPsTable(pids, columns)
+		default:
+			return fmt.Errorf("invalid format option")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(psCmd)
+
+	psCmd.Flags().SetInterspersed(false)
+	psCmd.Flags().StringP("format", "f", "table", "Possible values: table, json")
+}
+
+// parsePsArgs returns the columns selected by the ps options.
+func parsePsArgs(args []string) (columns []string, err error) {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return nil, fmt.Errorf("unsupported ps option: %v", arg)
+		}
+
+		// options can be grouped like "-ef" or "-eo pid,args"
+		for j, option := range arg[1:] {
+			switch option {
+			case 'e', 'A':
+				// all processes of the container are always shown
+			case 'f':
+				columns = append(columns, psDefaultColumns...)
+			case 'o':
+				list := arg[j+2:]
+				if list == "" {
+					if i++; i >= len(args) {
+						return nil, fmt.Errorf("ps option -o requires a column list")
+					}
+					list = args[i]
+				}
+				for _, column := range strings.Split(list, ",") {
+					if _, ok := psColumns[column]; !ok {
+						return nil, fmt.Errorf("unsupported ps column: %v", column)
+					}
+					columns = append(columns, column)
+				}
+			default:
+				return nil, fmt.Errorf("unsupported ps option: -%c", option)
+			}
+			if option == 'o' {
+				break
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		columns = psDefaultColumns
+	}
+	return columns, nil
+}
+
+// printPsTable prints the columns of every process with pid as table.
+func printPsTable(pids []int, columns []string) error {
+	bootTime, err := procfs.Root.BootTime()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 1, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = psColumns[column].header
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+
+	for _, pid := range pids {
+		p, err := loadPsProcess(procfs.Pid(pid), bootTime)
+		if errors.Is(err, os.ErrNotExist) {
+			// the process exited in the meantime
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = psColumns[column].value(p)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+
+	return w.Flush()
+}
+
+// loadPsProcess reads the information of the process with pid from the procfs.
+func loadPsProcess(pid procfs.Pid, bootTime time.Time) (p *psProcess, err error) {
+	p = &psProcess{bootTime: bootTime}
+	if p.stat, err = procfs.Root.Stat(pid); err != nil {
+		return nil, err
+	}
+	if p.status, err = procfs.Root.Status(pid); err != nil {
+		return nil, err
+	}
+	if p.args, err = procfs.Root.Cmdline(pid); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// cpuTime returns the time the process was scheduled in user and kernel mode.
+func (p *psProcess) cpuTime() time.Duration {
+	return time.Duration(p.stat.UTime+p.stat.STime) * time.Second / procfs.ClockTicks
+}
+
+// startTime returns the time the process was started.
+func (p *psProcess) startTime() time.Time {
+	return p.bootTime.Add(time.Duration(p.stat.StartTime) * time.Second / procfs.ClockTicks)
+}
+
+// cpuPercent returns the cpu utilization of the process over its lifetime.
+func (p *psProcess) cpuPercent() float64 {
+	elapsed := time.Since(p.startTime())
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.cpuTime()) * 100 / float64(elapsed)
+}
+
+// psArgs returns the command line of the process, kernel threads and zombies are shown as [comm].
+func psArgs(p *psProcess) string {
+	if len(p.args) == 0 {
+		return "[" + p.stat.Comm + "]"
+	}
+	return strings.Join(p.args, " ")
+}
+
+// userName returns the name of the user with uid or the uid if the user is unknown.
+func userName(uid uint32) string {
+	id := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return id
+}
+
+// groupName returns the name of the group with gid or the gid if the group is unknown.
+func groupName(gid uint32) string {
+	id := strconv.FormatUint(uint64(gid), 10)
+	if g, err := user.LookupGroupId(id); err == nil {
+		return g.Name
+	}
+	return id
+}
+
+// ttyName returns the name of the controlling terminal encoded in the tty_nr field of /proc/<pid>/stat.
+func ttyName(ttyNr int) string {
+	major := (ttyNr >> 8) & 0xfff
+	minor := (ttyNr & 0xff) | ((ttyNr >> 12) & 0xfff00)
+	switch {
+	case ttyNr == 0:
+		return "?"
+	case major >= 136 && major <= 143:
+		return fmt.Sprintf("pts/%d", (major-136)*256+minor)
+	case major == 4 && minor < 64:
+		return fmt.Sprintf("tty%d", minor)
+	case major == 4:
+		return fmt.Sprintf("ttyS%d", minor-64)
+	default:
+		return fmt.Sprintf("%d,%d", major, minor)
+	}
+}
+
+// formatCpuTime formats the cpu time like ps as [dd-]hh:mm:ss.
+func formatCpuTime(d time.Duration) string {
+	s := int64(d.Seconds())
+	days, hours, minutes, seconds := s/86400, s/3600%24, s/60%60, s%60
+	if days > 0 {
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+}
+
+// formatElapsed formats the elapsed time like ps as [[dd-]hh:]mm:ss.
+func formatElapsed(d time.Duration) string {
+	s := int64(d.Seconds())
+	days, hours, minutes, seconds := s/86400, s/3600%24, s/60%60, s%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, hours, minutes, seconds)
+	case hours > 0:
+		return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+	default:
+		return fmt.Sprintf("%02d:%02d", minutes, seconds)
+	}
+}
+
+// formatStartTime formats the start time like ps, processes started today are shown with time only.
+func formatStartTime(t time.Time) string {
+	now := time.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan02")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"roci/pkg/logger"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume <container-id>",
+	Short: "resumes all processes that have been previously paused",
+	Long: `The resume command resumes all processes in the instance of the container
+by thawing its cgroup. Use roci list to identify instances of containers and
+their current status.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			containerId = args[0]
+			log         = logger.Log().Named("resume")
+		)
+		log.Debug("resume called", zap.String("containerId", containerId))
+
+		return confs.Resume(containerId)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"roci/pkg/libcontainer/network"
 	"roci/pkg/model"
 
 	"github.com/spf13/cobra"
@@ -14,6 +15,29 @@ const (
 	configDirFlag    = "configDir"
 	containerDir     = "/run/roci/container"
 	containerDirFlag = "containerDir"
+
+	// networkConfigKey is the section of the config file with the network configuration, e.g.
+	//
+	//	network:
+	//	  mode: bridge
+	//	  bridge: roci0
+	//	  subnet: 10.77.0.0/16
+	//
+	// or with CNI plugins
+	//
+	//	network:
+	//	  mode: cni
+	//	  cni:
+	//	    pluginDirs: [/opt/cni/bin]
+	//	    confDir: /etc/cni/net.d
+	networkConfigKey = "network"
+
+	// mountsConfigKey is the section of the config file with the mount policy. Failed mounts of the spec
+	// fail the container, unless their destination is tolerated, e.g.
+	//
+	//	mounts:
+	//	  tolerate: [/mnt/cache]
+	mountsConfigKey = "mounts"
 )
 
 var cfgFile string
@@ -81,4 +105,9 @@ func initConfig() {
 func defaultConfig() {
 	viper.SetDefault(configDirFlag, configDir)
 	viper.SetDefault(containerDirFlag, containerDir)
+	viper.SetDefault(networkConfigKey+".mode", network.ModeNone)
+	viper.SetDefault(networkConfigKey+".bridge", "roci0")
+	viper.SetDefault(networkConfigKey+".subnet", "10.77.0.0/16")
+	viper.SetDefault(networkConfigKey+".cni.pluginDirs", []string{"/opt/cni/bin"})
+	viper.SetDefault(networkConfigKey+".cni.confDir", "/etc/cni/net.d")
 }
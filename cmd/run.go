@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"roci/pkg/logger"
+	"roci/pkg/model"
+	"roci/pkg/util"
+	"syscall"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run [command options] <container-id>",
+	Short: "create and run a container",
+	Long: `The run command creates an instance of a container for a bundle and starts it.
+Unless --detach is set, roci stays attached to the container process, forwards
+received signals to it and exits with the exit status of the container. The
+container is deleted after it exited.`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var (
+			containerId = args[0]
+			detach      = MustGetBool(cmd, "detach")
+			log         = logger.Log().Named("run")
+		)
+		defer log.Debug("run call handled")
+
+		opts, err := processOpts(cmd)
+		if err != nil {
+			return err
+		}
+
+		// a foreground container with terminal is attached to the stdio of roci
+		var proxy *consoleProxy
+		if !detach && opts.ConsoleSocket == "" {
+			terminal, err := bundleTerminal(MustGetString(cmd, "bundle"))
+			if err != nil {
+				return err
+			}
+			if terminal {
+				if proxy, err = newConsoleProxy(); err != nil {
+					return err
+				}
+				defer proxy.Close()
+				opts.ConsoleSocket = proxy.SocketPath()
+			}
+		}
+
+		c, err := createContainer(cmd, containerId, opts)
+		if err != nil {
+			if c != nil {
+				destroyContainer(containerId)
+			}
+			return err
+		}
+
+		if proxy != nil {
+			if err = proxy.Attach(); err != nil {
+				destroyContainer(containerId)
+				return err
+			}
+		}
+
+		var stopForwarding = func() {}
+		if !detach {
+			stopForwarding = forwardSignals(c.State().Pid)
+		}
+
+		log.Debug("starting container", zap.String("containerId", containerId))
+		err = confs.Start(containerId)
+		if err != nil {
+			stopForwarding()
+			destroyContainer(containerId)
+			return err
+		}
+
+		if detach {
+			return nil
+		}
+
+		status, err := c.Wait()
+		stopForwarding()
+		if proxy != nil {
+			proxy.Wait()
+		}
+		log.Debug("container exited", zap.Int("status", status), zap.Error(err))
+		if err != nil {
+			destroyContainer(containerId)
+			return err
+		}
+
+		err = confs.Remove(containerId)
+		if err != nil {
+			return err
+		}
+
+		if status != 0 {
+			cmd.SilenceErrors = true
+			return &model.ProcessExitError{Status: status}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().StringP("bundle", "b", ".", `path to the root of the bundle directory, defaults to the current directory`)
+	runCmd.Flags().String("pid-file", "", `specify the file to write the process id to`)
+	runCmd.Flags().BoolP("detach", "d", false, `detach from the container's process`)
+	runCmd.Flags().String("console-socket", "", `path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal`)
+	runCmd.Flags().Uint("preserve-fds", 0, `pass N additional file descriptors to the container (stdio + $LISTEN_FDS + N in total)`)
+	runCmd.Flags().Bool("no-pivot", false, `do not use pivot root to jail process inside rootfs. This should be used whenever the rootfs is on top of a ramdisk`)
+}
+
+// bundleTerminal checks whether the process of the bundle spec has a terminal.
+func bundleTerminal(bundle string) (bool, error) {
+	var spec specs.Spec
+	if err := util.ReadJsonFile(filepath.Join(bundle, model.OciSpecFileName), &spec); err != nil {
+		return false, err
+	}
+	return spec.Process != nil && spec.Process.Terminal, nil
+}
+
+// forwardSignals forwards the signals received by the runtime to the process with pid until stop is called.
+func forwardSignals(pid int) (stop func()) {
+	var (
+		log     = logger.Log().Named("signal")
+		signals = make(chan os.Signal, 32)
+		done    = make(chan struct{})
+	)
+	signal.Notify(signals)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case s := <-signals:
+				sig := s.(syscall.Signal)
+				switch sig {
+				case syscall.SIGCHLD, syscall.SIGURG, syscall.SIGPIPE:
+					// these are caused by the runtime itself
+					continue
+				}
+
+				log.Debug("forwarding signal", zap.Stringer("signal", sig), zap.Int("pid", pid))
+				if err := syscall.Kill(pid, sig); err != nil {
+					log.Warn("failed to forward signal", zap.Stringer("signal", sig), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// destroyContainer kills the container and removes it afterward. Errors are only logged,
+// because it is used to clean up after another error occurred.
+func destroyContainer(containerId string) {
+	log := logger.Log().With(zap.String("containerId", containerId))
+	if err := confs.Kill(containerId, syscall.SIGKILL); err != nil && err != model.ErrNotRunning {
+		log.Warn("failed to kill container", zap.Error(err))
+	}
+	if err := confs.Remove(containerId); err != nil {
+		log.Warn("failed to remove container", zap.Error(err))
+	}
+}
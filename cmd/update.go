@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"roci/pkg/logger"
+	"strconv"
+	"strings"
+)
+
+// updateCmd represents the update command
+var updateCmd = &cobra.Command{
+	Use:   "update [command options] <container-id>",
+	Short: "update container resource constraints",
+	Long: `The update command changes the resource constraints of a running container.
+The resources are either read from a json file in the format of the linux.resources
+section of the OCI runtime specification (use "-" to read from stdin) or set by the
+command options. Command options take precedence over the resources file.`,
+	Example: `For example, to limit the memory of the container "ubuntu01" to 512 MiB:
+
+       # roci update --memory 512m ubuntu01`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: ContainerPreRunE,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var (
+			containerId   = args[0]
+			resourcesFile = MustGetString(cmd, "resources")
+			log           = logger.Log().Named("update")
+		)
+		log.Debug("update called", zap.String("containerId", containerId), zap.String("resources", resourcesFile))
+
+		resources := new(specs.LinuxResources)
+		if resourcesFile != "" {
+			resources, err = readResources(resourcesFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err = resourcesFromFlags(cmd, resources); err != nil {
+			return err
+		}
+
+		return confs.Update(containerId, resources)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringP("resources", "r", "", `path to a file containing the resources to update or "-" to read from stdin`)
+	updateCmd.Flags().String("memory", "", `memory limit (in bytes or with unit suffix k, m, g)`)
+	updateCmd.Flags().String("memory-reservation", "", `memory reservation or soft limit (in bytes or with unit suffix k, m, g)`)
+	updateCmd.Flags().String("memory-swap", "", `total memory usage (memory + swap); set "-1" to enable unlimited swap`)
+	updateCmd.Flags().Uint64("cpu-period", 0, `CPU CFS period to be used for hardcapping (in usecs)`)
+	updateCmd.Flags().Int64("cpu-quota", 0, `CPU CFS hardcap limit (in usecs), allowed cpu time in a given period`)
+	updateCmd.Flags().Uint64("cpu-share", 0, `CPU shares (relative weight vs. other containers)`)
+	updateCmd.Flags().String("cpuset-cpus", "", `CPU(s) to use`)
+	updateCmd.Flags().String("cpuset-mems", "", `memory node(s) to use`)
+	updateCmd.Flags().Int64("pids-limit", 0, `maximum number of pids allowed in the container`)
+	updateCmd.Flags().Uint16("blkio-weight", 0, `specifies per cgroup weight, range is from 10 to 1000`)
+}
+
+// readResources reads the resources from path, "-" reads from stdin.
+func readResources(path string) (*specs.LinuxResources, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	resources := new(specs.LinuxResources)
+	if err := json.NewDecoder(r).Decode(resources); err != nil {
+		return nil, fmt.Errorf("failed to decode resources: %w", err)
+	}
+	return resources, nil
+}
+
+// resourcesFromFlags sets every resource of the changed command options.
+func resourcesFromFlags(cmd *cobra.Command, resources *specs.LinuxResources) (err error) {
+	flags := cmd.Flags()
+	memory := func() *specs.LinuxMemory {
+		if resources.Memory == nil {
+			resources.Memory = new(specs.LinuxMemory)
+		}
+		return resources.Memory
+	}
+	cpu := func() *specs.LinuxCPU {
+		if resources.CPU == nil {
+			resources.CPU = new(specs.LinuxCPU)
+		}
+		return resources.CPU
+	}
+
+	sizes := []struct {
+		flag  string
+		value func() **int64
+	}{
+		{"memory", func() **int64 { return &memory().Limit }},
+		{"memory-reservation", func() **int64 { return &memory().Reservation }},
+		{"memory-swap", func() **int64 { return &memory().Swap }},
+	}
+	for _, size := range sizes {
+		if !flags.Changed(size.flag) {
+			continue
+		}
+		v, err := parseSize(MustGetString(cmd, size.flag))
+		if err != nil {
+			return fmt.Errorf("invalid value for --%v: %w", size.flag, err)
+		}
+		*size.value() = &v
+	}
+
+	if flags.Changed("cpu-period") {
+		v, _ := flags.GetUint64("cpu-period")
+		cpu().Period = &v
+	}
+	if flags.Changed("cpu-quota") {
+		v, _ := flags.GetInt64("cpu-quota")
+		cpu().Quota = &v
+	}
+	if flags.Changed("cpu-share") {
+		v, _ := flags.GetUint64("cpu-share")
+		cpu().Shares = &v
+	}
+	if flags.Changed("cpuset-cpus") {
+		cpu().Cpus = MustGetString(cmd, "cpuset-cpus")
+	}
+	if flags.Changed("cpuset-mems") {
+		cpu().Mems = MustGetString(cmd, "cpuset-mems")
+	}
+	if flags.Changed("pids-limit") {
+		v, _ := flags.GetInt64("pids-limit")
+		resources.Pids = &specs.LinuxPids{Limit: v}
+	}
+	if flags.Changed("blkio-weight") {
+		v, _ := flags.GetUint16("blkio-weight")
+		if resources.BlockIO == nil {
+			resources.BlockIO = new(specs.LinuxBlockIO)
+		}
+		resources.BlockIO.Weight = &v
+	}
+
+	return nil
+}
+
+// parseSize parses a size in bytes with an optional unit suffix (k, m, g, t).
+// Negative values are returned as is and mean unlimited.
+func parseSize(size string) (int64, error) {
+	var (
+		s    = strings.ToLower(strings.TrimSpace(size))
+		unit = int64(1)
+	)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "b"), "i")
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'k':
+			unit = 1 << 10
+		case 'm':
+			unit = 1 << 20
+		case 'g':
+			unit = 1 << 30
+		case 't':
+			unit = 1 << 40
+		}
+		if unit != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return v, nil
+	}
+	return v * unit, nil
+}
@@ -9,8 +9,18 @@ import (
 	"path/filepath"
 	"roci/cmd"
 	"roci/pkg/logger"
+	"runtime"
 )
 
+func init() {
+	// Calling LockOSThread in an init function keeps main on the main thread. The container init needs it,
+	// because some state of /proc/self, like the offsets of a new time namespace, belongs to the main thread.
+	// The exec helper as well, because it changes the capabilities of the thread that executes the process.
+	if isInitProcess() || isExecInitProcess() {
+		runtime.LockOSThread()
+	}
+}
+
 func main() {
 	//s := strings.Join(os.Args, " ")
 	//fmt.Println(s)
@@ -19,6 +29,8 @@ func main() {
 
 	if isInitProcess() {
 		executeInit()
+	} else if isExecInitProcess() {
+		executeExecInit()
 	} else {
 		executeCLI()
 	}
@@ -39,6 +51,21 @@ func executeInit() {
 	}
 }
 
+func isExecInitProcess() bool {
+	return len(os.Args) == 3 && os.Args[1] == cmd.ExecInitCommandName
+}
+
+func executeExecInit() {
+	logger.Set(logger.Log().Named("exec-init").With(zap.String("cid", os.Args[2])))
+	log := logger.Log()
+
+	log.Debug("running exec init")
+	err := cmd.ExecuteExecInit()
+	if err != nil {
+		log.Fatal("failed to run exec init", zap.Error(err))
+	}
+}
+
 func executeCLI() {
 	logger.Set(logger.Log().Named("main"))
 	log := logger.Log()
@@ -0,0 +1,149 @@
+package libcontainer
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"roci/pkg/libcontainer/cgroups"
+	"roci/pkg/logger"
+)
+
+// cgroupManager returns the cgroup manager of the container with the given ID.
+// It returns nil if the host has no cgroup v2 hierarchy.
+func cgroupManager(id string, spec *specs.Spec) (*cgroups.Manager, error) {
+	var cgroupsPath string
+	if spec.Linux != nil {
+		cgroupsPath = spec.Linux.CgroupsPath
+	}
+
+	m, err := cgroups.NewManager(cgroupsPath, id)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsSupported() {
+		logger.Log().Warn("cgroup v2 is not available, resources are not enforced")
+		return nil, nil
+	}
+	return m, nil
+}
+
+// createCgroup creates the cgroup of the container and applies the resources of the spec.
+// The resolved cgroup path is stored in the spec, so the cgroup can be found by later runtime calls.
+func createCgroup(id string, spec *specs.Spec) (*cgroups.Manager, error) {
+	m, err := cgroupManager(id, spec)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	if err = m.Create(); err != nil {
+		return nil, err
+	}
+
+	if spec.Linux == nil {
+		spec.Linux = new(specs.Linux)
+	}
+	spec.Linux.CgroupsPath = m.CgroupsPath()
+
+	if err = m.Set(spec.Linux.Resources); err != nil {
+		_ = m.Destroy()
+		return nil, err
+	}
+	return m, nil
+}
+
+// mergeResources returns the resources of base with every value that is set in update replaced.
+func mergeResources(base, update *specs.LinuxResources) *specs.LinuxResources {
+	merged := new(specs.LinuxResources)
+	if base != nil {
+		*merged = *base
+	}
+	if update == nil {
+		return merged
+	}
+
+	if update.Memory != nil {
+		memory := new(specs.LinuxMemory)
+		if merged.Memory != nil {
+			*memory = *merged.Memory
+		}
+		memory.Limit = mergeValue(memory.Limit, update.Memory.Limit)
+		memory.Reservation = mergeValue(memory.Reservation, update.Memory.Reservation)
+		memory.Swap = mergeValue(memory.Swap, update.Memory.Swap)
+		memory.Kernel = mergeValue(memory.Kernel, update.Memory.Kernel)
+		memory.KernelTCP = mergeValue(memory.KernelTCP, update.Memory.KernelTCP)
+		memory.Swappiness = mergeValue(memory.Swappiness, update.Memory.Swappiness)
+		memory.DisableOOMKiller = mergeValue(memory.DisableOOMKiller, update.Memory.DisableOOMKiller)
+		memory.UseHierarchy = mergeValue(memory.UseHierarchy, update.Memory.UseHierarchy)
+		merged.Memory = memory
+	}
+
+	if update.CPU != nil {
+		cpu := new(specs.LinuxCPU)
+		if merged.CPU != nil {
+			*cpu = *merged.CPU
+		}
+		cpu.Shares = mergeValue(cpu.Shares, update.CPU.Shares)
+		cpu.Quota = mergeValue(cpu.Quota, update.CPU.Quota)
+		cpu.Burst = mergeValue(cpu.Burst, update.CPU.Burst)
+		cpu.Period = mergeValue(cpu.Period, update.CPU.Period)
+		cpu.RealtimeRuntime = mergeValue(cpu.RealtimeRuntime, update.CPU.RealtimeRuntime)
+		cpu.RealtimePeriod = mergeValue(cpu.RealtimePeriod, update.CPU.RealtimePeriod)
+		cpu.Idle = mergeValue(cpu.Idle, update.CPU.Idle)
+		if update.CPU.Cpus != "" {
+			cpu.Cpus = update.CPU.Cpus
+		}
+		if update.CPU.Mems != "" {
+			cpu.Mems = update.CPU.Mems
+		}
+		merged.CPU = cpu
+	}
+
+	if update.Pids != nil {
+		pids := *update.Pids
+		merged.Pids = &pids
+	}
+
+	if update.BlockIO != nil {
+		blockIO := new(specs.LinuxBlockIO)
+		if merged.BlockIO != nil {
+			*blockIO = *merged.BlockIO
+		}
+		blockIO.Weight = mergeValue(blockIO.Weight, update.BlockIO.Weight)
+		blockIO.LeafWeight = mergeValue(blockIO.LeafWeight, update.BlockIO.LeafWeight)
+		blockIO.WeightDevice = mergeSlice(blockIO.WeightDevice, update.BlockIO.WeightDevice)
+		blockIO.ThrottleReadBpsDevice = mergeSlice(blockIO.ThrottleReadBpsDevice, update.BlockIO.ThrottleReadBpsDevice)
+		blockIO.ThrottleWriteBpsDevice = mergeSlice(blockIO.ThrottleWriteBpsDevice, update.BlockIO.ThrottleWriteBpsDevice)
+		blockIO.ThrottleReadIOPSDevice = mergeSlice(blockIO.ThrottleReadIOPSDevice, update.BlockIO.ThrottleReadIOPSDevice)
+		blockIO.ThrottleWriteIOPSDevice = mergeSlice(blockIO.ThrottleWriteIOPSDevice, update.BlockIO.ThrottleWriteIOPSDevice)
+		merged.BlockIO = blockIO
+	}
+
+	merged.HugepageLimits = mergeSlice(merged.HugepageLimits, update.HugepageLimits)
+
+	if len(update.Unified) > 0 {
+		unified := make(map[string]string, len(merged.Unified)+len(update.Unified))
+		for name, value := range merged.Unified {
+			unified[name] = value
+		}
+		for name, value := range update.Unified {
+			unified[name] = value
+		}
+		merged.Unified = unified
+	}
+
+	return merged
+}
+
+// mergeValue returns update if it is set, otherwise base
+func mergeValue[T any](base, update *T) *T {
+	if update != nil {
+		return update
+	}
+	return base
+}
+
+// mergeSlice returns update if it is set, otherwise base
+func mergeSlice[T any](base, update []T) []T {
+	if update != nil {
+		return update
+	}
+	return base
+}
@@ -0,0 +1,60 @@
+package libcontainer
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestMergeResources(t *testing.T) {
+	var (
+		limit       int64  = 1 << 30
+		newLimit    int64  = 2 << 30
+		reservation int64  = 1 << 29
+		shares      uint64 = 1024
+		quota       int64  = 50000
+	)
+	base := &specs.LinuxResources{
+		Memory:  &specs.LinuxMemory{Limit: &limit, Reservation: &reservation},
+		CPU:     &specs.LinuxCPU{Shares: &shares, Cpus: "0-3"},
+		Pids:    &specs.LinuxPids{Limit: 100},
+		Unified: map[string]string{"memory.high": "1"},
+	}
+	update := &specs.LinuxResources{
+		Memory:  &specs.LinuxMemory{Limit: &newLimit},
+		CPU:     &specs.LinuxCPU{Quota: &quota},
+		Unified: map[string]string{"io.weight": "default 100"},
+	}
+
+	merged := mergeResources(base, update)
+
+	if *merged.Memory.Limit != newLimit {
+		t.Errorf("memory limit, expected: %d, actual: %d", newLimit, *merged.Memory.Limit)
+	}
+	if *merged.Memory.Reservation != reservation {
+		t.Errorf("memory reservation, expected: %d, actual: %d", reservation, *merged.Memory.Reservation)
+	}
+	if *merged.CPU.Shares != shares || *merged.CPU.Quota != quota || merged.CPU.Cpus != "0-3" {
+		t.Errorf("unexpected cpu resources: %+v", merged.CPU)
+	}
+	if merged.Pids.Limit != 100 {
+		t.Errorf("pids limit, expected: %d, actual: %d", 100, merged.Pids.Limit)
+	}
+	if len(merged.Unified) != 2 {
+		t.Errorf("unexpected unified resources: %v", merged.Unified)
+	}
+
+	// the base resources must not be modified
+	if *base.Memory.Limit != limit || base.CPU.Quota != nil || len(base.Unified) != 1 {
+		t.Error("base resources were modified")
+	}
+}
+
+func TestMergeResources_NilBase(t *testing.T) {
+	update := &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: 10}}
+
+	merged := mergeResources(nil, update)
+	if merged.Pids == nil || merged.Pids.Limit != 10 {
+		t.Errorf("unexpected pids resources: %+v", merged.Pids)
+	}
+}
@@ -0,0 +1,274 @@
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMountpoint is the default mountpoint of the cgroup v2 hierarchy of the host
+	DefaultMountpoint = "/sys/fs/cgroup"
+
+	// defaultParent is the parent cgroup of containers without a cgroupsPath
+	defaultParent = "/roci"
+
+	controllersFileName    = "cgroup.controllers"
+	subtreeControlFileName = "cgroup.subtree_control"
+	procsFileName          = "cgroup.procs"
+	killFileName           = "cgroup.kill"
+	freezeFileName         = "cgroup.freeze"
+	eventsFileName         = "cgroup.events"
+
+	// freezeTimeout is the maximum time to wait for the cgroup to be frozen or thawed
+	freezeTimeout = 2 * time.Second
+
+	cgroupFilePermissions = 0644
+	cgroupDirPermissions  = 0755
+)
+
+var log = logger.Log().Named("cgroup")
+
+// Manager manages the cgroup v2 of a single container.
+type Manager struct {
+	// mountpoint is the path to the root of the cgroup v2 hierarchy
+	mountpoint string
+
+	// path is the path of the container cgroup relative to mountpoint
+	path string
+}
+
+// NewManager creates a Manager for the cgroup defined by the cgroupsPath of the spec.
+// An absolute cgroupsPath is relative to the cgroup v2 mountpoint, a relative one is relative
+// to the cgroup of the runtime. Without cgroupsPath the cgroup is created below /roci.
+// The systemd notation "slice:prefix:name" is converted to the path systemd would use.
+func NewManager(cgroupsPath, containerId string) (*Manager, error) {
+	path, err := resolvePath(procfs.Root, cgroupsPath, containerId)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{mountpoint: DefaultMountpoint, path: path}, nil
+}
+
+// IsSupported checks whether a cgroup v2 hierarchy is mounted.
+// It returns `false` on hosts with cgroup v1 only.
+func (m *Manager) IsSupported() bool {
+	_, err := os.Stat(filepath.Join(m.mountpoint, controllersFileName))
+	return err == nil
+}
+
+// CgroupsPath returns the path of the container cgroup relative to the cgroup v2 mountpoint
+func (m *Manager) CgroupsPath() string {
+	return m.path
+}
+
+// Path returns the absolute path of the container cgroup
+func (m *Manager) Path() string {
+	return filepath.Join(m.mountpoint, m.path)
+}
+
+// Create creates the container cgroup and enables the available controllers for it.
+func (m *Manager) Create() (err error) {
+	if err = os.MkdirAll(m.Path(), cgroupDirPermissions); err != nil {
+		return err
+	}
+
+	// controllers have to be enabled in every ancestor to be available in the container cgroup
+	current := m.mountpoint
+	for _, part := range strings.Split(strings.Trim(m.path, "/"), "/") {
+		if err = enableControllers(current); err != nil {
+			log.Warn("failed to enable controllers", zap.String("cgroup", current), zap.Error(err))
+		}
+		current = filepath.Join(current, part)
+	}
+
+	return nil
+}
+
+// Apply moves the process with pid into the container cgroup.
+// Pid 0 moves the calling process.
+func (m *Manager) Apply(pid int) error {
+	return writeFile(m.Path(), procsFileName, strconv.Itoa(pid))
+}
+
+// Pids returns the process IDs of all processes in the container cgroup.
+func (m *Manager) Pids() (pids []int, err error) {
+	content, err := os.ReadFile(filepath.Join(m.Path(), procsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// Freeze stops all processes in the container cgroup and waits until the cgroup is frozen.
+func (m *Manager) Freeze() error {
+	return m.setFrozen(true)
+}
+
+// Thaw resumes all processes in the container cgroup and waits until the cgroup is thawed.
+func (m *Manager) Thaw() error {
+	return m.setFrozen(false)
+}
+
+// Frozen checks whether the container cgroup is frozen.
+func (m *Manager) Frozen() (bool, error) {
+	content, err := os.ReadFile(filepath.Join(m.Path(), freezeFileName))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(content)) == "1", nil
+}
+
+// setFrozen writes the freezer state and waits until cgroup.events reports it.
+func (m *Manager) setFrozen(frozen bool) (err error) {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+	if err = writeFile(m.Path(), freezeFileName, value); err != nil {
+		return err
+	}
+
+	// freezing is asynchronous, cgroup.events contains "frozen 1" once all processes are stopped
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		content, err := os.ReadFile(filepath.Join(m.Path(), eventsFileName))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			if line == "frozen "+value {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout while waiting for cgroup freeze state %v", value)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Destroy removes the container cgroup. Remaining processes are killed.
+func (m *Manager) Destroy() (err error) {
+	path := m.Path()
+	for i := 0; i < 10; i++ {
+		err = syscall.Rmdir(path)
+		switch {
+		case err == nil, errors.Is(err, syscall.ENOENT):
+			return nil
+		case errors.Is(err, syscall.EBUSY):
+			// the cgroup still contains processes
+			_ = writeFile(path, killFileName, "1")
+			time.Sleep(10 * time.Millisecond)
+		default:
+			return err
+		}
+	}
+	return err
+}
+
+// enableControllers enables all controllers of the cgroup for its children.
+func enableControllers(cgroup string) error {
+	content, err := os.ReadFile(filepath.Join(cgroup, controllersFileName))
+	if err != nil {
+		return err
+	}
+
+	controllers := strings.Fields(string(content))
+	if len(controllers) == 0 {
+		return nil
+	}
+	for i, controller := range controllers {
+		controllers[i] = "+" + controller
+	}
+
+	return writeFile(cgroup, subtreeControlFileName, strings.Join(controllers, " "))
+}
+
+// resolvePath converts the cgroupsPath into a path relative to the cgroup v2 mountpoint.
+func resolvePath(proc *procfs.FS, cgroupsPath, containerId string) (string, error) {
+	switch {
+	case cgroupsPath == "":
+		return filepath.Join(defaultParent, containerId), nil
+	case filepath.IsAbs(cgroupsPath):
+		return filepath.Clean(cgroupsPath), nil
+	case strings.Count(cgroupsPath, ":") == 2:
+		return systemdPath(cgroupsPath, containerId)
+	default:
+		own, err := proc.Cgroup(procfs.PidSelf)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(own, cgroupsPath), nil
+	}
+}
+
+// systemdPath converts the systemd notation "slice:prefix:name" into a cgroup path.
+// For example "machine.slice:libpod:abc" is converted into "/machine.slice/libpod-abc.scope".
+func systemdPath(cgroupsPath, containerId string) (string, error) {
+	parts := strings.Split(cgroupsPath, ":")
+	slice, prefix, name := parts[0], parts[1], parts[2]
+	if slice == "" {
+		slice = "system.slice"
+	}
+	if name == "" {
+		name = containerId
+	}
+	if !strings.HasSuffix(slice, ".slice") {
+		return "", fmt.Errorf("invalid slice name: %v", slice)
+	}
+
+	// a slice "a-b.slice" is nested inside "a.slice"
+	var path string
+	sliceName := strings.TrimSuffix(slice, ".slice")
+	for i, part := range strings.Split(sliceName, "-") {
+		if part == "" {
+			return "", fmt.Errorf("invalid slice name: %v", slice)
+		}
+		if i == 0 {
+			path = filepath.Join(path, part+".slice")
+			continue
+		}
+		path = filepath.Join(path, strings.TrimSuffix(filepath.Base(path), ".slice")+"-"+part+".slice")
+	}
+
+	unit := name
+	if !strings.HasSuffix(unit, ".scope") && !strings.HasSuffix(unit, ".slice") {
+		if prefix != "" {
+			unit = prefix + "-" + unit
+		}
+		unit += ".scope"
+	}
+
+	return filepath.Join("/", path, unit), nil
+}
+
+// writeFile writes value into the file name inside of the cgroup directory
+func writeFile(cgroup, name, value string) error {
+	err := os.WriteFile(filepath.Join(cgroup, name), []byte(value), cgroupFilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to write %q to %v: %w", value, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package cgroups
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const testCgroupsPath = "/roci/test"
+
+func newTestManager(t *testing.T) (m *Manager) {
+	testFSPath := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(testFSPath, controllersFileName), []byte("cpu memory pids\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Manager{mountpoint: testFSPath, path: testCgroupsPath}
+}
+
+func readTestFile(t *testing.T, m *Manager, name string) string {
+	content, err := os.ReadFile(filepath.Join(m.Path(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestManager_Create(t *testing.T) {
+	m := newTestManager(t)
+
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(m.Path()); err != nil {
+		t.Error(err)
+	}
+
+	subtreeControl, err := os.ReadFile(filepath.Join(m.mountpoint, subtreeControlFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(subtreeControl) != "+cpu +memory +pids" {
+		t.Errorf("unexpected subtree control: %q", subtreeControl)
+	}
+}
+
+func TestManager_Apply(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Apply(42); err != nil {
+		t.Fatal(err)
+	}
+
+	pids, err := m.Pids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pids) != 1 || pids[0] != 42 {
+		t.Errorf("expected [42], actual: %v", pids)
+	}
+}
+
+func TestManager_Set(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		memoryLimit int64  = 1 << 30
+		memorySwap  int64  = 2 << 30
+		shares      uint64 = 1024
+		quota       int64  = 50000
+		period      uint64 = 100000
+	)
+	resources := &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &memoryLimit, Swap: &memorySwap},
+		CPU:    &specs.LinuxCPU{Shares: &shares, Quota: &quota, Period: &period, Cpus: "0-1"},
+		Pids:   &specs.LinuxPids{Limit: 100},
+		HugepageLimits: []specs.LinuxHugepageLimit{
+			{Pagesize: "2MB", Limit: 4 << 20},
+		},
+		Unified: map[string]string{"memory.high": "900000000"},
+	}
+
+	if err := m.Set(resources); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"memory.max":      "1073741824",
+		"memory.swap.max": "1073741824",
+		"cpu.weight":      "39",
+		"cpu.max":         "50000 100000",
+		"cpuset.cpus":     "0-1",
+		"pids.max":        "100",
+		"hugetlb.2MB.max": "4194304",
+		"memory.high":     "900000000",
+	}
+	for name, value := range expected {
+		if actual := readTestFile(t, m, name); actual != value {
+			t.Errorf("%v: expected: %q, actual: %q", name, value, actual)
+		}
+	}
+}
+
+func TestManager_Set_InvalidSwap(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		memoryLimit int64 = 2 << 30
+		memorySwap  int64 = 1 << 30
+	)
+	err := m.Set(&specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &memoryLimit, Swap: &memorySwap}})
+	if err == nil {
+		t.Error("expected error for swap limit lower than memory limit")
+	}
+}
+
+func TestManager_Destroy(t *testing.T) {
+	m := newTestManager(t)
+	if err := os.MkdirAll(m.Path(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(m.Path()); !os.IsNotExist(err) {
+		t.Errorf("expected cgroup to be removed, got: %v", err)
+	}
+
+	// destroying a cgroup that doesn't exist is not an error
+	if err := m.Destroy(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSystemdPath(t *testing.T) {
+	tests := []struct {
+		cgroupsPath string
+		expected    string
+		valid       bool
+	}{
+		{"machine.slice:libpod:abc", "/machine.slice/libpod-abc.scope", true},
+		{"user-1000.slice:libpod:abc", "/user.slice/user-1000.slice/libpod-abc.scope", true},
+		{":roci:abc", "/system.slice/roci-abc.scope", true},
+		{"machine.slice::abc.scope", "/machine.slice/abc.scope", true},
+		{"machine:libpod:abc", "", false},
+		{"a--b.slice:libpod:abc", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cgroupsPath, func(t *testing.T) {
+			actual, err := systemdPath(tt.cgroupsPath, "id")
+			if (err == nil) != tt.valid {
+				t.Fatalf("systemdPath(%q) error = %v, valid %v", tt.cgroupsPath, err, tt.valid)
+			}
+			if actual != tt.expected {
+				t.Errorf("systemdPath(%q), expected: %v, actual: %v", tt.cgroupsPath, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCpuWeight(t *testing.T) {
+	tests := []struct {
+		shares uint64
+		weight uint64
+	}{
+		{2, 1},
+		{1024, 39},
+		{262144, 10000},
+		{0, 1},
+	}
+
+	for _, tt := range tests {
+		if actual := cpuWeight(tt.shares); actual != tt.weight {
+			t.Errorf("cpuWeight(%d), expected: %d, actual: %d", tt.shares, tt.weight, actual)
+		}
+	}
+}
+
+func TestManager_Freeze(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Freeze(); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, err := m.Frozen(); err != nil || !frozen {
+		t.Errorf("expected cgroup to be frozen, frozen: %v, err: %v", frozen, err)
+	}
+
+	if err := m.Thaw(); err != nil {
+		t.Fatal(err)
+	}
+	if frozen, err := m.Frozen(); err != nil || frozen {
+		t.Errorf("expected cgroup to be thawed, frozen: %v, err: %v", frozen, err)
+	}
+}
+
+func TestManager_Freeze_WaitsForEvents(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := os.WriteFile(filepath.Join(m.Path(), eventsFileName), []byte("populated 1\nfrozen 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.Freeze(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManager_Stats(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"cpu.stat":            "usage_usec 1500\nuser_usec 1000\nsystem_usec 500\nnr_periods 10\nnr_throttled 2\nthrottled_usec 30\n",
+		"memory.current":      "4096\n",
+		"memory.max":          "max\n",
+		"memory.stat":         "anon 1024\nfile 2048\n",
+		memoryEventsFileName:  "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+		"memory.swap.current": "0\n",
+		"pids.current":        "2\n",
+		"pids.max":            "100\n",
+		"io.stat":             "8:0 rbytes=512 wbytes=1024 rios=1 wios=2 dbytes=0 dios=0\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(m.Path(), name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := m.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCPU := CPUStats{Total: 1500000, User: 1000000, System: 500000, Periods: 10, ThrottledPeriods: 2, ThrottledTime: 30000}
+	if stats.CPU != expectedCPU {
+		t.Errorf("cpu, expected: %+v, actual: %+v", expectedCPU, stats.CPU)
+	}
+	if stats.Memory.Usage != 4096 || stats.Memory.Limit != math.MaxUint64 || stats.Memory.MaxEvents != 3 {
+		t.Errorf("unexpected memory stats: %+v", stats.Memory)
+	}
+	if stats.Memory.Raw["file"] != 2048 {
+		t.Errorf("unexpected raw memory stats: %v", stats.Memory.Raw)
+	}
+	if stats.Pids != (PidsStats{Current: 2, Limit: 100}) {
+		t.Errorf("unexpected pids stats: %+v", stats.Pids)
+	}
+	expectedIO := IOStats{Major: 8, Minor: 0, ReadBytes: 512, WriteBytes: 1024, ReadIOs: 1, WriteIOs: 2}
+	if len(stats.IO) != 1 || stats.IO[0] != expectedIO {
+		t.Errorf("io, expected: [%+v], actual: %+v", expectedIO, stats.IO)
+	}
+
+	count, err := m.OOMKillCount()
+	if err != nil || count != 1 {
+		t.Errorf("oom kill count, expected: 1, actual: %d, err: %v", count, err)
+	}
+}
+
+func TestManager_NotifyOOM(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.MemoryEventsPath(), []byte("oom 0\noom_kill 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	ch, err := m.NotifyOOM(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(m.MemoryEventsPath(), []byte("oom 1\noom_kill 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Error("expected oom event")
+	}
+}
@@ -0,0 +1,61 @@
+package cgroups
+
+import (
+	"go.uber.org/zap"
+	"os"
+	"syscall"
+)
+
+// NotifyOOM returns a channel that receives a value for every process of the container cgroup
+// killed by the OOM killer. The kernel modifies memory.events on every memory event, so the
+// file is watched with inotify and the oom_kill counter is compared with its previous value.
+// The channel is closed when done is closed or the cgroup is removed.
+func (m *Manager) NotifyOOM(done <-chan struct{}) (<-chan struct{}, error) {
+	count, err := m.OOMKillCount()
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = syscall.InotifyAddWatch(fd, m.MemoryEventsPath(), syscall.IN_MODIFY); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	// the non-blocking fd is registered in the runtime poller, so closing the file interrupts Read
+	inotify := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-done
+		inotify.Close()
+	}()
+
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+
+		buf := make([]byte, 4096)
+		for {
+			if _, err := inotify.Read(buf); err != nil {
+				return
+			}
+
+			current, err := m.OOMKillCount()
+			if err != nil {
+				log.Debug("stop watching oom events", zap.Error(err))
+				return
+			}
+			for ; count < current; count++ {
+				select {
+				case ch <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,199 @@
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxValue = "max"
+
+// Set translates the resources into the files of the cgroup v2 unified controllers
+// and writes them into the container cgroup.
+func (m *Manager) Set(resources *specs.LinuxResources) (err error) {
+	if resources == nil {
+		return nil
+	}
+	if len(resources.Devices) > 0 {
+		log.Debug("device rules are not supported with cgroup v2 and are ignored")
+	}
+
+	files, err := resourceFiles(resources)
+	if err != nil {
+		return err
+	}
+
+	path := m.Path()
+	for _, file := range files {
+		if err = writeFile(path, file.name, file.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceFile is a single value that is written into a cgroup file
+type resourceFile struct {
+	name  string
+	value string
+}
+
+// resourceFiles converts the OCI resources into cgroup v2 files in the order they have to be written.
+func resourceFiles(resources *specs.LinuxResources) (files []resourceFile, err error) {
+	add := func(name, value string) {
+		files = append(files, resourceFile{name: name, value: value})
+	}
+
+	if memory := resources.Memory; memory != nil {
+		if memory.Reservation != nil {
+			add("memory.low", limit(*memory.Reservation))
+		}
+		if memory.Limit != nil {
+			add("memory.max", limit(*memory.Limit))
+		}
+		if memory.Swap != nil {
+			swap, err := swapLimit(memory.Limit, *memory.Swap)
+			if err != nil {
+				return nil, err
+			}
+			add("memory.swap.max", swap)
+		}
+	}
+
+	if cpu := resources.CPU; cpu != nil {
+		if cpu.Shares != nil && *cpu.Shares != 0 {
+			add("cpu.weight", strconv.FormatUint(cpuWeight(*cpu.Shares), 10))
+		}
+		if cpu.Quota != nil || cpu.Period != nil {
+			add("cpu.max", cpuMax(cpu.Quota, cpu.Period))
+		}
+		if cpu.Burst != nil {
+			add("cpu.max.burst", strconv.FormatUint(*cpu.Burst, 10))
+		}
+		if cpu.Idle != nil {
+			add("cpu.idle", strconv.FormatInt(*cpu.Idle, 10))
+		}
+		if cpu.Cpus != "" {
+			add("cpuset.cpus", cpu.Cpus)
+		}
+		if cpu.Mems != "" {
+			add("cpuset.mems", cpu.Mems)
+		}
+	}
+
+	if pids := resources.Pids; pids != nil {
+		if pids.Limit > 0 {
+			add("pids.max", strconv.FormatInt(pids.Limit, 10))
+		} else {
+			add("pids.max", maxValue)
+		}
+	}
+
+	if blockIO := resources.BlockIO; blockIO != nil {
+		if blockIO.Weight != nil && *blockIO.Weight != 0 {
+			add("io.weight", fmt.Sprintf("default %d", ioWeight(*blockIO.Weight)))
+		}
+		for _, device := range blockIO.WeightDevice {
+			if device.Weight != nil {
+				add("io.weight", fmt.Sprintf("%d:%d %d", device.Major, device.Minor, ioWeight(*device.Weight)))
+			}
+		}
+		throttles := []struct {
+			key     string
+			devices []specs.LinuxThrottleDevice
+		}{
+			{"rbps", blockIO.ThrottleReadBpsDevice},
+			{"wbps", blockIO.ThrottleWriteBpsDevice},
+			{"riops", blockIO.ThrottleReadIOPSDevice},
+			{"wiops", blockIO.ThrottleWriteIOPSDevice},
+		}
+		for _, throttle := range throttles {
+			for _, device := range throttle.devices {
+				rate := strconv.FormatUint(device.Rate, 10)
+				if device.Rate == 0 {
+					rate = maxValue
+				}
+				add("io.max", fmt.Sprintf("%d:%d %s=%s", device.Major, device.Minor, throttle.key, rate))
+			}
+		}
+	}
+
+	for _, hugepage := range resources.HugepageLimits {
+		add(fmt.Sprintf("hugetlb.%s.max", hugepage.Pagesize), strconv.FormatUint(hugepage.Limit, 10))
+	}
+
+	// unified values are written last, so they can override the converted values
+	names := make([]string, 0, len(resources.Unified))
+	for name := range resources.Unified {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid unified resource: %v", name)
+		}
+		add(name, resources.Unified[name])
+	}
+
+	return files, nil
+}
+
+// limit converts a limit into the cgroup v2 format, negative values mean unlimited
+func limit(value int64) string {
+	if value < 0 {
+		return maxValue
+	}
+	return strconv.FormatInt(value, 10)
+}
+
+// swapLimit converts the OCI swap limit (memory+swap) into the cgroup v2 swap limit (swap only).
+func swapLimit(memoryLimit *int64, swap int64) (string, error) {
+	switch {
+	case swap < 0:
+		return maxValue, nil
+	case memoryLimit == nil || *memoryLimit < 0:
+		return "", errors.New("memory swap limit requires a memory limit")
+	case swap < *memoryLimit:
+		return "", fmt.Errorf("memory swap limit %d is lower than the memory limit %d", swap, *memoryLimit)
+	default:
+		return strconv.FormatInt(swap-*memoryLimit, 10), nil
+	}
+}
+
+// cpuWeight converts cpu shares [2-262144] into a cgroup v2 cpu weight [1-10000].
+func cpuWeight(shares uint64) uint64 {
+	if shares < 2 {
+		shares = 2
+	}
+	if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// cpuMax converts the cpu quota and period into the cgroup v2 cpu.max format "$MAX $PERIOD".
+func cpuMax(quota *int64, period *uint64) string {
+	value := maxValue
+	if quota != nil && *quota > 0 {
+		value = strconv.FormatInt(*quota, 10)
+	}
+	if period == nil || *period == 0 {
+		return value
+	}
+	return fmt.Sprintf("%s %d", value, *period)
+}
+
+// ioWeight converts a blkio weight [10-1000] into a cgroup v2 io weight [1-10000].
+func ioWeight(weight uint16) uint64 {
+	w := uint64(weight)
+	if w < 10 {
+		w = 10
+	}
+	if w > 1000 {
+		w = 1000
+	}
+	return 1 + (w-10)*9999/990
+}
@@ -0,0 +1,222 @@
+package cgroups
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	memoryEventsFileName = "memory.events"
+
+	// oomKillKey is the key of memory.events that counts the processes killed by the OOM killer
+	oomKillKey = "oom_kill"
+)
+
+// Stats contains the resource usage of a cgroup.
+// Times are in nanoseconds, sizes in bytes. Unlimited limits are math.MaxUint64.
+type Stats struct {
+	CPU    CPUStats
+	Memory MemoryStats
+	Pids   PidsStats
+	IO     []IOStats
+}
+
+// CPUStats contains the values of cpu.stat
+type CPUStats struct {
+	Total            uint64
+	User             uint64
+	System           uint64
+	Periods          uint64
+	ThrottledPeriods uint64
+	ThrottledTime    uint64
+}
+
+// MemoryStats contains the values of the memory controller files
+type MemoryStats struct {
+	Usage     uint64
+	Limit     uint64
+	MaxEvents uint64 // number of times the usage hit the limit
+	SwapUsage uint64
+	SwapLimit uint64
+	Raw       map[string]uint64 // content of memory.stat
+}
+
+// PidsStats contains the values of the pids controller files
+type PidsStats struct {
+	Current uint64
+	Limit   uint64
+}
+
+// IOStats contains the values of io.stat of a single device
+type IOStats struct {
+	Major      uint64
+	Minor      uint64
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadIOs    uint64
+	WriteIOs   uint64
+}
+
+// Stats reads the resource usage of the container cgroup.
+// Files of controllers that are not enabled are skipped.
+func (m *Manager) Stats() (stats *Stats, err error) {
+	stats = new(Stats)
+	path := m.Path()
+
+	cpu, err := readKeyValues(path, "cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	stats.CPU = CPUStats{
+		Total:            cpu["usage_usec"] * 1000,
+		User:             cpu["user_usec"] * 1000,
+		System:           cpu["system_usec"] * 1000,
+		Periods:          cpu["nr_periods"],
+		ThrottledPeriods: cpu["nr_throttled"],
+		ThrottledTime:    cpu["throttled_usec"] * 1000,
+	}
+
+	if stats.Memory.Raw, err = readKeyValues(path, "memory.stat"); err != nil {
+		return nil, err
+	}
+	memoryEvents, err := readKeyValues(path, memoryEventsFileName)
+	if err != nil {
+		return nil, err
+	}
+	stats.Memory.MaxEvents = memoryEvents["max"]
+
+	files := []struct {
+		name  string
+		value *uint64
+	}{
+		{"memory.current", &stats.Memory.Usage},
+		{"memory.max", &stats.Memory.Limit},
+		{"memory.swap.current", &stats.Memory.SwapUsage},
+		{"memory.swap.max", &stats.Memory.SwapLimit},
+		{"pids.current", &stats.Pids.Current},
+		{"pids.max", &stats.Pids.Limit},
+	}
+	for _, file := range files {
+		if *file.value, err = readUint(path, file.name); err != nil {
+			return nil, err
+		}
+	}
+
+	if stats.IO, err = readIOStats(path); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// OOMKillCount returns the number of processes of the container cgroup killed by the OOM killer.
+func (m *Manager) OOMKillCount() (uint64, error) {
+	events, err := readKeyValues(m.Path(), memoryEventsFileName)
+	if err != nil {
+		return 0, err
+	}
+	return events[oomKillKey], nil
+}
+
+// MemoryEventsPath returns the path of memory.events, it is modified whenever a memory event occurs.
+func (m *Manager) MemoryEventsPath() string {
+	return filepath.Join(m.Path(), memoryEventsFileName)
+}
+
+// readUint reads a file containing a single value. "max" is returned as math.MaxUint64.
+// A file that doesn't exist is returned as 0.
+func readUint(cgroup, name string) (uint64, error) {
+	content, err := os.ReadFile(filepath.Join(cgroup, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseUint(strings.TrimSpace(string(content)))
+}
+
+// readKeyValues reads a flat keyed file with lines of "key value".
+// A file that doesn't exist is returned as empty map.
+func readKeyValues(cgroup, name string) (values map[string]uint64, err error) {
+	values = make(map[string]uint64)
+
+	f, err := os.Open(filepath.Join(cgroup, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			continue
+		}
+		if values[key], err = parseUint(value); err != nil {
+			return nil, fmt.Errorf("invalid value of %v in %v: %w", key, name, err)
+		}
+	}
+	return values, scanner.Err()
+}
+
+// readIOStats reads the nested keyed io.stat with lines of "major:minor key=value...".
+func readIOStats(cgroup string) (stats []IOStats, err error) {
+	f, err := os.Open(filepath.Join(cgroup, "io.stat"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var device IOStats
+		if _, err = fmt.Sscanf(fields[0], "%d:%d", &device.Major, &device.Minor); err != nil {
+			return nil, fmt.Errorf("invalid device in io.stat: %v", fields[0])
+		}
+
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			v, err := parseUint(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %v in io.stat: %w", key, err)
+			}
+			switch key {
+			case "rbytes":
+				device.ReadBytes = v
+			case "wbytes":
+				device.WriteBytes = v
+			case "rios":
+				device.ReadIOs = v
+			case "wios":
+				device.WriteIOs = v
+			}
+		}
+		stats = append(stats, device)
+	}
+	return stats, scanner.Err()
+}
+
+// parseUint parses a cgroup value, "max" is returned as math.MaxUint64.
+func parseUint(value string) (uint64, error) {
+	if value == maxValue {
+		return math.MaxUint64, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
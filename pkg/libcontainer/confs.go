@@ -1,6 +1,7 @@
 package libcontainer
 
 import (
+	"errors"
 	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
@@ -8,15 +9,23 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"roci/pkg/libcontainer/cgroups"
+	"roci/pkg/libcontainer/console"
+	"roci/pkg/libcontainer/events"
 	"roci/pkg/libcontainer/initp"
 	"roci/pkg/libcontainer/ipc"
+	"roci/pkg/libcontainer/namespace"
+	"roci/pkg/libcontainer/network"
 	"roci/pkg/libcontainer/oci"
 	"roci/pkg/libcontainer/rootfs"
+	"roci/pkg/libcontainer/seccomp"
 	"roci/pkg/logger"
 	"roci/pkg/model"
 	"roci/pkg/procfs"
 	"roci/pkg/util"
+	"slices"
 	"syscall"
+	"time"
 )
 
 var (
@@ -29,7 +38,7 @@ type ContainerFS interface {
 
 	// Create initializes and creates a new container with the given ID, bundle path, and OCI runtime specification.
 	// Returns the created container and any error encountered.
-	Create(id, bundle string, spec specs.Spec) (container *Container, err error)
+	Create(id, bundle string, spec specs.Spec, opts ProcessOpts) (container *Container, err error)
 
 	// Start launches the container with the specified ID.
 	// It returns any error encountered during the start process.
@@ -52,36 +61,146 @@ type ContainerFS interface {
 	// List returns a list of all containers' states.
 	// It returns the list of container states and any error encountered.
 	List() (containers []specs.State, err error)
+
+	// Exec starts an additional process inside the container with the specified ID.
+	// It returns the started process and any error encountered.
+	Exec(id string, process *specs.Process, opts ProcessOpts) (p *initp.ExecProcess, err error)
+
+	// Update changes the resources of the container with the specified ID.
+	// It returns any error encountered during the update.
+	Update(id string, resources *specs.LinuxResources) (err error)
+
+	// Pause suspends all processes of the container with the specified ID.
+	// It returns any error encountered during the process.
+	Pause(id string) (err error)
+
+	// Resume resumes all processes of the paused container with the specified ID.
+	// It returns any error encountered during the process.
+	Resume(id string) (err error)
+
+	// Pids returns the process IDs of all processes inside the container with the specified ID.
+	// It returns the list of process IDs and any error encountered.
+	Pids(id string) (pids []int, err error)
+
+	// Stats returns the resource usage of the container with the specified ID.
+	// It returns the statistics and any error encountered.
+	Stats(id string) (stats *events.Stats, err error)
+
+	// Events streams the events of the container with the specified ID until it stops or done is closed.
+	// It returns the channel of events and any error encountered.
+	Events(id string, interval time.Duration, done <-chan struct{}) (<-chan events.Event, error)
+}
+
+// ProcessOpts contains the options of the runtime for a started container process.
+type ProcessOpts struct {
+	// ConsoleSocket is the path of a unix socket that receives the master of the pty
+	// of a process with terminal.
+	ConsoleSocket string
+
+	// PreserveFds is the number of additional fds of the runtime, following stdio and
+	// the fds of systemd socket activation, that are inherited by the process.
+	PreserveFds int
+
+	// NoPivot changes the root of the container with chroot instead of pivot_root.
+	// It is only used when the container is created.
+	NoPivot bool
 }
 
 // FS represents a file system that manages containers.
 type FS struct {
-	dir string // Directory where container (state and configuration) are stored
+	dir     string             // Directory where container (state and configuration) are stored
+	network network.Config     // Network configuration of the containers with a new network namespace
+	mounts  rootfs.MountPolicy // Policy for failed mounts of the spec
 }
 
-// NewContainerFS creates a new FS instance with the specified container directory.
-// It initializes the directory structure if it does not already exist.
-func NewContainerFS(rootDir string) (*FS, error) {
+// NewContainerFS creates a new FS instance with the specified container directory, network configuration
+// and mount policy. It initializes the directory structure if it does not already exist.
+func NewContainerFS(rootDir string, networkConfig network.Config, mountPolicy rootfs.MountPolicy) (*FS, error) {
 	if rootDir == "" {
 		return nil, fmt.Errorf("rootDir is empty")
 	}
+	if err := networkConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if err := mountPolicy.Validate(); err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(rootDir, 0o700); err != nil {
 		return nil, err
 	}
-	return &FS{dir: rootDir}, nil
+	return &FS{dir: rootDir, network: networkConfig, mounts: mountPolicy}, nil
 }
 
 // Create initializes and creates a new container with the given ID, bundle path, and OCI runtime specification.
 // It returns the created container and any error encountered.
-func (r *FS) Create(id, bundle string, spec specs.Spec) (*Container, error) {
+func (r *FS) Create(id, bundle string, spec specs.Spec, opts ProcessOpts) (c *Container, err error) {
 	stateDir, err := r.validateId(id)
 	if err != nil {
 		return nil, err
 	}
 
+	// Invalid namespaces are rejected before anything is created
+	if spec.Linux != nil {
+		if _, err = namespace.From(spec); err != nil {
+			return nil, err
+		}
+	}
+	if err = validateRoot(spec); err != nil {
+		return nil, err
+	}
+
+	// Unknown capabilities and rlimits are rejected as well
+	if spec.Process != nil {
+		if err = validateCapabilities(spec.Process.Capabilities); err != nil {
+			return nil, err
+		}
+		if err = validateRlimits(spec.Process.Rlimits); err != nil {
+			return nil, err
+		}
+	}
+
+	// Invalid seccomp filters as well
+	var seccompConfig *specs.LinuxSeccomp
+	if spec.Linux != nil {
+		seccompConfig = spec.Linux.Seccomp
+	}
+	filter, err := seccomp.Compile(seccompConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The console socket is connected first, so an invalid socket doesn't leave a state dir behind.
+	// The runtime closes its copy after the init process was started.
+	consoleSocket, err := openConsoleSocket(spec.Process, opts.ConsoleSocket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil && consoleSocket != nil {
+			consoleSocket.Close()
+		}
+	}()
+
+	// The seccomp listener socket is connected as well, if the filter notifies
+	listener, err := openListenerSocket(filter, seccompConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil && listener != nil {
+			listener.Close()
+		}
+	}()
+
 	if err := os.Mkdir(stateDir, 0o711); err != nil {
 		return nil, err
 	}
+	var cgroup *cgroups.Manager
+	defer func() {
+		if err != nil {
+			r.removeCreated(stateDir, cgroup)
+		}
+	}()
 
 	// Create IPC pipes required for communication with the container
 	err = ipc.CreateRuntimePipe(stateDir)
@@ -93,12 +212,30 @@ func (r *FS) Create(id, bundle string, spec specs.Spec) (*Container, error) {
 		return nil, err
 	}
 
+	// Create the cgroup before the spec is copied, because the spec stores the resolved cgroup path
+	cgroup, err = createCgroup(id, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// The inherited files are determined before the spec is copied, because socket activation changes the env
+	files := inheritedFiles(spec.Process, opts.PreserveFds)
+	if err = closeExecFrom(listenFdsStart + len(files)); err != nil {
+		return nil, err
+	}
+
 	// Copy the OCI runtime specification into the state dir
 	err = util.WriteJsonFile(path.Join(stateDir, model.OciSpecFileName), &spec)
 	if err != nil {
 		return nil, err
 	}
 
+	// Prepare the network of the container, it is attached after the init process was started
+	attachment, err := r.createNetwork(id, stateDir, &spec)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize the state
 	state, err := NewStateManager(stateDir, &specs.State{
 		Version: oci.Version,
@@ -110,16 +247,45 @@ func (r *FS) Create(id, bundle string, spec specs.Spec) (*Container, error) {
 		return nil, err
 	}
 
+	process, err := initp.NewInitProcess(spec.Root.Path, stateDir, &spec, cgroup, initp.InitOpts{
+		ExtraFiles:      files,
+		ConsoleSocket:   consoleSocket,
+		SeccompListener: listener,
+		NoPivot:         opts.NoPivot,
+		MountPolicy:     r.mounts,
+		Network:         attachment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Create and return a new Container instance
-	c := &Container{
+	c = &Container{
 		id:     id,
 		state:  state,
 		config: spec,
-		initp:  initp.NewInitProcess(spec.Root.Path, stateDir, &spec),
+		initp:  process,
 	}
 	return c, nil
 }
 
+// removeCreated removes the network attachment, the cgroup and the state dir of a container whose init process
+// isn't running. It cleans up after a failed create, so errors are only logged.
+func (r *FS) removeCreated(stateDir string, cgroup *cgroups.Manager) {
+	log := logger.Log().With(zap.String("stateDir", stateDir))
+	if err := removeNetwork(stateDir); err != nil {
+		log.Warn("failed to remove network", zap.Error(err))
+	}
+	if cgroup != nil {
+		if err := cgroup.Destroy(); err != nil {
+			log.Warn("failed to destroy cgroup", zap.Error(err))
+		}
+	}
+	if err := os.RemoveAll(stateDir); err != nil {
+		log.Warn("failed to remove state dir", zap.Error(err))
+	}
+}
+
 // Start launches the container specified by the given ID.
 // Uses the ipc pipes to send the start signal
 // It returns any error encountered during the start process.
@@ -141,6 +307,10 @@ func (r *FS) Start(id string) (err error) {
 	if err != nil {
 		return err
 	}
+	// the init process of a paused container is frozen and can't receive the start
+	if state.State().Status == oci.StatePaused {
+		return model.ErrPaused
+	}
 
 	log.Debug("load spec")
 	spec, err := r.loadSpec(id)
@@ -148,6 +318,11 @@ func (r *FS) Start(id string) (err error) {
 		return err
 	}
 
+	log.Debug("check network")
+	if err = checkNetwork(stateDir, state.State().Pid); err != nil {
+		return err
+	}
+
 	log.Debug("invoking hooks HookStartContainer")
 	err = oci.InvokeHooks(spec.Hooks, oci.HookStartContainer)
 	if err != nil {
@@ -181,10 +356,21 @@ func (r *FS) Kill(id string, signal syscall.Signal) (err error) {
 	}
 	state := sm.State()
 
-	if state.Status != specs.StateRunning && state.Status != specs.StateCreated {
+	if state.Status != specs.StateRunning && state.Status != specs.StateCreated && state.Status != oci.StatePaused {
 		return model.ErrNotRunning
 	}
 
+	if state.Status == oci.StatePaused {
+		// signals are only handled by thawed processes, a frozen container keeps other signals pending
+		if signal != syscall.SIGKILL {
+			return syscall.Kill(state.Pid, signal)
+		}
+
+		if err = r.thaw(id); err != nil {
+			return err
+		}
+	}
+
 	err = syscall.Kill(state.Pid, signal)
 	switch {
 	case err == syscall.ESRCH:
@@ -222,18 +408,37 @@ func (r *FS) Remove(id string) (err error) {
 		return err
 	}
 
+	// The cgroup and the network attachment are removed even if the other one fails,
+	// their errors are returned after the state dir was removed.
+	var errs []error
+
+	// Remove the container's cgroup
+	cgroup, err := cgroupManager(id, spec)
+	if err != nil {
+		errs = append(errs, err)
+	} else if cgroup != nil {
+		if err = cgroup.Destroy(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to destroy cgroup: %w", err))
+		}
+	}
+
+	// Remove the container's network attachment
+	if err = removeNetwork(r.stateDir(id)); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove network: %w", err))
+	}
+
 	// Remove the container's state directory
 	err = os.RemoveAll(r.stateDir(id))
 	if err != nil {
-		return err
+		return errors.Join(append(errs, err)...)
 	}
 
 	err = oci.InvokeHooks(spec.Hooks, oci.HookPostStop)
 	if err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // State retrieves the current state of the container with the specified ID.
@@ -261,7 +466,7 @@ func (r *FS) State(id string) (state specs.State, err error) {
 		}
 	}
 
-	return state, nil
+	return sm.State(), nil
 }
 
 // List returns a list of all containers' states.
@@ -286,6 +491,263 @@ func (r *FS) List() (containers []specs.State, err error) {
 	return containers, nil
 }
 
+// Exec starts an additional process inside the namespaces of the container with the specified ID.
+// The container needs to be created or running.
+// It returns the started process and any error encountered.
+func (r *FS) Exec(id string, process *specs.Process, opts ProcessOpts) (p *initp.ExecProcess, err error) {
+	state, err := r.State(id)
+	if err != nil {
+		return nil, err
+	}
+	switch state.Status {
+	case specs.StateRunning, specs.StateCreated:
+	case oci.StatePaused:
+		return nil, model.ErrPaused
+	default:
+		return nil, model.ErrNotRunning
+	}
+
+	spec, err := r.loadSpec(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the exec process is moved into the cgroup of the container before it is set up
+	cgroup, err := cgroupManager(id, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var seccompConfig *specs.LinuxSeccomp
+	if spec.Linux != nil {
+		seccompConfig = spec.Linux.Seccomp
+	}
+	filter, err := seccomp.Compile(seccompConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// the runtime closes its copies of the sockets after the exec process was started
+	var execOpts initp.ExecOpts
+	execOpts.ConsoleSocket, err = openConsoleSocket(process, opts.ConsoleSocket)
+	if err != nil {
+		return nil, err
+	}
+	execOpts.SeccompListener, err = openListenerSocket(filter, seccompConfig)
+	if err != nil {
+		if execOpts.ConsoleSocket != nil {
+			execOpts.ConsoleSocket.Close()
+		}
+		return nil, err
+	}
+
+	p, err = initp.NewExecProcess(state, spec, process, cgroup, execOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.Start()
+	return p, err
+}
+
+// Update applies the resources to the cgroup of the container with the specified ID.
+// Only the resources that are set are changed. The updated resources are stored in the
+// spec inside the state directory.
+func (r *FS) Update(id string, resources *specs.LinuxResources) (err error) {
+	state, err := r.State(id)
+	if err != nil {
+		return err
+	}
+	if state.Status == specs.StateStopped {
+		return model.ErrNotRunning
+	}
+
+	spec, err := r.loadSpec(id)
+	if err != nil {
+		return err
+	}
+
+	cgroup, err := r.cgroup(id)
+	if err != nil {
+		return err
+	}
+
+	if spec.Linux == nil {
+		spec.Linux = new(specs.Linux)
+	}
+	spec.Linux.Resources = mergeResources(spec.Linux.Resources, resources)
+	if err = cgroup.Set(spec.Linux.Resources); err != nil {
+		return err
+	}
+
+	return util.WriteJsonFile(path.Join(r.stateDir(id), model.OciSpecFileName), spec)
+}
+
+// pausedStatusFileName is the file in the state dir with the status of a paused container before it was paused
+const pausedStatusFileName = "paused-status"
+
+// Pause freezes the cgroup of the container with the specified ID.
+// Created and running containers can be paused, resuming restores their status.
+func (r *FS) Pause(id string) (err error) {
+	if err = r.assertContainerExists(id); err != nil {
+		return err
+	}
+	sm, err := LoadStateManager(r.stateDir(id))
+	if err != nil {
+		return err
+	}
+
+	status := sm.State().Status
+	switch status {
+	case specs.StateCreated, specs.StateRunning:
+	case oci.StatePaused:
+		return model.ErrPaused
+	default:
+		return model.ErrNotRunning
+	}
+
+	cgroup, err := r.cgroup(id)
+	if err != nil {
+		return err
+	}
+	if err = cgroup.Freeze(); err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(r.stateDir(id), pausedStatusFileName), []byte(status), 0o600); err != nil {
+		_ = cgroup.Thaw()
+		return err
+	}
+
+	sm.SetStatus(oci.StatePaused)
+	return sm.UpdateState()
+}
+
+// Resume thaws the cgroup of the paused container with the specified ID.
+func (r *FS) Resume(id string) (err error) {
+	if err = r.assertContainerExists(id); err != nil {
+		return err
+	}
+	sm, err := LoadStateManager(r.stateDir(id))
+	if err != nil {
+		return err
+	}
+
+	if sm.State().Status != oci.StatePaused {
+		return model.ErrNotPaused
+	}
+
+	if err = r.thaw(id); err != nil {
+		return err
+	}
+
+	// the container was running if its status before the pause is unknown
+	status := specs.StateRunning
+	pausedStatusFile := path.Join(r.stateDir(id), pausedStatusFileName)
+	if content, err := os.ReadFile(pausedStatusFile); err == nil {
+		status = specs.ContainerState(content)
+	}
+	_ = os.Remove(pausedStatusFile)
+
+	sm.SetStatus(status)
+	return sm.UpdateState()
+}
+
+// Pids returns the host process IDs of all processes inside the container with the specified ID.
+// The processes are read from the container cgroup. Without cgroup v2 all processes in the
+// pid namespace of the init process are returned.
+func (r *FS) Pids(id string) (pids []int, err error) {
+	state, err := r.State(id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status == specs.StateStopped {
+		return nil, model.ErrNotRunning
+	}
+
+	spec, err := r.loadSpec(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cgroup, err := cgroupManager(id, spec)
+	if err != nil {
+		return nil, err
+	}
+	if cgroup != nil {
+		return cgroup.Pids()
+	}
+
+	nsPids, err := procfs.Root.PidsInNamespace(procfs.Pid(state.Pid))
+	if err != nil {
+		return nil, err
+	}
+	for _, pid := range nsPids {
+		pids = append(pids, int(pid))
+	}
+	return pids, nil
+}
+
+// openConsoleSocket connects to the console socket at path if the process has a terminal.
+// A console socket is required for processes with terminal and not allowed for processes without.
+func openConsoleSocket(process *specs.Process, path string) (*os.File, error) {
+	terminal := process != nil && process.Terminal
+	switch {
+	case terminal && path == "":
+		return nil, fmt.Errorf("process.terminal requires a console socket")
+	case !terminal && path != "":
+		return nil, fmt.Errorf("a console socket requires process.terminal to be true")
+	case !terminal:
+		return nil, nil
+	}
+	return console.Connect(path)
+}
+
+// openListenerSocket connects to the listener socket of the seccomp configuration, if the filter notifies.
+func openListenerSocket(filter *seccomp.Filter, config *specs.LinuxSeccomp) (*os.File, error) {
+	if !filter.Notifies() {
+		if config != nil && config.ListenerPath != "" {
+			logger.Log().Warn("seccomp listener path is ignored, because no action notifies", zap.String("path", config.ListenerPath))
+		}
+		return nil, nil
+	}
+	return seccomp.Connect(config.ListenerPath)
+}
+
+// thaw thaws the cgroup of the container with the specified ID.
+func (r *FS) thaw(id string) error {
+	cgroup, err := r.cgroup(id)
+	if err != nil {
+		return err
+	}
+	return cgroup.Thaw()
+}
+
+// cgroup returns the cgroup manager of the container with the specified ID.
+// It returns an error if the host has no cgroup v2 hierarchy.
+func (r *FS) cgroup(id string) (*cgroups.Manager, error) {
+	spec, err := r.loadSpec(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cgroup, err := cgroupManager(id, spec)
+	if err != nil {
+		return nil, err
+	}
+	if cgroup == nil {
+		return nil, fmt.Errorf("operation requires cgroup v2")
+	}
+	return cgroup, nil
+}
+
+// Spec returns the OCI runtime specification of the container with the specified ID.
+func (r *FS) Spec(id string) (spec *specs.Spec, err error) {
+	if err = r.assertContainerExists(id); err != nil {
+		return nil, err
+	}
+	return r.loadSpec(id)
+}
+
 // loadSpec loads the OCI runtime specification for the container with the given ID.
 // It returns the specification and any error encountered.
 func (r *FS) loadSpec(id string) (spec *specs.Spec, err error) {
@@ -339,6 +801,55 @@ func (r *FS) assertContainerNotExists(id string) error {
 	}
 }
 
+// validateCapabilities checks that the capability sets only contain known capabilities.
+func validateCapabilities(caps *specs.LinuxCapabilities) error {
+	if caps == nil {
+		return nil
+	}
+	var errs []error
+	for _, set := range []struct {
+		name  string
+		names []string
+	}{
+		{"bounding", caps.Bounding},
+		{"effective", caps.Effective},
+		{"permitted", caps.Permitted},
+		{"inheritable", caps.Inheritable},
+		{"ambient", caps.Ambient},
+	} {
+		if _, err := model.Capabilities(set.names); err != nil {
+			errs = append(errs, fmt.Errorf("%v set: %w", set.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateRlimits checks that the rlimits only contain known resources.
+func validateRlimits(rlimits []specs.POSIXRlimit) error {
+	names := make([]string, len(rlimits))
+	for i, rlimit := range rlimits {
+		names[i] = rlimit.Type
+	}
+	return model.Rlimits(names)
+}
+
+// validateRoot checks the rootfs propagation and that a read-only root has a mount namespace to be remounted in.
+func validateRoot(spec specs.Spec) error {
+	mountNamespace := false
+	if spec.Linux != nil {
+		if _, err := oci.RootfsPropagation(spec.Linux.RootfsPropagation); err != nil {
+			return err
+		}
+		mountNamespace = slices.ContainsFunc(spec.Linux.Namespaces, func(ns specs.LinuxNamespace) bool {
+			return ns.Type == specs.MountNamespace
+		})
+	}
+	if spec.Root != nil && spec.Root.Readonly && !mountNamespace {
+		return fmt.Errorf("read-only root requires a mount namespace")
+	}
+	return nil
+}
+
 // validateIdFormat checks if the container ID is in a valid format using a regular expression.
 func validateIdFormat(id string) bool {
 	switch {
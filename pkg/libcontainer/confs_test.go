@@ -2,6 +2,8 @@ package libcontainer
 
 import (
 	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
 func TestValidateId(t *testing.T) {
@@ -34,3 +36,56 @@ func TestValidateId(t *testing.T) {
 	}
 
 }
+
+func TestValidateCapabilities(t *testing.T) {
+	if err := validateCapabilities(nil); err != nil {
+		t.Error(err)
+	}
+
+	caps := &specs.LinuxCapabilities{Bounding: []string{"CAP_KILL"}, Ambient: []string{"CAP_NET_RAW"}}
+	if err := validateCapabilities(caps); err != nil {
+		t.Error(err)
+	}
+
+	caps.Effective = []string{"CAP_FOO"}
+	caps.Ambient = []string{"CAP_BAR"}
+	err := validateCapabilities(caps)
+	if err == nil {
+		t.Fatal("expected error for unknown capabilities")
+	}
+	if expected := "effective set: unknown capabilities: CAP_FOO\nambient set: unknown capabilities: CAP_BAR"; err.Error() != expected {
+		t.Errorf("error, expected: %q, actual: %q", expected, err.Error())
+	}
+}
+
+func TestValidateRlimits(t *testing.T) {
+	if err := validateRlimits([]specs.POSIXRlimit{{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024}}); err != nil {
+		t.Error(err)
+	}
+	if err := validateRlimits([]specs.POSIXRlimit{{Type: "RLIMIT_FOO"}}); err == nil {
+		t.Error("expected error for unknown rlimit")
+	}
+}
+
+func TestValidateRoot(t *testing.T) {
+	mountNamespace := []specs.LinuxNamespace{{Type: specs.MountNamespace}}
+	tests := []struct {
+		name  string
+		spec  specs.Spec
+		valid bool
+	}{
+		{"no linux", specs.Spec{}, true},
+		{"propagation", specs.Spec{Linux: &specs.Linux{RootfsPropagation: "rshared"}}, true},
+		{"invalid propagation", specs.Spec{Linux: &specs.Linux{RootfsPropagation: "foo"}}, false},
+		{"read-only", specs.Spec{Root: &specs.Root{Readonly: true}, Linux: &specs.Linux{Namespaces: mountNamespace}}, true},
+		{"read-only without mount namespace", specs.Spec{Root: &specs.Root{Readonly: true}, Linux: &specs.Linux{}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRoot(tt.spec); (err == nil) != tt.valid {
+				t.Errorf("validateRoot, expected valid: %v, actual error: %v", tt.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Package console allocates the pseudo terminal of container processes with
+// process.terminal set and hands the master side over to the caller of the runtime.
+package console
+
+import (
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// EnvSocketFd is the environment variable that contains the fd of the console socket in the init process
+	EnvSocketFd = "_ROCI_CONSOLE_SOCKET_FD"
+
+	// tiocgptpeer opens the slave of a pty master without its path (since Linux 4.13)
+	tiocgptpeer = 0x5441
+)
+
+// ptmxPaths are the paths of the pty multiplexer, the second one is used if /dev/ptmx doesn't exist
+var ptmxPaths = []string{"/dev/ptmx", "/dev/pts/ptmx"}
+
+// NewPty allocates a new pseudo terminal in the devpts instance of root.
+// It returns the master and slave side of the terminal, the name of the slave is its path below root.
+func NewPty(root string) (master, slave *os.File, err error) {
+	for _, path := range ptmxPaths {
+		master, err = os.OpenFile(filepath.Join(root, path), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+		if !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open ptmx: %w", err)
+	}
+
+	if slave, err = openSlave(root, master); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// openSlave unlocks the pty and opens its slave side.
+func openSlave(root string, master *os.File) (*os.File, error) {
+	var unlock int32
+	if err := ioctl(master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		return nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+
+	var n uint32
+	if err := ioctl(master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		return nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+	name := filepath.Join("/dev/pts", fmt.Sprint(n))
+
+	fd, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), tiocgptpeer, uintptr(os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC))
+	if errno == 0 {
+		return os.NewFile(fd, name), nil
+	}
+
+	// older kernels require the slave to be opened by its path
+	slaveFd, err := syscall.Open(filepath.Join(root, name), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pty slave: %w", err)
+	}
+	return os.NewFile(uintptr(slaveFd), name), nil
+}
+
+// SetSize sets the window size of the terminal. A nil size is ignored.
+func SetSize(terminal *os.File, size *specs.Box) error {
+	if size == nil {
+		return nil
+	}
+	ws := struct{ row, col, xpixel, ypixel uint16 }{row: uint16(size.Height), col: uint16(size.Width)}
+	return ioctl(terminal.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// Size returns the window size of the terminal.
+func Size(terminal *os.File) (*specs.Box, error) {
+	var ws struct{ row, col, xpixel, ypixel uint16 }
+	if err := ioctl(terminal.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return nil, err
+	}
+	return &specs.Box{Height: uint(ws.row), Width: uint(ws.col)}, nil
+}
+
+// SetControllingTerminal makes slave the stdin, stdout, stderr and controlling terminal of the calling process.
+// The calling process has to be a session leader.
+func SetControllingTerminal(slave *os.File) error {
+	for fd := 0; fd <= 2; fd++ {
+		if err := syscall.Dup3(int(slave.Fd()), fd, 0); err != nil {
+			return fmt.Errorf("failed to dup pty slave: %w", err)
+		}
+	}
+	if err := ioctl(0, syscall.TIOCSCTTY, 0); err != nil {
+		return fmt.Errorf("failed to set controlling terminal: %w", err)
+	}
+	return nil
+}
+
+// Connect connects to the console socket at path.
+// It returns the socket as file, so it can be passed to a child process.
+func Connect(path string) (*os.File, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to console socket: %w", err)
+	}
+	defer conn.Close()
+	return conn.File()
+}
+
+// SendMaster sends the master side of the pty with SCM_RIGHTS over the console socket.
+// The path of the slave is sent as message, like runc does.
+func SendMaster(socket, master *os.File, slavePath string) error {
+	rights := syscall.UnixRights(int(master.Fd()))
+	if err := syscall.Sendmsg(int(socket.Fd()), []byte(slavePath), rights, nil, 0); err != nil {
+		return fmt.Errorf("failed to send pty master: %w", err)
+	}
+	return nil
+}
+
+// ReceiveMaster receives the master side of a pty sent with SendMaster, it's named after the path of the slave.
+func ReceiveMaster(conn *net.UnixConn) (*os.File, error) {
+	name := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(name, oob)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("expected 1 control message, got %d", len(messages))
+	}
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(fds) != 1 {
+		return nil, fmt.Errorf("expected 1 fd, got %d", len(fds))
+	}
+	return os.NewFile(uintptr(fds[0]), string(name[:n])), nil
+}
+
+// MakeRaw puts the terminal into raw mode.
+// It returns a function that restores the previous mode.
+func MakeRaw(terminal *os.File) (restore func() error, err error) {
+	var termios syscall.Termios
+	if err = ioctl(terminal.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return nil, err
+	}
+	previous := termios
+
+	// see cfmakeraw(3)
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if err = ioctl(terminal.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&termios))); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return ioctl(terminal.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&previous)))
+	}, nil
+}
+
+// IsTerminal checks whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	return ioctl(f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios))) == nil
+}
+
+func ioctl(fd, request, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
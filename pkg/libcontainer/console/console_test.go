@@ -0,0 +1,87 @@
+package console
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestSendReceiveMaster(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := os.NewFile(uintptr(fds[0]), "socket")
+	defer socket.Close()
+	peer := os.NewFile(uintptr(fds[1]), "peer")
+	conn, err := net.FileConn(peer)
+	peer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	master, err := os.CreateTemp(t.TempDir(), "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	const slavePath = "/dev/pts/3"
+	if err = SendMaster(socket, master, slavePath); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := ReceiveMaster(conn.(*net.UnixConn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer received.Close()
+
+	if received.Name() != slavePath {
+		t.Errorf("name, expected: %v, actual: %v", slavePath, received.Name())
+	}
+
+	var expected, actual syscall.Stat_t
+	if err = syscall.Fstat(int(master.Fd()), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if err = syscall.Fstat(int(received.Fd()), &actual); err != nil {
+		t.Fatal(err)
+	}
+	if expected.Ino != actual.Ino {
+		t.Error("received fd refers to a different file")
+	}
+}
+
+func TestNewPty(t *testing.T) {
+	master, slave, err := NewPty("/")
+	if err != nil {
+		t.Skipf("pty not available: %v", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	if !IsTerminal(slave) {
+		t.Error("expected slave to be a terminal")
+	}
+	if path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", slave.Fd())); err != nil || path != slave.Name() {
+		t.Errorf("slave name, expected: %v, actual: %v", path, slave.Name())
+	}
+
+	size := &specs.Box{Height: 24, Width: 80}
+	if err = SetSize(master, size); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := Size(slave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *actual != *size {
+		t.Errorf("size, expected: %+v, actual: %+v", size, actual)
+	}
+}
@@ -24,6 +24,12 @@ func (c *Container) Init() (pid int, err error) {
 	return c.initp.Start()
 }
 
+// Wait waits for the init process of the container to exit and returns its exit status.
+// This only works in the runtime process that created the container.
+func (c *Container) Wait() (status int, err error) {
+	return c.initp.Wait()
+}
+
 // State returns the current state of the container.
 func (c *Container) State() specs.State {
 	return c.state.State()
@@ -31,8 +37,8 @@ func (c *Container) State() specs.State {
 
 // CreateContainer creates a new container using the container filesystem, id, and bundle path.
 // It reads the container's specification, prepares it, creates the container, initializes it, and updates its state.
-// Returns the created container
-func CreateContainer(fs *FS, id, bundle string) (c *Container, err error) {
+// Returns the created container, which is also returned with an error if its init process was started, so it can be destroyed.
+func CreateContainer(fs *FS, id, bundle string, opts ProcessOpts) (c *Container, err error) {
 	var spec specs.Spec
 	if err = util.ReadJsonFile(path.Join(bundle, model.OciSpecFileName), &spec); err != nil {
 		return nil, err
@@ -41,22 +47,29 @@ func CreateContainer(fs *FS, id, bundle string) (c *Container, err error) {
 	PrepareSpec(&spec, bundle)
 
 	// Create the container using the confs, ID, bundle, and prepared specification
-	c, err = fs.Create(id, bundle, spec)
+	c, err = fs.Create(id, bundle, spec, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	// Initialize the container and retrieve its process ID
 	pid, err := c.Init()
-	if err != nil {
+	if pid <= 0 {
+		// the init process isn't running, so the container is removed instead of being returned
+		cgroup, _ := cgroupManager(id, &c.config)
+		fs.removeCreated(fs.stateDir(id), cgroup)
 		return nil, err
 	}
 
-	// Set the container's process ID and update its state to "Created"
+	// Set the container's process ID and update its state to "Created".
+	// The state is updated even if the init process failed after it was started, so it can be killed.
 	c.state.SetPid(pid)
 	c.state.SetStatus(specs.StateCreated)
-	if err = c.state.UpdateState(); err != nil {
-		return nil, err
+	if updateErr := c.state.UpdateState(); err == nil {
+		err = updateErr
+	}
+	if err != nil {
+		return c, err
 	}
 
 	err = oci.InvokeHooks(spec.Hooks, oci.HookCreateRuntime)
@@ -0,0 +1,145 @@
+package libcontainer
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"roci/pkg/libcontainer/events"
+	"roci/pkg/logger"
+	"roci/pkg/model"
+	"roci/pkg/procfs"
+	"time"
+)
+
+// statePollInterval is the interval in which the state of the container is checked for changes
+const statePollInterval = 500 * time.Millisecond
+
+// Stats returns the resource usage of the container with the specified ID.
+// The statistics are read from the container cgroup, without cgroup v2 they are
+// summed up from the procfs entries of the container processes.
+func (r *FS) Stats(id string) (stats *events.Stats, err error) {
+	state, err := r.State(id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status == specs.StateStopped {
+		return nil, model.ErrNotRunning
+	}
+
+	spec, err := r.loadSpec(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cgroup, err := cgroupManager(id, spec)
+	if err != nil {
+		return nil, err
+	}
+	if cgroup != nil {
+		s, err := cgroup.Stats()
+		if err != nil {
+			return nil, err
+		}
+		return events.FromCgroup(s), nil
+	}
+
+	pids, err := r.Pids(id)
+	if err != nil {
+		return nil, err
+	}
+	return events.FromProcfs(procfs.Root, pids)
+}
+
+// Events streams the events of the container with the specified ID.
+// Stats are sent every interval, OOM kills and state changes as soon as they are noticed.
+// The channel is closed after the container stopped or when done is closed.
+func (r *FS) Events(id string, interval time.Duration, done <-chan struct{}) (<-chan events.Event, error) {
+	state, err := r.State(id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Status == specs.StateStopped {
+		return nil, model.ErrNotRunning
+	}
+
+	spec, err := r.loadSpec(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		log = logger.Log().Named("events")
+		oom <-chan struct{}
+	)
+	cgroup, err := cgroupManager(id, spec)
+	if err != nil {
+		return nil, err
+	}
+	if cgroup != nil {
+		// the memory controller might not be enabled for the cgroup, the other events are sent anyway
+		if oom, err = cgroup.NotifyOOM(done); err != nil {
+			log.Warn("oom events are not available", zap.Error(err))
+			oom = nil
+		}
+	} else {
+		log.Warn("oom events require cgroup v2")
+	}
+
+	ch := make(chan events.Event)
+	go func() {
+		defer close(ch)
+
+		statsTicker := time.NewTicker(interval)
+		defer statsTicker.Stop()
+		stateTicker := time.NewTicker(statePollInterval)
+		defer stateTicker.Stop()
+
+		send := func(event events.Event) bool {
+			select {
+			case ch <- event:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		status := state.Status
+		for {
+			var event events.Event
+			select {
+			case <-done:
+				return
+			case _, ok := <-oom:
+				if !ok {
+					oom = nil
+					continue
+				}
+				event = events.Event{Type: events.TypeOOM, ID: id}
+			case <-statsTicker.C:
+				stats, err := r.Stats(id)
+				if err != nil {
+					log.Warn("failed to read stats", zap.String("containerId", id), zap.Error(err))
+					continue
+				}
+				event = events.Event{Type: events.TypeStats, ID: id, Data: stats}
+			case <-stateTicker.C:
+				current, err := r.State(id)
+				if err != nil {
+					// the container was removed
+					log.Debug("failed to read state", zap.String("containerId", id), zap.Error(err))
+					return
+				}
+				if current.Status == status {
+					continue
+				}
+				status = current.Status
+				event = events.Event{Type: events.TypeState, ID: id, Data: events.State{Status: string(status)}}
+			}
+
+			if !send(event) || status == specs.StateStopped {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
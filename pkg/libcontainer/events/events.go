@@ -0,0 +1,200 @@
+// Package events defines the container events emitted by the events command.
+// The json format is compatible with the events of runc.
+package events
+
+import (
+	"errors"
+	"math"
+	"os"
+	"roci/pkg/libcontainer/cgroups"
+	"roci/pkg/procfs"
+	"time"
+)
+
+// Event types
+const (
+	TypeStats = "stats"
+	TypeOOM   = "oom"
+	TypeState = "state"
+)
+
+// Event is a single event of a container
+type Event struct {
+	Type string      `json:"type"`
+	ID   string      `json:"id"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// State is the data of a state event
+type State struct {
+	Status string `json:"status"`
+}
+
+// Stats is the data of a stats event
+type Stats struct {
+	CPU    CPU    `json:"cpu"`
+	Memory Memory `json:"memory"`
+	Pids   Pids   `json:"pids"`
+	Blkio  Blkio  `json:"blkio"`
+}
+
+// CPU contains the cpu usage in nanoseconds and the throttling statistics
+type CPU struct {
+	Usage      CPUUsage   `json:"usage,omitempty"`
+	Throttling Throttling `json:"throttling,omitempty"`
+}
+
+type CPUUsage struct {
+	Total  uint64 `json:"total,omitempty"`
+	Kernel uint64 `json:"kernel"`
+	User   uint64 `json:"user"`
+}
+
+type Throttling struct {
+	Periods          uint64 `json:"periods,omitempty"`
+	ThrottledPeriods uint64 `json:"throttledPeriods,omitempty"`
+	ThrottledTime    uint64 `json:"throttledTime,omitempty"`
+}
+
+// Memory contains the memory usage in bytes, the swap entry contains memory and swap together
+type Memory struct {
+	Cache uint64            `json:"cache,omitempty"`
+	Usage MemoryEntry       `json:"usage,omitempty"`
+	Swap  MemoryEntry       `json:"swap,omitempty"`
+	Raw   map[string]uint64 `json:"raw,omitempty"`
+}
+
+type MemoryEntry struct {
+	Limit   uint64 `json:"limit"`
+	Usage   uint64 `json:"usage,omitempty"`
+	Max     uint64 `json:"max,omitempty"`
+	Failcnt uint64 `json:"failcnt"`
+}
+
+type Pids struct {
+	Current uint64 `json:"current,omitempty"`
+	Limit   uint64 `json:"limit,omitempty"`
+}
+
+type Blkio struct {
+	IoServiceBytesRecursive []BlkioEntry `json:"ioServiceBytesRecursive,omitempty"`
+	IoServicedRecursive     []BlkioEntry `json:"ioServicedRecursive,omitempty"`
+}
+
+type BlkioEntry struct {
+	Major uint64 `json:"major,omitempty"`
+	Minor uint64 `json:"minor,omitempty"`
+	Op    string `json:"op,omitempty"`
+	Value uint64 `json:"value,omitempty"`
+}
+
+// FromCgroup converts the statistics of the container cgroup.
+func FromCgroup(s *cgroups.Stats) *Stats {
+	stats := &Stats{
+		CPU: CPU{
+			Usage: CPUUsage{
+				Total:  s.CPU.Total,
+				Kernel: s.CPU.System,
+				User:   s.CPU.User,
+			},
+			Throttling: Throttling{
+				Periods:          s.CPU.Periods,
+				ThrottledPeriods: s.CPU.ThrottledPeriods,
+				ThrottledTime:    s.CPU.ThrottledTime,
+			},
+		},
+		Memory: Memory{
+			Cache: s.Memory.Raw["file"],
+			Usage: MemoryEntry{
+				Limit:   s.Memory.Limit,
+				Usage:   s.Memory.Usage,
+				Failcnt: s.Memory.MaxEvents,
+			},
+			Swap: MemoryEntry{
+				Limit: addLimits(s.Memory.Limit, s.Memory.SwapLimit),
+				Usage: s.Memory.Usage + s.Memory.SwapUsage,
+			},
+			Raw: s.Memory.Raw,
+		},
+		Pids: Pids{
+			Current: s.Pids.Current,
+			Limit:   s.Pids.Limit,
+		},
+	}
+
+	// runc reports an unlimited number of pids as 0
+	if stats.Pids.Limit == math.MaxUint64 {
+		stats.Pids.Limit = 0
+	}
+
+	for _, device := range s.IO {
+		stats.Blkio.add(device.Major, device.Minor, device.ReadBytes, device.WriteBytes, device.ReadIOs, device.WriteIOs)
+	}
+	return stats
+}
+
+// FromProcfs sums up the statistics of the processes with pids.
+// It is used for containers without cgroup, limits are unknown and not reported.
+// Processes that exited in the meantime are skipped.
+func FromProcfs(proc *procfs.FS, pids []int) (*Stats, error) {
+	stats := new(Stats)
+	var readBytes, writeBytes, readCalls, writeCalls uint64
+
+	for _, pid := range pids {
+		stat, err := proc.Stat(procfs.Pid(pid))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		status, err := proc.Status(procfs.Pid(pid))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		stats.CPU.Usage.User += ticksToNanoseconds(stat.UTime)
+		stats.CPU.Usage.Kernel += ticksToNanoseconds(stat.STime)
+		stats.Memory.Usage.Usage += status.VmRSS
+		stats.Pids.Current++
+
+		// reading the io of another process requires ptrace access, it is skipped if that isn't possible
+		if io, err := proc.IO(procfs.Pid(pid)); err == nil {
+			readBytes += io.ReadBytes
+			writeBytes += io.WriteBytes
+			readCalls += io.ReadCalls
+			writeCalls += io.WriteCalls
+		}
+	}
+
+	stats.CPU.Usage.Total = stats.CPU.Usage.User + stats.CPU.Usage.Kernel
+	stats.Blkio.add(0, 0, readBytes, writeBytes, readCalls, writeCalls)
+	return stats, nil
+}
+
+// add appends the entries of a single device in the format of the runc blkio statistics.
+func (b *Blkio) add(major, minor, readBytes, writeBytes, reads, writes uint64) {
+	entry := func(op string, value uint64) BlkioEntry {
+		return BlkioEntry{Major: major, Minor: minor, Op: op, Value: value}
+	}
+	b.IoServiceBytesRecursive = append(b.IoServiceBytesRecursive,
+		entry("Read", readBytes), entry("Write", writeBytes), entry("Total", readBytes+writeBytes))
+	b.IoServicedRecursive = append(b.IoServicedRecursive,
+		entry("Read", reads), entry("Write", writes), entry("Total", reads+writes))
+}
+
+// addLimits adds two limits, if one of them is unlimited the sum is unlimited as well.
+func addLimits(a, b uint64) uint64 {
+	if a == math.MaxUint64 || b == math.MaxUint64 {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// ticksToNanoseconds converts clock ticks of /proc/<pid>/stat into nanoseconds.
+func ticksToNanoseconds(ticks uint64) uint64 {
+	return ticks * uint64(time.Second/procfs.ClockTicks)
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"encoding/json"
+	"math"
+	"roci/pkg/libcontainer/cgroups"
+	"testing"
+)
+
+func TestFromCgroup(t *testing.T) {
+	s := &cgroups.Stats{
+		CPU: cgroups.CPUStats{Total: 3000, User: 2000, System: 1000},
+		Memory: cgroups.MemoryStats{
+			Usage:     4096,
+			Limit:     8192,
+			SwapUsage: 1024,
+			SwapLimit: math.MaxUint64,
+			Raw:       map[string]uint64{"file": 512},
+		},
+		Pids: cgroups.PidsStats{Current: 3, Limit: math.MaxUint64},
+		IO:   []cgroups.IOStats{{Major: 8, Minor: 0, ReadBytes: 10, WriteBytes: 20, ReadIOs: 1, WriteIOs: 2}},
+	}
+
+	stats := FromCgroup(s)
+
+	if stats.CPU.Usage != (CPUUsage{Total: 3000, Kernel: 1000, User: 2000}) {
+		t.Errorf("unexpected cpu usage: %+v", stats.CPU.Usage)
+	}
+	if stats.Memory.Cache != 512 || stats.Memory.Usage.Usage != 4096 || stats.Memory.Usage.Limit != 8192 {
+		t.Errorf("unexpected memory: %+v", stats.Memory)
+	}
+	if stats.Memory.Swap.Usage != 5120 || stats.Memory.Swap.Limit != math.MaxUint64 {
+		t.Errorf("unexpected swap: %+v", stats.Memory.Swap)
+	}
+	if stats.Pids != (Pids{Current: 3}) {
+		t.Errorf("unexpected pids: %+v", stats.Pids)
+	}
+
+	expected := []BlkioEntry{
+		{Major: 8, Op: "Read", Value: 10},
+		{Major: 8, Op: "Write", Value: 20},
+		{Major: 8, Op: "Total", Value: 30},
+	}
+	if len(stats.Blkio.IoServiceBytesRecursive) != len(expected) {
+		t.Fatalf("unexpected blkio: %+v", stats.Blkio)
+	}
+	for i, entry := range expected {
+		if stats.Blkio.IoServiceBytesRecursive[i] != entry {
+			t.Errorf("blkio entry %d, expected: %+v, actual: %+v", i, entry, stats.Blkio.IoServiceBytesRecursive[i])
+		}
+	}
+}
+
+func TestEvent_Json(t *testing.T) {
+	content, err := json.Marshal(Event{Type: TypeOOM, ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `{"type":"oom","id":"test"}` {
+		t.Errorf("unexpected json: %s", content)
+	}
+}
@@ -0,0 +1,68 @@
+package libcontainer
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"os"
+	"roci/pkg/libcontainer/initp"
+	"strconv"
+	"syscall"
+)
+
+// listenFdsStart is the first fd passed by systemd socket activation, see sd_listen_fds(3)
+const listenFdsStart = 3
+
+// inheritedFiles returns the files of the runtime that are inherited by the container process
+// starting at fd 3. The fds passed by systemd socket activation come first, LISTEN_FDS and
+// LISTEN_PID are added to the environment of the process for them. The preserveFds fds
+// following them are passed as well.
+func inheritedFiles(process *specs.Process, preserveFds int) (files []*os.File) {
+	listenFds := activationFds()
+	for fd := listenFdsStart; fd < listenFdsStart+listenFds; fd++ {
+		// the fds are not inherited by other children of the runtime, like hooks
+		syscall.CloseOnExec(fd)
+		files = append(files, os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd)))
+	}
+	if listenFds > 0 && process != nil {
+		// the pid of the container process is set by the init process, see initp.EnvListenPid
+		process.Env = append(process.Env, "LISTEN_FDS="+strconv.Itoa(listenFds), initp.EnvListenPid+"=")
+	}
+
+	base := listenFdsStart + listenFds
+	for fd := base; fd < base+preserveFds; fd++ {
+		files = append(files, os.NewFile(uintptr(fd), "PreserveFD:"+strconv.Itoa(fd)))
+	}
+	return files
+}
+
+// activationFds returns the number of fds passed to the runtime by systemd socket activation.
+// The fds are only meant for the runtime if LISTEN_PID matches its pid.
+func activationFds() int {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return 0
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// closeExecFrom marks every fd of the runtime starting at minFd as close-on-exec.
+// Go doesn't close inherited fds when a child is started, so fds the runtime inherited
+// from its caller would leak into the container otherwise. The inherited files below minFd
+// are kept, they are passed to the container process explicitly.
+func closeExecFrom(minFd int) error {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil || fd < minFd {
+			continue
+		}
+		syscall.CloseOnExec(fd)
+	}
+	return nil
+}
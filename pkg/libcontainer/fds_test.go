@@ -0,0 +1,65 @@
+package libcontainer
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestActivationFds(t *testing.T) {
+	tests := []struct {
+		name      string
+		listenPid string
+		listenFds string
+		expected  int
+	}{
+		{"not set", "", "", 0},
+		{"own pid", strconv.Itoa(os.Getpid()), "2", 2},
+		{"other pid", strconv.Itoa(os.Getpid() + 1), "2", 0},
+		{"invalid count", strconv.Itoa(os.Getpid()), "two", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", tt.listenPid)
+			t.Setenv("LISTEN_FDS", tt.listenFds)
+
+			if actual := activationFds(); actual != tt.expected {
+				t.Errorf("expected: %d, actual: %d", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCloseExecFrom(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	low, high := int(r.Fd()), int(w.Fd())
+	if low > high {
+		low, high = high, low
+	}
+	for _, fd := range []int{low, high} {
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_SETFD, 0); errno != 0 {
+			t.Fatal(errno)
+		}
+	}
+
+	if err = closeExecFrom(high); err != nil {
+		t.Fatal(err)
+	}
+	for fd, expected := range map[int]bool{low: false, high: true} {
+		flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFD, 0)
+		if errno != 0 {
+			t.Fatal(errno)
+		}
+		if actual := flags&syscall.FD_CLOEXEC != 0; actual != expected {
+			t.Errorf("fd %d, expected close-on-exec: %v, actual: %v", fd, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package initp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"roci/pkg/logger"
+	"roci/pkg/model"
+	"roci/pkg/procfs"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prCapAmbientRaise = 2 // PR_CAP_AMBIENT_RAISE
+
+	// capSysAdmin is the number of CAP_SYS_ADMIN
+	capSysAdmin = 21
+
+	// linuxCapabilityVersion3 is the version of the capset(2) header for 64-bit capability sets
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+// capabilities are the capability sets of the container process as bit masks.
+type capabilities struct {
+	bounding, effective, permitted, inheritable, ambient uint64
+	lastCap                                              int
+}
+
+// capHeader and capData are the arguments of capset(2)
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective, permitted, inheritable uint32
+}
+
+// newCapabilities converts the capability sets of the spec into bit masks.
+// It returns nil if the spec has no capabilities, the process keeps the capabilities of the runtime then.
+// Unknown names are reported, capabilities that are unknown to the kernel are skipped with a warning.
+func newCapabilities(spec *specs.LinuxCapabilities) (*capabilities, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	lastCap, err := procfs.Root.CapLastCap()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &capabilities{lastCap: lastCap}
+	var errs []error
+	for _, set := range []struct {
+		name  string
+		mask  *uint64
+		names []string
+	}{
+		{"bounding", &c.bounding, spec.Bounding},
+		{"effective", &c.effective, spec.Effective},
+		{"permitted", &c.permitted, spec.Permitted},
+		{"inheritable", &c.inheritable, spec.Inheritable},
+		{"ambient", &c.ambient, spec.Ambient},
+	} {
+		numbers, err := model.Capabilities(set.names)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v set: %w", set.name, err))
+			continue
+		}
+		for _, number := range numbers {
+			if number > lastCap {
+				logger.Log().Warn("capability is not supported by the kernel", zap.Int("capability", number))
+				continue
+			}
+			*set.mask |= 1 << number
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// dropBounding drops the capabilities that are not in the bounding set.
+// It has to be called before the user is changed, because dropping them requires CAP_SETPCAP.
+func (c *capabilities) dropBounding() error {
+	if c == nil {
+		return nil
+	}
+	for capability := 0; capability <= c.lastCap; capability++ {
+		if c.bounding&(1<<capability) != 0 {
+			continue
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, uintptr(capability), 0); errno != 0 {
+			return fmt.Errorf("failed to drop capability %d from bounding set: %w", capability, errno)
+		}
+	}
+	return nil
+}
+
+// apply sets the effective, permitted and inheritable sets and raises the ambient capabilities.
+// The permitted capabilities of a non-root user are kept by the keep capabilities flag of setUser.
+func (c *capabilities) apply() error {
+	if c == nil {
+		return nil
+	}
+	header := capHeader{version: linuxCapabilityVersion3}
+	data := [2]capData{
+		{effective: uint32(c.effective), permitted: uint32(c.permitted), inheritable: uint32(c.inheritable)},
+		{effective: uint32(c.effective >> 32), permitted: uint32(c.permitted >> 32), inheritable: uint32(c.inheritable >> 32)},
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to set capabilities: %w", errno)
+	}
+
+	// ambient capabilities have to be permitted and inheritable
+	for capability := 0; capability <= c.lastCap; capability++ {
+		if c.ambient&(1<<capability) == 0 {
+			continue
+		}
+		if _, _, errno = syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientRaise, uintptr(capability), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("failed to raise ambient capability %d: %w", capability, errno)
+		}
+	}
+	return nil
+}
+
+// raised returns a copy of the capabilities with the capability in the effective and permitted set.
+func (c *capabilities) raised(capability int) *capabilities {
+	r := *c
+	r.effective |= 1 << capability
+	r.permitted |= 1 << capability
+	return &r
+}
+
+// raiseEffective raises the capability in the effective set of the calling thread, it has to be permitted.
+func raiseEffective(capability int) error {
+	header := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	_, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to get capabilities: %w", errno)
+	}
+	data[capability/32].effective |= 1 << (capability % 32)
+	_, _, errno = syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to raise capability %d: %w", capability, errno)
+	}
+	return nil
+}
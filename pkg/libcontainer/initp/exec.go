@@ -0,0 +1,366 @@
+package initp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"roci/pkg/libcontainer/cgroups"
+	"roci/pkg/libcontainer/console"
+	"roci/pkg/libcontainer/nsenter"
+	"roci/pkg/libcontainer/seccomp"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// EnvExecSocket is the environment variable that contains the fd of the socket to the runtime of an exec
+const EnvExecSocket = "_ROCI_EXEC_SOCKET"
+
+// ExecOpts contains the options the runtime passes to an exec process.
+type ExecOpts struct {
+	// ConsoleSocket receives the master of the process pty, if the process has a terminal
+	ConsoleSocket *os.File
+
+	// SeccompListener receives the notify fd of the seccomp filter, if the filter notifies
+	SeccompListener *os.File
+}
+
+// execConfig is sent by the runtime to the exec helper after the helper was moved into the cgroup.
+type execConfig struct {
+	// Spec is the spec of the container with the process of the exec
+	Spec specs.Spec `json:"spec"`
+
+	// State is the state of the container with the pid of the exec process, it is sent to the seccomp listener
+	State specs.State `json:"state"`
+}
+
+// ExecProcess is an additional process that is executed inside the namespaces of a running container.
+// The runtime starts a helper, roci re-executed with the exec-init command, that joins the namespaces
+// of the init process before the go runtime starts (see nsenter). The helper sets up the process like
+// the init process and executes it.
+type ExecProcess struct {
+	cmd    *exec.Cmd
+	config execConfig
+	cgroup *cgroups.Manager
+
+	// socket to the helper, the other end is closed by the exec of the process
+	socket *os.File
+
+	// pid namespace of the init process, empty without one
+	pidNamespace string
+
+	// files of the helper, the runtime closes its copies after the start
+	files []*os.File
+}
+
+// NewExecProcess prepares the process for execution inside the namespaces of the init process of state.
+// Only the namespaces listed in the spec are joined. If cgroup is not nil, the process is moved into it
+// before the process is set up.
+func NewExecProcess(state specs.State, spec *specs.Spec, process *specs.Process, cgroup *cgroups.Manager, opts ExecOpts) (_ *ExecProcess, err error) {
+	if process == nil || len(process.Args) == 0 {
+		return nil, fmt.Errorf("process args must not be empty")
+	}
+
+	e := &ExecProcess{cgroup: cgroup}
+	for _, f := range []*os.File{opts.ConsoleSocket, opts.SeccompListener} {
+		if f != nil {
+			e.files = append(e.files, f)
+		}
+	}
+	defer func() {
+		if err != nil {
+			e.closeFiles()
+			if e.socket != nil {
+				e.socket.Close()
+			}
+		}
+	}()
+
+	executablePath, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	e.socket = os.NewFile(uintptr(fds[0]), "exec-socket")
+	helperSocket := os.NewFile(uintptr(fds[1]), "exec-socket")
+	e.files = append(e.files, helperSocket)
+
+	e.cmd = exec.Command(executablePath, "exec-init", state.ID)
+	e.cmd.Stdin = os.Stdin
+	e.cmd.Stdout = os.Stdout
+	e.cmd.Stderr = os.Stderr
+	e.cmd.Env = os.Environ()
+	e.cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	e.addFile(EnvExecSocket, helperSocket)
+	if opts.ConsoleSocket != nil {
+		e.addFile(console.EnvSocketFd, opts.ConsoleSocket)
+	}
+	if opts.SeccompListener != nil {
+		e.addFile(seccomp.EnvListenerFd, opts.SeccompListener)
+	}
+
+	if err = e.prepareNamespaces(procfs.Pid(state.Pid), spec); err != nil {
+		return nil, err
+	}
+
+	e.config = execConfig{Spec: *spec, State: state}
+	e.config.Spec.Process = process
+	return e, nil
+}
+
+// addFile passes f to the helper in the fd of the environment variable env.
+func (e *ExecProcess) addFile(env string, f *os.File) {
+	e.cmd.ExtraFiles = append(e.cmd.ExtraFiles, f)
+	e.cmd.Env = append(e.cmd.Env, fmt.Sprintf("%v=%d", env, 2+len(e.cmd.ExtraFiles)))
+}
+
+// prepareNamespaces passes the namespaces of the init process with pid and its root to the helper.
+// The user namespace is joined first, so the helper has the privileges to join the namespaces it owns.
+// setns of a pid namespace only changes the namespace of the children, so it's joined by the runtime.
+func (e *ExecProcess) prepareNamespaces(pid procfs.Pid, spec *specs.Spec) error {
+	var namespaces []*os.File
+	if spec.Linux != nil {
+		types := make([]specs.LinuxNamespaceType, 0, len(spec.Linux.Namespaces))
+		for _, ns := range spec.Linux.Namespaces {
+			types = append(types, ns.Type)
+		}
+		slices.SortStableFunc(types, func(a, b specs.LinuxNamespaceType) int {
+			return isUserNamespace(b) - isUserNamespace(a)
+		})
+
+		for _, nsType := range types {
+			if nsType == specs.PIDNamespace {
+				e.pidNamespace = procfs.Root.NsPath(pid, nsType)
+				continue
+			}
+			f, err := procfs.OpenNamespace(procfs.Root.NsPath(pid, nsType), nsType)
+			if err != nil {
+				return err
+			}
+			e.files = append(e.files, f)
+			namespaces = append(namespaces, f)
+		}
+	}
+
+	// the helper changes its root to the one of the init process, which isn't the root of the
+	// mount namespace if the rootfs was changed with chroot
+	root, err := os.OpenFile(procfs.Root.RootPath(pid), syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	e.files = append(e.files, root)
+
+	nsenter.Configure(e.cmd, namespaces, root)
+	return nil
+}
+
+// isUserNamespace returns 1 for the user namespace type, so it's sorted first.
+func isUserNamespace(nsType specs.LinuxNamespaceType) int {
+	if nsType == specs.UserNamespace {
+		return 1
+	}
+	return 0
+}
+
+// Start starts the helper, moves it into the cgroup and sends it the process.
+// It returns the process ID (pid) of the started process after the process was executed.
+func (e *ExecProcess) Start() (pid int, err error) {
+	defer e.socket.Close()
+	err = e.startCmd()
+	// the helper has its own copies of the files
+	e.closeFiles()
+	if err != nil {
+		return -1, err
+	}
+	pid = e.cmd.Process.Pid
+
+	// The helper blocks until it received its config, so it is moved into the cgroup
+	// before it runs any code of the process.
+	if e.cgroup != nil {
+		logger.Log().Debug("moving exec process into cgroup", zap.String("cgroup", e.cgroup.Path()))
+		if err = e.cgroup.Apply(pid); err != nil {
+			e.kill()
+			return -1, err
+		}
+	}
+
+	e.config.State.Pid = pid
+	if err = json.NewEncoder(e.socket).Encode(e.config); err != nil {
+		e.kill()
+		return -1, err
+	}
+
+	// The socket of the helper is closed by the exec of the process, otherwise the helper reports why it failed
+	msg, err := io.ReadAll(e.socket)
+	if err == nil && len(msg) > 0 {
+		err = errors.New(string(msg))
+	}
+	if err != nil {
+		e.kill()
+		return -1, err
+	}
+	return pid, nil
+}
+
+// startCmd starts the helper. The pid namespace is joined by a locked thread of the runtime, so the helper
+// is started inside of it.
+func (e *ExecProcess) startCmd() error {
+	if e.pidNamespace == "" {
+		return e.cmd.Start()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		// setns only changes the namespaces of the calling thread. The thread is never unlocked,
+		// so it's terminated by the go runtime after the process is started instead of being reused.
+		runtime.LockOSThread()
+		logger.Log().Debug("joining namespace", zap.Any("type", specs.PIDNamespace), zap.String("path", e.pidNamespace))
+		if err := procfs.JoinNamespace(e.pidNamespace, specs.PIDNamespace); err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- e.cmd.Start()
+	}()
+	return <-errCh
+}
+
+// kill kills the helper and waits for it.
+func (e *ExecProcess) kill() {
+	_ = e.cmd.Process.Kill()
+	_ = e.cmd.Wait()
+}
+
+func (e *ExecProcess) closeFiles() {
+	for _, f := range e.files {
+		f.Close()
+	}
+	e.files = nil
+}
+
+// Pid returns the process ID (pid) of the started process.
+func (e *ExecProcess) Pid() int {
+	if e.cmd.Process == nil {
+		return -1
+	}
+	return e.cmd.Process.Pid
+}
+
+// Wait waits for the process to exit and returns its exit status.
+// Processes terminated by a signal report 128 plus the signal number.
+func (e *ExecProcess) Wait() (status int, err error) {
+	return exitStatus(e.cmd.Wait())
+}
+
+// ExecInit runs in the helper of an exec, which already joined the namespaces and the root of the container.
+// It waits for the process from the runtime and sets it up like the init process sets up the entrypoint.
+// Errors are reported to the runtime over the socket.
+func ExecInit() (err error) {
+	// The capabilities are changed for the calling thread only, so it has to execute the process.
+	runtime.LockOSThread()
+
+	socket, err := inheritedSocket(EnvExecSocket, "exec-socket")
+	if err != nil {
+		return err
+	}
+	if socket == nil {
+		return fmt.Errorf("%v is not set", EnvExecSocket)
+	}
+	defer func() {
+		if err != nil {
+			_, _ = socket.WriteString(err.Error())
+		}
+	}()
+
+	if err = nsenter.Err(); err != nil {
+		return err
+	}
+
+	var config execConfig
+	if err = json.NewDecoder(socket).Decode(&config); err != nil {
+		return fmt.Errorf("failed to receive exec config: %w", err)
+	}
+	spec := config.Spec
+
+	cwd := spec.Process.Cwd
+	if cwd == "" {
+		cwd = "/"
+	}
+	if err = syscall.Chdir(cwd); err != nil {
+		return fmt.Errorf("failed to change dir to %v: %w", cwd, err)
+	}
+
+	// the executable is searched in the PATH of the process
+	spec.Process.Args[0], err = lookPathInRoot("/", spec.Process.Args[0], spec.Process.Env)
+	if err != nil {
+		return err
+	}
+
+	consoleSocket, err := inheritedSocket(console.EnvSocketFd, "console-socket")
+	if err != nil {
+		return err
+	}
+	if spec.Process.Terminal {
+		if err = setupConsole(consoleSocket, spec.Process.ConsoleSize); err != nil {
+			return err
+		}
+	}
+
+	listenerSocket, err := inheritedSocket(seccomp.EnvListenerFd, "seccomp-listener-socket")
+	if err != nil {
+		return err
+	}
+	var listener *seccompListener
+	if listenerSocket != nil {
+		listener = newListenerOf(listenerSocket, config.State, spec)
+	}
+
+	caps, err := newCapabilities(spec.Process.Capabilities)
+	if err != nil {
+		return err
+	}
+	filter, err := compileSeccomp(spec)
+	if err != nil {
+		return err
+	}
+	return finalizeProcess(spec, caps, filter, listener)
+}
+
+// lookPathInRoot searches for an executable named file in the PATH of env relative to root.
+// The returned path is relative to root.
+func lookPathInRoot(root, file string, env []string) (string, error) {
+	if strings.Contains(file, "/") {
+		return file, nil
+	}
+
+	path := defaultPath
+	for _, e := range env {
+		if v, ok := strings.CutPrefix(e, "PATH="); ok {
+			path = v
+		}
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		p := filepath.Join(dir, file)
+		info, err := os.Stat(filepath.Join(root, p))
+		if err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+			continue
+		}
+		return p, nil
+	}
+	return "", fmt.Errorf("%v: %w", file, exec.ErrNotFound)
+}
@@ -1,24 +1,42 @@
 package initp
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
 	"os"
 	"os/exec"
+	"roci/pkg/libcontainer/console"
 	"roci/pkg/libcontainer/ipc"
 	"roci/pkg/libcontainer/namespace"
+	"roci/pkg/libcontainer/nsenter"
 	"roci/pkg/libcontainer/rootfs"
+	"roci/pkg/libcontainer/seccomp"
 	"roci/pkg/logger"
+	"roci/pkg/model"
+	"roci/pkg/procfs"
+	"runtime"
+	"strconv"
 	"syscall"
 )
 
 func Init(stateDir string, spec specs.Spec) (err error) {
+	// Namespaces, the root and the working directory are changed for the calling thread only.
+	// The init has to stay on the same thread until the entrypoint is executed.
+	runtime.LockOSThread()
+
 	var (
 		log        = logger.Log()
 		rootfsPath = spec.Root.Path
 		wd, _      = os.Getwd()
 	)
+	// existing user and time namespaces were joined before the go runtime started
+	if err = nsenter.Err(); err != nil {
+		return err
+	}
+
 	log.Debug("change dir to rootfs", zap.String("rootfs", rootfsPath), zap.String("wd", wd))
 	err = syscall.Chdir(rootfsPath)
 	if err != nil {
@@ -26,8 +44,28 @@ func Init(stateDir string, spec specs.Spec) (err error) {
 	}
 	log.Debug("init started", zap.Int("pid", os.Getpid()))
 
+	// the console socket is inherited from the runtime, it is used after the rootfs is finalized
+	consoleSocket, err := inheritedSocket(console.EnvSocketFd, "console-socket")
+	if err != nil {
+		return err
+	}
+
+	// the seccomp listener socket as well, it receives the notify fd after the filter was loaded
+	listener, err := newSeccompListener(stateDir, spec)
+	if err != nil {
+		return err
+	}
+
 	log.Debug("create runtime.pipe client")
-	runtime, err := ipc.NewRuntimePipeWriter(stateDir)
+	runtimePipe, err := ipc.NewRuntimePipeWriter(stateDir)
+	if err != nil {
+		return err
+	}
+
+	// the init.pipe has to be opened before the rootfs is finalized, because the state dir
+	// is not reachable after the root was changed
+	log.Debug("create init.pipe listener")
+	pipe, err := ipc.NewInitPipeReader(stateDir)
 	if err != nil {
 		return err
 	}
@@ -35,14 +73,8 @@ func Init(stateDir string, spec specs.Spec) (err error) {
 	waitForStart := make(chan struct{})
 	go func() {
 		defer close(waitForStart)
-		log.Debug("create init.pipe listener")
-		pipe, err := ipc.NewInitPipeReader(stateDir)
-		if err != nil {
-			panic(err)
-		}
-
 		log.Debug("wait for start on pipe")
-		err = pipe.WaitForStart()
+		err := pipe.WaitForStart()
 		if err != nil {
 			panic(err)
 		}
@@ -51,8 +83,16 @@ func Init(stateDir string, spec specs.Spec) (err error) {
 		waitForStart <- struct{}{}
 	}()
 
+	// the procfs of the host is still mounted, the one of the container might not have been mounted at all
+	if spec.Process.OOMScoreAdj != nil {
+		log.Debug("set oom score adj", zap.Int("score", *spec.Process.OOMScoreAdj))
+		if err = procfs.Root.SetOOMScoreAdj(procfs.PidSelf, *spec.Process.OOMScoreAdj); err != nil {
+			return fmt.Errorf("failed to set oom_score_adj: %w", err)
+		}
+	}
+
 	log.Debug("prepare namespaces")
-	namespaces, err := namespace.From(runtime, spec)
+	namespaces, err := namespace.From(spec)
 	if err != nil {
 		return err
 	}
@@ -63,15 +103,26 @@ func Init(stateDir string, spec specs.Spec) (err error) {
 			log.Warn("namespace is not supported", zap.Any("type", ns.Type()))
 			continue
 		}
-		if ns.Type() == specs.PIDNamespace {
-			log.Debug("skipping pid namespace because it's already unshared (happened in runtime proc)")
+		if ns.Path() != "" && ns.Type() == specs.MountNamespace {
+			// the rootfs is looked up in the joined mount namespace
+			log.Debug("join namespace", zap.Any("type", ns.Type()), zap.String("path", ns.Path()))
+			if err = namespace.Join(ns); err != nil {
+				return err
+			}
 			continue
 		}
-
-		log.Debug("init namespace", zap.Int("i", i), zap.Any("ns", ns.Type()))
-		err = namespace.Unshare(ns)
-		if err != nil {
-			return err
+		if ns.Path() != "" {
+			log.Debug("skipping namespace because it was joined", zap.Any("type", ns.Type()), zap.String("path", ns.Path()))
+			continue
+		}
+		if createdByRuntime(ns.Type()) {
+			log.Debug("skipping unshare because it's already unshared (happened in runtime proc)", zap.Any("type", ns.Type()))
+		} else {
+			log.Debug("init namespace", zap.Int("i", i), zap.Any("ns", ns.Type()))
+			err = namespace.Unshare(ns)
+			if err != nil {
+				return err
+			}
 		}
 
 		log.Debug("finalizing namespace in rootfs", zap.Int("i", i), zap.Any("ns", ns.Type()))
@@ -81,14 +132,37 @@ func Init(stateDir string, spec specs.Spec) (err error) {
 		}
 	}
 
+	policy, err := mountPolicy()
+	if err != nil {
+		return err
+	}
 	log.Debug("prepare rootfs", zap.String("rootfs", rootfsPath))
-	err = rootfs.FinalizeRootfs(rootfsPath, &spec)
+	err = rootfs.FinalizeRootfs(rootfsPath, &spec, os.Getenv(EnvNoPivot) != "", policy)
+	if err != nil {
+		return err
+	}
+
+	if spec.Process.Terminal {
+		log.Debug("setup console")
+		if err = setupConsole(consoleSocket, spec.Process.ConsoleSize); err != nil {
+			return err
+		}
+	}
+
+	// the capabilities are checked before the container is ready, so invalid ones fail the creation
+	caps, err := newCapabilities(spec.Process.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	// the seccomp filter is compiled before the container is ready as well
+	filter, err := compileSeccomp(spec)
 	if err != nil {
 		return err
 	}
 
 	log.Debug("notify runtime that container is ready")
-	err = runtime.SendReady()
+	err = runtimePipe.SendReady()
 	if err != nil {
 		return err
 	}
@@ -96,8 +170,195 @@ func Init(stateDir string, spec specs.Spec) (err error) {
 	log.Debug("wait for runtime start signal")
 	<-waitForStart
 
+	setListenPid(spec.Process)
+	return finalizeProcess(spec, caps, filter, listener)
+}
+
+// setListenPid completes the LISTEN_PID of socket activation with the pid of the init process, which keeps
+// its pid when it executes the entrypoint. It's 1 if the init process is in a new pid namespace.
+func setListenPid(process *specs.Process) {
+	for i, env := range process.Env {
+		if env == EnvListenPid+"=" {
+			process.Env[i] = EnvListenPid + "=" + strconv.Itoa(os.Getpid())
+		}
+	}
+}
+
+// finalizeProcess applies the rlimits, the user, no_new_privileges, the seccomp filter and the capabilities
+// of the process of the spec and executes it. It's the last step of the init process and of an exec.
+func finalizeProcess(spec specs.Spec, caps *capabilities, filter *seccomp.Filter, listener *seccompListener) (err error) {
+	log := logger.Log()
+
+	// raising the hard limits requires CAP_SYS_RESOURCE, so they are set before the user is changed
+	log.Debug("set rlimits")
+	if err = setRlimits(spec.Process.Rlimits); err != nil {
+		return err
+	}
+
+	log.Debug("drop bounding capabilities")
+	if err = caps.dropBounding(); err != nil {
+		return err
+	}
+
+	// Without no_new_privileges loading the filter requires CAP_SYS_ADMIN. The permitted capabilities are
+	// kept when the user is changed, so it can be raised again to load the filter after the user was changed.
+	privilegedSeccomp := filter != nil && !spec.Process.NoNewPrivileges
+
+	log.Debug("set user", zap.Uint32("uid", spec.Process.User.UID), zap.Uint32("gid", spec.Process.User.GID))
+	if err = setUser(spec.Process.User, caps != nil || privilegedSeccomp); err != nil {
+		return err
+	}
+
+	if spec.Process.NoNewPrivileges {
+		log.Debug("set no new privileges")
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+			return fmt.Errorf("failed to set no_new_privileges: %w", errno)
+		}
+	}
+
+	// the entrypoint is looked up before the filter is loaded, so only the exec is filtered
+	arg0, args, env, err := Entrypoint(spec.Process)
+	if err != nil {
+		return err
+	}
+
+	// The capabilities are applied before the filter is loaded, because the filter may deny capset and prctl.
+	// CAP_SYS_ADMIN stays raised to load the filter, the exec doesn't keep the effective and permitted set.
+	if privilegedSeccomp {
+		if caps != nil {
+			caps = caps.raised(capSysAdmin)
+		} else if err = raiseEffective(capSysAdmin); err != nil {
+			return err
+		}
+	}
+	log.Debug("apply capabilities")
+	if err = caps.apply(); err != nil {
+		return err
+	}
+
+	// The filter is loaded as late as possible, so it filters few syscalls of the init.
+	log.Debug("load seccomp filter")
+	if err = loadSeccomp(filter, listener); err != nil {
+		return err
+	}
+
 	log.Debug("exec container entrypoint")
-	return execEntrypoint(spec)
+	return execEntrypoint(arg0, args, env)
+}
+
+const (
+	prSetNoNewPrivs      = 38 // PR_SET_NO_NEW_PRIVS
+	prCapAmbient         = 47 // PR_CAP_AMBIENT
+	prCapAmbientClearAll = 4  // PR_CAP_AMBIENT_CLEAR_ALL
+)
+
+// setRlimits sets the resource limits of the init process, they are inherited by the container process.
+func setRlimits(rlimits []specs.POSIXRlimit) error {
+	for _, rlimit := range rlimits {
+		resource, err := model.Rlimit(rlimit.Type)
+		if err != nil {
+			return err
+		}
+		if err = syscall.Setrlimit(resource, &syscall.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}); err != nil {
+			return fmt.Errorf("failed to set rlimit %v: %w", rlimit.Type, err)
+		}
+	}
+	return nil
+}
+
+// setUser changes the user of the init process to the user of the container process.
+// The ambient capabilities, passed by the runtime to set up the user namespace, are dropped before,
+// so the container process only gets the capabilities of its user or the ones of the spec.
+// With keepCaps the permitted capabilities survive the change to a non-root user, so the capabilities
+// of the spec can be applied afterward.
+// The supplementary groups are replaced by the additional gids, and the umask of the user is set.
+func setUser(user specs.User, keepCaps bool) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0)
+	if errno != 0 && errno != syscall.EINVAL {
+		// EINVAL: kernel without ambient capabilities
+		return fmt.Errorf("failed to clear ambient capabilities: %w", errno)
+	}
+
+	if keepCaps {
+		if _, _, errno = syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_KEEPCAPS, 1, 0); errno != 0 {
+			return fmt.Errorf("failed to keep capabilities: %w", errno)
+		}
+	}
+
+	gids := make([]int, len(user.AdditionalGids))
+	for i, gid := range user.AdditionalGids {
+		gids[i] = int(gid)
+	}
+	// setgroups is denied in user namespaces of unprivileged runtimes, the groups are only cleared if it's allowed
+	if err := syscall.Setgroups(gids); err != nil && (len(gids) > 0 || !errors.Is(err, syscall.EPERM)) {
+		return fmt.Errorf("failed to set additional gids: %w", err)
+	}
+
+	if err := syscall.Setgid(int(user.GID)); err != nil {
+		return fmt.Errorf("failed to set gid: %w", err)
+	}
+	if err := syscall.Setuid(int(user.UID)); err != nil {
+		return fmt.Errorf("failed to set uid: %w", err)
+	}
+
+	if user.Umask != nil {
+		syscall.Umask(int(*user.Umask))
+	}
+	return nil
+}
+
+// inheritedSocket returns the socket passed by the runtime in the fd of the environment variable
+// or nil if there is none. The socket is not inherited by the container process.
+func inheritedSocket(env, name string) (*os.File, error) {
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return nil, nil
+	}
+	_ = os.Unsetenv(env)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v: %w", env, err)
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+// mountPolicy returns the mount policy the runtime passed in the environment, without one no failed
+// mount is tolerated.
+func mountPolicy() (policy rootfs.MountPolicy, err error) {
+	value, ok := os.LookupEnv(EnvMountPolicy)
+	if !ok {
+		return policy, nil
+	}
+	if err = json.Unmarshal([]byte(value), &policy); err != nil {
+		return policy, fmt.Errorf("invalid %v: %w", EnvMountPolicy, err)
+	}
+	return policy, nil
+}
+
+// setupConsole allocates a pty inside the container and sends its master over the console socket.
+// The slave becomes the stdio and controlling terminal of the init process and so of the entrypoint.
+func setupConsole(socket *os.File, size *specs.Box) error {
+	if socket == nil {
+		return fmt.Errorf("process.terminal requires a console socket")
+	}
+	defer socket.Close()
+
+	master, slave, err := console.NewPty("/")
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	if err = console.SetSize(master, size); err != nil {
+		return fmt.Errorf("failed to set console size: %w", err)
+	}
+	if err = console.SendMaster(socket, master, slave.Name()); err != nil {
+		return err
+	}
+	return console.SetControllingTerminal(slave)
 }
 
 func Entrypoint(process *specs.Process) (bin string, args, env []string, err error) {
@@ -109,12 +370,9 @@ func Entrypoint(process *specs.Process) (bin string, args, env []string, err err
 	return process.Args[0], process.Args, process.Env, nil
 }
 
-func execEntrypoint(spec specs.Spec) (err error) {
-	arg0, args, env, err := Entrypoint(spec.Process)
-	if err != nil {
-		return err
-	}
-
+// execEntrypoint replaces the init process with the entrypoint of the container.
+// It's the last step of the init process, after the seccomp filter was loaded.
+func execEntrypoint(arg0 string, args, env []string) (err error) {
 	for {
 		err = syscall.Exec(arg0, args, env)
 		if !errors.Is(err, syscall.EINTR) {
@@ -2,61 +2,339 @@ package initp
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
 	"os"
 	"os/exec"
+	"roci/pkg/libcontainer/cgroups"
+	"roci/pkg/libcontainer/console"
 	"roci/pkg/libcontainer/ipc"
+	"roci/pkg/libcontainer/namespace"
+	"roci/pkg/libcontainer/network"
+	"roci/pkg/libcontainer/nsenter"
 	"roci/pkg/libcontainer/oci"
+	"roci/pkg/libcontainer/rootfs"
+	"roci/pkg/libcontainer/seccomp"
 	"roci/pkg/logger"
+	"roci/pkg/model"
 	"roci/pkg/procfs"
+	"runtime"
+	"slices"
 	"syscall"
 )
 
 type ProcessSpec = specs.Process
 
 type Process struct {
-	cmd      *exec.Cmd
-	stateDir string
-	hooks    *specs.Hooks
+	cmd           *exec.Cmd
+	process       *os.Process
+	stateDir      string
+	hooks         *specs.Hooks
+	cgroup        *cgroups.Manager
+	consoleSocket *os.File
+	listener      *os.File
+	exited        <-chan error
+
+	// id mappings of the user namespace, nil without user namespace
+	uidMappings, gidMappings []specs.LinuxIDMapping
+
+	// existing namespaces that are joined before the init process is started
+	join []namespace.Namespace
+
+	// files of the existing namespaces that the init process joins itself, they are closed after the start
+	files []*os.File
+
+	// pipe of the pid of the clone that creates the new namespaces, nil if the clone of cmd creates them
+	clonePid *os.File
+
+	// network attachment that connects the network namespace of the init process, nil without one
+	network network.Attachment
 }
 
-func NewInitProcess(rootfs, stateDir string, spec *specs.Spec) *Process {
-	cmd, err := prepareCmd(stateDir)
+// EnvNoPivot is the environment variable that tells the init process to use chroot instead of pivot_root
+const EnvNoPivot = "_ROCI_NO_PIVOT"
+
+// EnvListenPid is the environment variable of socket activation that contains the pid of the process the fds
+// are meant for. The runtime passes it without a value, the init process completes it with the pid of the
+// entrypoint, which is only known inside of its pid namespace.
+const EnvListenPid = "LISTEN_PID"
+
+// EnvMountPolicy is the environment variable that contains the mount policy of the runtime as json
+const EnvMountPolicy = "_ROCI_MOUNT_POLICY"
+
+// InitOpts contains the options the runtime passes to the init process.
+type InitOpts struct {
+	// ExtraFiles are inherited by the container process starting at fd 3
+	ExtraFiles []*os.File
+
+	// ConsoleSocket receives the master of the container pty, if the process has a terminal
+	ConsoleSocket *os.File
+
+	// SeccompListener receives the notify fd of the seccomp filter, if the filter notifies
+	SeccompListener *os.File
+
+	// NoPivot changes the root with chroot instead of pivot_root
+	NoPivot bool
+
+	// MountPolicy decides which failed mounts of the spec are tolerated
+	MountPolicy rootfs.MountPolicy
+
+	// Network connects the new network namespace of the container to the host, if it is not nil
+	Network network.Attachment
+}
+
+// NewInitProcess prepares the init process of a container.
+// If cgroup is not nil, the init process is moved into it before the namespaces are created.
+// The stdio of the runtime and the extra files of opts are inherited by the container process.
+func NewInitProcess(rootfs, stateDir string, spec *specs.Spec, cgroup *cgroups.Manager, opts InitOpts) (*Process, error) {
+	cmd, err := prepareCmd(stateDir, opts)
 	if err != nil {
-		panic(err) //TODO
+		return nil, err
 	}
-	return &Process{
-		stateDir: stateDir,
-		cmd:      cmd,
-		hooks:    spec.Hooks,
+	p := &Process{
+		stateDir:      stateDir,
+		cmd:           cmd,
+		hooks:         spec.Hooks,
+		cgroup:        cgroup,
+		consoleSocket: opts.ConsoleSocket,
+		listener:      opts.SeccompListener,
+		network:       opts.Network,
+	}
+	if hasUserNamespace(spec) {
+		if err = p.prepareUserNamespace(spec); err != nil {
+			return nil, err
+		}
 	}
+	if err = p.prepareNamespaces(spec); err != nil {
+		p.closeFiles()
+		return nil, err
+	}
+	return p, nil
+}
+
+// prepareNamespaces collects the namespaces of the spec with a path. Most of them are joined by the runtime,
+// because the init process is started in a new user namespace that has no privileges over them.
+// User and time namespaces can only be joined by a single-threaded process, they are joined by the init process
+// before the go runtime starts (see nsenter). If the init process joins a user namespace, it joins the other
+// namespaces as well, so the privileges in the user namespace apply to them. A mount namespace is joined by the
+// init process after it opened the files of the state dir.
+// A new network namespace is created by the clone, so the runtime can connect it by the pid of the init process.
+func (i *Process) prepareNamespaces(spec *specs.Spec) error {
+	if spec.Linux == nil {
+		return nil
+	}
+	namespaces, err := namespace.From(*spec)
+	if err != nil {
+		return err
+	}
+	joinsUser := slices.ContainsFunc(namespaces, func(ns namespace.Namespace) bool {
+		return ns.Type() == specs.UserNamespace && ns.Path() != ""
+	})
+
+	var enter []namespace.Namespace
+	for _, ns := range namespaces {
+		switch {
+		case ns.Path() == "":
+			if ns.Type() == specs.NetworkNamespace {
+				i.cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+			}
+		case ns.Type() == specs.MountNamespace:
+			// joined by the init process
+		case ns.Type() == specs.PIDNamespace:
+			// setns of a pid namespace only changes the namespace of the children
+			i.join = append(i.join, ns)
+			i.cmd.SysProcAttr.Cloneflags &^= syscall.CLONE_NEWPID
+		case joinsUser || ns.Type() == specs.TimeNamespace:
+			enter = append(enter, ns)
+		default:
+			i.join = append(i.join, ns)
+		}
+	}
+
+	// New pid and network namespaces have to be owned by the joined user namespace,
+	// so they are created by a clone of the init process after it joined the user namespace.
+	if flags := i.cmd.SysProcAttr.Cloneflags & (syscall.CLONE_NEWPID | syscall.CLONE_NEWNET); joinsUser && flags != 0 {
+		i.cmd.SysProcAttr.Cloneflags &^= flags
+		r, w, err := nsenter.Clone(i.cmd, flags)
+		if err != nil {
+			return err
+		}
+		i.clonePid = r
+		i.files = append(i.files, w)
+	}
+
+	// the namespaces are sorted by priority, so the user namespace is joined first
+	files := make([]*os.File, 0, len(enter))
+	for _, ns := range enter {
+		f, err := procfs.OpenNamespace(ns.Path(), ns.Type())
+		if err != nil {
+			return err
+		}
+		i.files = append(i.files, f)
+		files = append(files, f)
+	}
+	nsenter.Configure(i.cmd, files, nil)
+	return nil
+}
+
+// startCmd starts the init process. The existing namespaces are joined by a locked thread of the runtime,
+// so the init process inherits them. They are joined before the user namespace of the init process is
+// created by the clone, so joining them is checked against the privileges of the runtime.
+func (i *Process) startCmd() error {
+	if len(i.join) == 0 {
+		return i.cmd.Start()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		// setns only changes the namespaces of the calling thread. The thread is never unlocked,
+		// so it's terminated by the go runtime after the process is started instead of being reused.
+		runtime.LockOSThread()
+		for _, ns := range i.join {
+			logger.Log().Debug("joining namespace", zap.Any("type", ns.Type()), zap.String("path", ns.Path()))
+			if err := namespace.Join(ns); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		errCh <- i.cmd.Start()
+	}()
+	return <-errCh
+}
+
+// prepareUserNamespace creates the user namespace together with the pid namespace, because a multithreaded
+// process can't unshare it. The init process is unmapped until the runtime wrote the id mappings, so the
+// capabilities are passed as ambient capabilities, otherwise they would be dropped by the exec of the init.
+// Without mappings in the spec, root of the container is mapped to the user of the runtime.
+func (i *Process) prepareUserNamespace(spec *specs.Spec) error {
+	lastCap, err := procfs.Root.CapLastCap()
+	if err != nil {
+		return err
+	}
+	for c := 0; c <= lastCap; c++ {
+		i.cmd.SysProcAttr.AmbientCaps = append(i.cmd.SysProcAttr.AmbientCaps, uintptr(c))
+	}
+	i.cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+
+	i.uidMappings = spec.Linux.UIDMappings
+	if len(i.uidMappings) == 0 {
+		i.uidMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: uint32(os.Geteuid()), Size: 1}}
+	}
+	i.gidMappings = spec.Linux.GIDMappings
+	if len(i.gidMappings) == 0 {
+		i.gidMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: uint32(os.Getegid()), Size: 1}}
+	}
+	return nil
+}
+
+// mapIds writes the id mappings of the user namespace of the init process with pid.
+// Unprivileged runtimes are only allowed to write the gid mappings after setgroups was denied.
+func (i *Process) mapIds(pid int) error {
+	if err := procfs.Root.SetGroups(procfs.Pid(pid), os.Geteuid() == 0); err != nil {
+		return fmt.Errorf("failed to write setgroups: %w", err)
+	}
+	if err := procfs.Root.MapGids(procfs.Pid(pid), i.gidMappings); err != nil {
+		return fmt.Errorf("failed to map gids: %w", err)
+	}
+	if err := procfs.Root.MapUids(procfs.Pid(pid), i.uidMappings); err != nil {
+		return fmt.Errorf("failed to map uids: %w", err)
+	}
+	return nil
+}
+
+// createdByRuntime checks whether a new namespace of the type is created by the clone of the init process
+// instead of being unshared by the init process.
+func createdByRuntime(namespaceType specs.LinuxNamespaceType) bool {
+	switch namespaceType {
+	case specs.PIDNamespace, specs.UserNamespace, specs.NetworkNamespace:
+		return true
+	}
+	return false
+}
+
+// hasUserNamespace checks whether the spec contains a new user namespace.
+func hasUserNamespace(spec *specs.Spec) bool {
+	if spec.Linux == nil {
+		return false
+	}
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.UserNamespace && ns.Path == "" {
+			return true
+		}
+	}
+	return false
 }
 
 func (i *Process) Start() (pid int, err error) {
-	err = i.cmd.Start()
+	err = i.startCmd()
+	// the init process has its own copies of the sockets and namespace files
+	i.closeFiles()
+	if i.consoleSocket != nil {
+		i.consoleSocket.Close()
+	}
+	if i.listener != nil {
+		i.listener.Close()
+	}
 	if err != nil {
 		return -1, err
 	}
+	if i.process, err = i.initProcess(); err != nil {
+		return -1, err
+	}
+
+	// The init process blocks until the runtime pipe reader is opened,
+	// so it is moved into the cgroup before it starts to set up the container.
+	if i.cgroup != nil {
+		logger.Log().Debug("moving init process into cgroup", zap.String("cgroup", i.cgroup.Path()))
+		if err = i.cgroup.Apply(i.process.Pid); err != nil {
+			_ = i.process.Kill()
+			return -1, err
+		}
+	}
+
+	// The id mappings are written before the runtime pipe is opened as well,
+	// so the init process is mapped before it starts to set up the container.
+	if i.uidMappings != nil {
+		logger.Log().Debug("writing id mappings of init process")
+		if err = i.mapIds(i.process.Pid); err != nil {
+			_ = i.process.Kill()
+			return -1, err
+		}
+	}
+
+	// The network namespace is connected before the init process sets up the container as well
+	if i.network != nil {
+		logger.Log().Debug("attaching network")
+		if err = i.network.Attach(i.process.Pid); err != nil {
+			_ = i.process.Kill()
+			return -1, err
+		}
+	}
 
-	waitForReady, pipe, err := ipc.NewRuntimePipeReader(context.Background(), i.stateDir, procfs.Root)
+	waitForReady, pipe, err := ipc.NewRuntimePipeReader(context.Background(), i.stateDir)
 	if err != nil {
+		_ = i.process.Kill()
 		return -1, err
 	}
 	defer pipe.Close()
 
 	logger.Log().Debug("waiting for init process")
+	i.exited = i.wait()
 	select {
 	case <-waitForReady:
 		logger.Log().Debug("received ready")
 		break
-	case err := <-i.wait():
+	case err := <-i.exited:
 		logger.Log().Debug("init process stopped")
 		if err != nil {
 			return -1, err
 		}
 	}
 
-	pid = i.cmd.Process.Pid
+	pid = i.process.Pid
 	err = oci.InvokeHooks(i.hooks, oci.HookCreateRuntime)
 	if err != nil {
 		return pid, err
@@ -70,25 +348,106 @@ func (i *Process) Start() (pid int, err error) {
 	return pid, nil
 }
 
+// initProcess returns the init process. If the new namespaces are created by a clone of the started process,
+// the clone is the init process and the started process exits after it reported the pid of the clone.
+func (i *Process) initProcess() (*os.Process, error) {
+	if i.clonePid == nil {
+		return i.cmd.Process, nil
+	}
+	defer i.clonePid.Close()
+
+	pid, err := nsenter.ReadPid(i.clonePid)
+	if err != nil {
+		_ = i.cmd.Process.Kill()
+		_ = i.cmd.Wait()
+		return nil, fmt.Errorf("failed to read pid of init process: %w", err)
+	}
+	if pid == 0 {
+		// the init process failed before the clone and reports the error itself
+		return i.cmd.Process, nil
+	}
+	if err = i.cmd.Wait(); err != nil {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+		return nil, fmt.Errorf("failed to clone namespaces of init process: %w", err)
+	}
+	return os.FindProcess(pid)
+}
+
+func (i *Process) closeFiles() {
+	for _, f := range i.files {
+		f.Close()
+	}
+	i.files = nil
+}
+
+// Wait waits for the init process to exit and returns its exit status.
+// Only the runtime process that started the init process is able to wait for it.
+func (i *Process) Wait() (status int, err error) {
+	if i.exited == nil {
+		return -1, fmt.Errorf("init process not started")
+	}
+	return exitStatus(<-i.exited)
+}
+
 func (i *Process) wait() <-chan error {
 	ch := make(chan error, 1)
 	go func() {
 		defer close(ch)
 
-		ch <- i.cmd.Wait()
+		state, err := i.process.Wait()
+		if err == nil && !state.Success() {
+			err = &exec.ExitError{ProcessState: state}
+		}
+		ch <- err
 	}()
 	return ch
 }
 
-func prepareCmd(stateDir string) (*exec.Cmd, error) {
+// exitStatus converts the error returned by exec.Cmd.Wait into an exit status.
+func exitStatus(err error) (status int, _ error) {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return model.ExitStatus(exitErr.Sys().(syscall.WaitStatus)), nil
+	case err != nil:
+		return -1, err
+	default:
+		return 0, nil
+	}
+}
+
+func prepareCmd(stateDir string, opts InitOpts) (*exec.Cmd, error) {
 	executablePath, err := os.Executable()
 	if err != nil {
 		return nil, err
 	}
 
+	// the files are passed as is, so the container process keeps them after the runtime exited
 	cmd := exec.Command(executablePath, "init", stateDir)
+	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append([]*os.File(nil), opts.ExtraFiles...)
+	cmd.Env = os.Environ()
+	if opts.ConsoleSocket != nil {
+		// the socket follows the extra files, so it doesn't take an fd number of the container process
+		cmd.ExtraFiles = append(cmd.ExtraFiles, opts.ConsoleSocket)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%d", console.EnvSocketFd, 2+len(cmd.ExtraFiles)))
+	}
+	if opts.SeccompListener != nil {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, opts.SeccompListener)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%d", seccomp.EnvListenerFd, 2+len(cmd.ExtraFiles)))
+	}
+	if opts.NoPivot {
+		cmd.Env = append(cmd.Env, EnvNoPivot+"=1")
+	}
+	if len(opts.MountPolicy.Tolerate) > 0 {
+		policy, err := json.Marshal(opts.MountPolicy)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%s", EnvMountPolicy, policy))
+	}
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setsid: true,
 		//pid namespace is unshared here because unshare can't move to current process to pid 1
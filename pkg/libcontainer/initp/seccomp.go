@@ -0,0 +1,79 @@
+package initp
+
+import (
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"os"
+	"path"
+	"roci/pkg/libcontainer/seccomp"
+	"roci/pkg/model"
+	"roci/pkg/procfs"
+	"roci/pkg/util"
+)
+
+// seccompListener receives the notify fd of the seccomp filter.
+type seccompListener struct {
+	socket *os.File
+	state  specs.ContainerProcessState
+}
+
+// newSeccompListener returns the listener socket inherited from the runtime with the state of the container
+// process, or nil if there is none. The pid of the state is read from the procfs of the host, so it has to be
+// called before the rootfs is finalized.
+func newSeccompListener(stateDir string, spec specs.Spec) (*seccompListener, error) {
+	socket, err := inheritedSocket(seccomp.EnvListenerFd, "seccomp-listener-socket")
+	if err != nil || socket == nil {
+		return nil, err
+	}
+
+	var state specs.State
+	if err = util.ReadJsonFile(path.Join(stateDir, model.OciStateFileName), &state); err != nil {
+		return nil, err
+	}
+	pid, err := procfs.Root.Self()
+	if err != nil {
+		return nil, err
+	}
+	state.Pid = int(pid)
+	return newListenerOf(socket, state, spec), nil
+}
+
+// newListenerOf returns the listener of socket, which is sent the state of the container process with pid of state.
+func newListenerOf(socket *os.File, state specs.State, spec specs.Spec) *seccompListener {
+	// the filter is loaded after the container was started
+	state.Status = specs.StateRunning
+	state.Annotations = spec.Annotations
+
+	return &seccompListener{
+		socket: socket,
+		state: specs.ContainerProcessState{
+			Version:  state.Version,
+			Pid:      state.Pid,
+			Metadata: spec.Linux.Seccomp.ListenerMetadata,
+			State:    state,
+		},
+	}
+}
+
+// compileSeccomp compiles the seccomp filter of the spec, it returns nil if the spec has none.
+func compileSeccomp(spec specs.Spec) (*seccomp.Filter, error) {
+	if spec.Linux == nil {
+		return nil, nil
+	}
+	return seccomp.Compile(spec.Linux.Seccomp)
+}
+
+// loadSeccomp loads the filter for the calling thread and sends its notify fd to the listener.
+func loadSeccomp(filter *seccomp.Filter, listener *seccompListener) error {
+	notifyFd, err := filter.Load()
+	if err != nil || notifyFd == nil {
+		return err
+	}
+	defer notifyFd.Close()
+
+	if listener == nil {
+		return fmt.Errorf("seccomp filter notifies without listener socket")
+	}
+	defer listener.socket.Close()
+	return seccomp.SendListener(listener.socket, notifyFd, listener.state)
+}
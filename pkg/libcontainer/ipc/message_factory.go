@@ -14,19 +14,3 @@ func NewMessageReady() proto.Message {
 func NewMessageStart() proto.Message {
 	return &pb.FromRuntime{Payload: &pb.FromRuntime_Start{Start: &pb.Start{}}}
 }
-
-// NewMessageUidMapping creates new UidMapping call message
-func NewMessageUidMapping(insideId, outsideId uint32) proto.Message {
-	return &pb.FromInit{Payload: &pb.FromInit_MapUid{MapUid: &pb.IdMapping{
-		InsideId:  insideId,
-		OutsideId: outsideId,
-	}}}
-}
-
-// NewMessageGidMapping creates new GidMapping call message
-func NewMessageGidMapping(insideId, outsideId uint32) proto.Message {
-	return &pb.FromInit{Payload: &pb.FromInit_MapGid{MapGid: &pb.IdMapping{
-		InsideId:  insideId,
-		OutsideId: outsideId,
-	}}}
-}
@@ -19,10 +19,20 @@ func createPipe(stateDir, pipeName string) error {
 	return syscall.Mkfifo(fifoPath, 0666)
 }
 
-// openPipeReader opens the fifo special file with read access inside stateDir with pipeName
+// openPipeReader opens the fifo special file with read access inside stateDir with pipeName.
+// The fifo is opened non-blocking, so the caller doesn't have to wait for a writer,
+// and switched back to blocking reads afterward.
 func openPipeReader(stateDir, pipeName string) (*os.File, error) {
 	fifoPath := filepath.Join(stateDir, pipeName)
-	return os.OpenFile(fifoPath, os.O_RDONLY|os.O_CREATE, os.ModeNamedPipe)
+	fd, err := syscall.Open(fifoPath, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.SetNonblock(fd, false); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), fifoPath), nil
 }
 
 // openPipeWriter opens the fifo special file with write access inside stateDir with pipeName
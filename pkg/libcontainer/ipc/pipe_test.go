@@ -3,14 +3,12 @@ package ipc
 import (
 	"bytes"
 	"context"
+	"google.golang.org/protobuf/proto"
 	pb "roci/proto"
 	"testing"
 )
 
-var testMessage = &pb.IdMapping{
-	InsideId:  42,
-	OutsideId: 69,
-}
+var testMessage = &pb.FromInit{Payload: &pb.FromInit_Ready{Ready: &pb.Ready{}}}
 
 func TestListen(t *testing.T) {
 	var buf bytes.Buffer
@@ -25,18 +23,14 @@ func TestListen(t *testing.T) {
 		t.Log("written message into buffer")
 	}()
 
-	ch := Listen(context.TODO(), &buf, func() *pb.IdMapping {
-		return new(pb.IdMapping)
+	ch := Listen(context.TODO(), &buf, func() *pb.FromInit {
+		return new(pb.FromInit)
 	})
 
 	actual := <-ch
 	t.Log("received message")
 
-	if actual.InsideId != expected.InsideId {
-		t.Error()
-		t.FailNow()
-	}
-	if actual.OutsideId != expected.OutsideId {
+	if !proto.Equal(actual, expected) {
 		t.FailNow()
 	}
 
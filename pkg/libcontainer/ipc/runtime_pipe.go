@@ -2,11 +2,9 @@ package ipc
 
 import (
 	"context"
-	"go.uber.org/zap"
 	"io"
 	"os"
 	"roci/pkg/logger"
-	"roci/pkg/procfs"
 	pb "roci/proto"
 )
 
@@ -15,14 +13,11 @@ const runtimePipeFileName = "runtime.pipe"
 // RuntimePipeWriter is the runtime.pipe interface for the init process
 type RuntimePipeWriter interface {
 	SendReady() error
-
-	procfs.IdMapper
 }
 
 // RuntimePipeR is the cmd process implementation for the runtime.pipe message handlers
 type RuntimePipeR struct {
 	fd                 *os.File
-	idMapper           procfs.IdMapper
 	readyContext       context.Context
 	finishReadyContext context.CancelFunc
 }
@@ -33,14 +28,13 @@ func CreateRuntimePipe(stateDir string) error {
 }
 
 // NewRuntimePipeReader opens the runtime pipe with read access and returns InitPipeReader
-func NewRuntimePipeReader(ctx context.Context, stateDir string, idMapper procfs.IdMapper) (ready <-chan struct{}, closer io.Closer, err error) {
+func NewRuntimePipeReader(ctx context.Context, stateDir string) (ready <-chan struct{}, closer io.Closer, err error) {
 	p := new(RuntimePipeR)
 	fd, err := openPipeReader(stateDir, runtimePipeFileName)
 	if err != nil {
 		return nil, nil, err
 	}
 	p.fd = fd
-	p.idMapper = idMapper
 
 	return p.listen(ctx), fd, nil
 }
@@ -58,24 +52,6 @@ func (r *RuntimePipeR) listen(ctx context.Context) (waitForReady <-chan struct{}
 				log.Debug("received ready message")
 				r.onReady()
 				return
-			case *pb.FromInit_MapGid:
-				log.Debug("received map gid request")
-				req := msg.GetMapGid()
-				//TODO fix, pid self is runtime pid not init pid, needs to be init pid
-				// (no performance impact expected)
-				err := r.idMapper.MapGid(procfs.PidSelf, req.InsideId, req.OutsideId)
-				if err != nil {
-					log.Error("map gid failed", zap.Error(err))
-				}
-			case *pb.FromInit_MapUid:
-				log.Debug("received map uid request")
-				req := msg.GetMapUid()
-				//TODO fix, pid self is runtime pid not init pid, needs to be init pid
-				// (no performance impact expected)
-				err := r.idMapper.MapUid(procfs.PidSelf, req.InsideId, req.OutsideId)
-				if err != nil {
-					log.Error("map uid failed", zap.Error(err))
-				}
 			}
 		}
 	}()
@@ -109,16 +85,6 @@ func (r RuntimePipeW) SendReady() error {
 	return write(r.fd, NewMessageReady())
 }
 
-// MapUid sends the UidMapping message
-func (r RuntimePipeW) MapUid(pid procfs.Pid, insideId, outsideId uint32) error {
-	return write(r.fd, NewMessageUidMapping(insideId, outsideId))
-}
-
-// MapGid sends the GidMapping message
-func (r RuntimePipeW) MapGid(pid procfs.Pid, insideId, outsideId uint32) error {
-	return write(r.fd, NewMessageGidMapping(insideId, outsideId))
-}
-
 func (r RuntimePipeW) Close() error {
 	if r.fd != nil {
 		return r.fd.Close()
@@ -3,23 +3,23 @@ package namespace
 import (
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
-	"roci/pkg/logger"
 	"syscall"
 )
 
 // Cgroup Namespace
 type cgroupNS struct {
+	nsPath
 	log *zap.Logger
 }
 
-func newCgroupNamespace() *cgroupNS {
-	ns := new(cgroupNS)
+func newCgroupNamespace(path nsPath) *cgroupNS {
+	ns := &cgroupNS{nsPath: path}
 	ns.log = log.Named("cgroup")
 	return ns
 }
 
 func (c *cgroupNS) IsSupported() bool {
-	return false
+	return true
 }
 
 func (c *cgroupNS) Priority() int {
@@ -30,12 +30,13 @@ func (c *cgroupNS) Type() specs.LinuxNamespaceType {
 	return specs.CgroupNamespace
 }
 
+// CloneFlag of the cgroup namespace. It is unshared by the init process, which is moved into the cgroup of
+// the container by the runtime before, so the cgroup becomes the root of the namespace.
 func (c *cgroupNS) CloneFlag() uintptr {
-	logger.LogNotImplemented("namespace.cgroup")
 	return syscall.CLONE_NEWCGROUP
 }
 
 func (c *cgroupNS) Finalize(spec specs.Spec) error {
-	logger.LogNotImplemented("namespace.cgroup")
+	// the cgroup hierarchy of the namespace is mounted in rootfs.FinalizeRootfs
 	return nil
 }
@@ -7,10 +7,11 @@ import (
 
 // IPC Namespace
 type ipcNS struct {
+	nsPath
 }
 
-func newIpcNamespace() *ipcNS {
-	return new(ipcNS)
+func newIpcNamespace(path nsPath) *ipcNS {
+	return &ipcNS{nsPath: path}
 }
 
 func (i *ipcNS) IsSupported() bool {
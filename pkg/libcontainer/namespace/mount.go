@@ -1,13 +1,16 @@
 package namespace
 
 import (
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
+	"roci/pkg/libcontainer/oci"
 	"syscall"
 )
 
 // Mount Namespace
 type mountNS struct {
+	nsPath
 	log *zap.Logger
 }
 
@@ -15,8 +18,8 @@ func (m *mountNS) IsSupported() bool {
 	return true
 }
 
-func newMountNamespace() *mountNS {
-	ns := new(mountNS)
+func newMountNamespace(path nsPath) *mountNS {
+	ns := &mountNS{nsPath: path}
 	ns.log = log.Named("mnt")
 	return ns
 }
@@ -33,6 +36,17 @@ func (m *mountNS) CloneFlag() uintptr {
 	return syscall.CLONE_NEWNS
 }
 
+// Finalize sets the propagation of the mounts of the new namespace to the rootfs propagation of the spec.
+// With the default slave propagation the mounts receive the mount events of the host, but the mounts of
+// the container don't propagate back to the host.
 func (m *mountNS) Finalize(spec specs.Spec) error {
+	flags, err := oci.RootfsPropagation(spec.Linux.RootfsPropagation)
+	if err != nil {
+		return err
+	}
+	m.log.Debug("setting mount propagation", zap.String("propagation", spec.Linux.RootfsPropagation))
+	if err = syscall.Mount("", "/", "", uintptr(flags), ""); err != nil {
+		return fmt.Errorf("failed to set rootfs propagation: %w", err)
+	}
 	return nil
 }
@@ -1,10 +1,11 @@
 package namespace
 
 import (
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
-	"roci/pkg/libcontainer/ipc"
 	"roci/pkg/logger"
+	"roci/pkg/procfs"
 	"slices"
 	"syscall"
 )
@@ -34,24 +35,44 @@ type Namespace interface {
 	// IsSupported checks whether the namespace is supported by the runtime.
 	// It returns `false` if the namespace cannot be used.
 	IsSupported() bool
+
+	// Path returns the path of an existing namespace that is joined instead of creating
+	// a new one. It is empty if a new namespace is created.
+	Path() string
+}
+
+// nsPath implements Namespace.Path for the namespaces.
+type nsPath string
+
+func (p nsPath) Path() string {
+	return string(p)
 }
 
 // From prepares a list of namespaces based on the provided spec.
-// It takes a RuntimePipeWriter and a spec as inputs and returns a slice of Namespace and an error if any occurs.
+// It takes a spec as input and returns a slice of Namespace and an error if any occurs.
 // The namespaces are sorted based on their priority before being returned.
-func From(runtime ipc.RuntimePipeWriter, spec specs.Spec) (namespaces []Namespace, err error) {
+func From(spec specs.Spec) (namespaces []Namespace, err error) {
 	log.Debug("Preparing namespaces from spec")
 
 	namespacesSpec := spec.Linux.Namespaces
+	if len(spec.Linux.TimeOffsets) > 0 && !slices.ContainsFunc(namespacesSpec, func(ns specs.LinuxNamespace) bool {
+		return ns.Type == specs.TimeNamespace && ns.Path == ""
+	}) {
+		return nil, fmt.Errorf("time offsets require a new time namespace")
+	}
+
 	namespaces = make([]Namespace, len(namespacesSpec))
 	for i, namespace := range namespacesSpec {
-		namespaces[i] = fromNamespaceType(runtime, spec, namespace.Type)
-		log.Debug("prepared namespace", zap.Any("type", namespace.Type))
+		namespaces[i] = fromNamespaceType(spec, namespace)
+		if namespaces[i] == nil {
+			return nil, fmt.Errorf("unknown namespace type %v", namespace.Type)
+		}
+		log.Debug("prepared namespace", zap.Any("type", namespace.Type), zap.String("path", namespace.Path))
 	}
 
 	// Sort the namespaces based on their priority.
-	slices.SortFunc(namespaces, func(a, b Namespace) int {
-		return b.Priority() - b.Priority()
+	slices.SortStableFunc(namespaces, func(a, b Namespace) int {
+		return b.Priority() - a.Priority()
 	})
 
 	return namespaces, nil
@@ -62,26 +83,40 @@ func Unshare(namespace Namespace) error {
 	return syscall.Unshare(int(namespace.CloneFlag()))
 }
 
-// fromNamespaceType maps a LinuxNamespaceType to the corresponding Namespace object.
+// Join changes the namespace of the calling thread to the existing namespace at the path of the provided namespace.
+// Processes started by the thread afterward inherit the namespace.
+// A mount namespace can only be joined by a thread that doesn't share its root and working directory,
+// so they are unshared from the other threads of the process before.
+func Join(namespace Namespace) error {
+	if namespace.Type() == specs.MountNamespace {
+		if err := syscall.Unshare(syscall.CLONE_FS); err != nil {
+			return fmt.Errorf("failed to unshare fs attributes: %w", err)
+		}
+	}
+	return procfs.JoinNamespace(namespace.Path(), namespace.Type())
+}
+
+// fromNamespaceType maps a LinuxNamespace to the corresponding Namespace object.
 // It returns the appropriate Namespace implementation based on the type, or nil if the type is unrecognized.
-func fromNamespaceType(runtime ipc.RuntimePipeWriter, spec specs.Spec, namespaceType specs.LinuxNamespaceType) Namespace {
-	switch namespaceType {
+func fromNamespaceType(spec specs.Spec, namespace specs.LinuxNamespace) Namespace {
+	path := nsPath(namespace.Path)
+	switch namespace.Type {
 	case specs.PIDNamespace:
-		return newPidNamespace(spec)
+		return newPidNamespace(spec, path)
 	case specs.IPCNamespace:
-		return newIpcNamespace()
+		return newIpcNamespace(path)
 	case specs.TimeNamespace:
-		return newTimeNamespace()
+		return newTimeNamespace(path)
 	case specs.UTSNamespace:
-		return newUtsNamespace(spec)
+		return newUtsNamespace(spec, path)
 	case specs.NetworkNamespace:
-		return newNetworkNamespace()
+		return newNetworkNamespace(path)
 	case specs.MountNamespace:
-		return newMountNamespace()
+		return newMountNamespace(path)
 	case specs.CgroupNamespace:
-		return newCgroupNamespace()
+		return newCgroupNamespace(path)
 	case specs.UserNamespace:
-		return newUserNamespace(runtime)
+		return newUserNamespace(path)
 	default:
 		return nil
 	}
@@ -14,7 +14,7 @@ func TestFrom(t *testing.T) {
 		{Type: specs.MountNamespace},
 	}}}
 
-	ns, err := From(nil, spec)
+	ns, err := From(spec)
 	if err != nil {
 		t.Log(err)
 		t.FailNow()
@@ -23,4 +23,89 @@ func TestFrom(t *testing.T) {
 	for i, n := range ns {
 		t.Log(i, n.Priority(), n.Type())
 	}
+
+	expected := []specs.LinuxNamespaceType{specs.UserNamespace, specs.PIDNamespace, specs.MountNamespace}
+	for i, namespaceType := range expected {
+		if ns[i].Type() != namespaceType {
+			t.Errorf("namespace %d, expected: %v, actual: %v", i, namespaceType, ns[i].Type())
+		}
+	}
+}
+
+func TestFromPath(t *testing.T) {
+	spec := specs.Spec{Linux: &specs.Linux{Namespaces: []specs.LinuxNamespace{
+		{Type: specs.NetworkNamespace, Path: "/var/run/netns/pod"},
+		{Type: specs.IPCNamespace},
+	}}}
+
+	ns, err := From(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns[0].Path() != "/var/run/netns/pod" {
+		t.Errorf("path, expected: /var/run/netns/pod, actual: %v", ns[0].Path())
+	}
+	if ns[1].Path() != "" {
+		t.Errorf("expected new ipc namespace, actual path: %v", ns[1].Path())
+	}
+}
+
+func TestFromPathJoinedByInit(t *testing.T) {
+	for _, namespaceType := range []specs.LinuxNamespaceType{specs.UserNamespace, specs.MountNamespace, specs.TimeNamespace} {
+		spec := specs.Spec{Linux: &specs.Linux{Namespaces: []specs.LinuxNamespace{
+			{Type: namespaceType, Path: "/proc/1/ns/" + string(namespaceType)},
+		}}}
+		ns, err := From(spec)
+		if err != nil {
+			t.Errorf("joining %v namespace: %v", namespaceType, err)
+			continue
+		}
+		if ns[0].Path() != spec.Linux.Namespaces[0].Path {
+			t.Errorf("path, expected: %v, actual: %v", spec.Linux.Namespaces[0].Path, ns[0].Path())
+		}
+	}
+}
+
+func TestFromTimeOffsets(t *testing.T) {
+	offsets := map[string]specs.LinuxTimeOffset{"monotonic": {Secs: 86400}}
+
+	spec := specs.Spec{Linux: &specs.Linux{TimeOffsets: offsets, Namespaces: []specs.LinuxNamespace{
+		{Type: specs.PIDNamespace},
+	}}}
+	if _, err := From(spec); err == nil {
+		t.Error("expected error for time offsets without time namespace")
+	}
+
+	spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.TimeNamespace, Path: "/proc/1/ns/time"})
+	if _, err := From(spec); err == nil {
+		t.Error("expected error for time offsets of an existing time namespace")
+	}
+
+	spec.Linux.Namespaces[1].Path = ""
+	if _, err := From(spec); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseKernelVersion(t *testing.T) {
+	tests := map[string][2]int{
+		"6.1.0-13-amd64":  {6, 1},
+		"5.16.20":         {5, 16},
+		"5.4-rc1":         {5, 4},
+		"6.18.44-fc-v139": {6, 18},
+	}
+	for release, expected := range tests {
+		version, err := parseKernelVersion(release)
+		if err != nil {
+			t.Errorf("%v: %v", release, err)
+			continue
+		}
+		if version != expected {
+			t.Errorf("%v, expected: %v, actual: %v", release, expected, version)
+		}
+	}
+
+	if _, err := parseKernelVersion("invalid"); err == nil {
+		t.Errorf("expected an error for an invalid release")
+	}
 }
@@ -3,24 +3,24 @@ package namespace
 import (
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
-	"roci/pkg/logger"
+	"roci/pkg/libcontainer/network"
 	"syscall"
 )
 
 // Network Namespace
 type netNS struct {
+	nsPath
 	logger *zap.Logger
 }
 
-func newNetworkNamespace() *netNS {
-	ns := new(netNS)
+func newNetworkNamespace(path nsPath) *netNS {
+	ns := &netNS{nsPath: path}
 	ns.logger = log.Named("net")
 	return ns
 }
 
 func (n *netNS) IsSupported() bool {
-	// Network isolation is not implemented in roci
-	return false
+	return true
 }
 
 func (n *netNS) Priority() int {
@@ -32,11 +32,12 @@ func (n *netNS) Type() specs.LinuxNamespaceType {
 }
 
 func (n *netNS) CloneFlag() uintptr {
-	logger.LogNotImplemented("namespace.cgroup")
 	return syscall.CLONE_NEWNET
 }
 
+// Finalize brings up the loopback device, a new network namespace starts with all devices down.
+// The namespace is created by the runtime, which connects it to the host before the init process continues.
 func (n *netNS) Finalize(spec specs.Spec) error {
-	logger.LogNotImplemented("namespace.cgroup")
-	return nil
+	n.logger.Debug("setting loopback device up")
+	return network.LoopbackUp()
 }
@@ -8,12 +8,13 @@ import (
 
 // PID namespace
 type pidNS struct {
+	nsPath
 	logger *zap.Logger
 	spec   specs.Spec
 }
 
-func newPidNamespace(spec specs.Spec) *pidNS {
-	return &pidNS{spec: spec, logger: zap.L().Named("namespace").Named("pid")}
+func newPidNamespace(spec specs.Spec, path nsPath) *pidNS {
+	return &pidNS{nsPath: path, spec: spec, logger: zap.L().Named("namespace").Named("pid")}
 }
 
 func (p *pidNS) IsSupported() bool {
@@ -1,16 +1,24 @@
 package namespace
 
 import (
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"roci/pkg/procfs"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
+// timeExecVersion is the first kernel version whose exec switches to the time namespace of the children
+var timeExecVersion = [2]int{5, 16}
+
 // Time namespace
 type timeNS struct {
+	nsPath
 }
 
-func newTimeNamespace() *timeNS {
-	return new(timeNS)
+func newTimeNamespace(path nsPath) *timeNS {
+	return &timeNS{nsPath: path}
 }
 
 func (t *timeNS) IsSupported() bool {
@@ -29,6 +37,56 @@ func (t *timeNS) CloneFlag() uintptr {
 	return syscall.CLONE_NEWTIME
 }
 
+// Finalize writes the clock offsets of the spec. Unsharing the time namespace only changes the namespace
+// of the children, so the init process can still write the offsets before the entrypoint enters the
+// namespace with its exec. Older kernels don't switch the namespace on exec, the entrypoint would stay
+// in the namespace of the runtime, so they are rejected.
+// The offsets file belongs to the main thread, which runs the init process.
 func (t *timeNS) Finalize(spec specs.Spec) error {
-	return nil
+	version, err := kernelVersion()
+	if err != nil {
+		return err
+	}
+	if version[0] < timeExecVersion[0] || version[0] == timeExecVersion[0] && version[1] < timeExecVersion[1] {
+		return fmt.Errorf("a new time namespace requires kernel %d.%d or newer, the kernel is %d.%d",
+			timeExecVersion[0], timeExecVersion[1], version[0], version[1])
+	}
+
+	if spec.Linux == nil || len(spec.Linux.TimeOffsets) == 0 {
+		return nil
+	}
+	return procfs.Root.SetTimeOffsets(procfs.PidSelf, spec.Linux.TimeOffsets)
+}
+
+// kernelVersion returns the major and minor version of the running kernel.
+func kernelVersion() ([2]int, error) {
+	var uname syscall.Utsname
+	if err := syscall.Uname(&uname); err != nil {
+		return [2]int{}, err
+	}
+	release := make([]byte, 0, len(uname.Release))
+	for _, c := range uname.Release {
+		if c == 0 {
+			break
+		}
+		release = append(release, byte(c))
+	}
+	return parseKernelVersion(string(release))
+}
+
+// parseKernelVersion parses the major and minor version of a kernel release like "6.1.0-13-amd64".
+func parseKernelVersion(release string) (version [2]int, err error) {
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return version, fmt.Errorf("invalid kernel release %q", release)
+	}
+	// the minor version may be followed by a suffix, if the release has no patch level
+	minor, _, _ := strings.Cut(parts[1], "-")
+	if version[0], err = strconv.Atoi(parts[0]); err != nil {
+		return version, fmt.Errorf("invalid kernel release %q", release)
+	}
+	if version[1], err = strconv.Atoi(minor); err != nil {
+		return version, fmt.Errorf("invalid kernel release %q", release)
+	}
+	return version, nil
 }
@@ -2,19 +2,19 @@ package namespace
 
 import (
 	"github.com/opencontainers/runtime-spec/specs-go"
-	"roci/pkg/procfs"
 	"syscall"
 )
 
 // User namespace
+//
+// A multithreaded process can't unshare the user namespace, so it is created together with the
+// pid namespace when the runtime starts the init process. The runtime writes the id mappings.
 type userNS struct {
-	idMapper procfs.IdMapper
+	nsPath
 }
 
-func newUserNamespace(idMapper procfs.IdMapper) *userNS {
-	ns := new(userNS)
-	ns.idMapper = idMapper
-	return ns
+func newUserNamespace(path nsPath) *userNS {
+	return &userNS{nsPath: path}
 }
 
 func (u *userNS) IsSupported() bool {
@@ -34,23 +34,5 @@ func (u *userNS) CloneFlag() uintptr {
 }
 
 func (u *userNS) Finalize(spec specs.Spec) (err error) {
-	insideUid := spec.Process.User.UID
-	if err = u.idMapper.MapUid(procfs.PidSelf, insideUid, uint32(syscall.Getuid())); err != nil {
-		return err
-	}
-
-	insideGid := spec.Process.User.GID
-	if err = u.idMapper.MapGid(procfs.PidSelf, insideGid, uint32(syscall.Getgid())); err != nil {
-		return err
-	}
-
-	if err = syscall.Setuid(int(insideUid)); err != nil {
-		return err
-	}
-
-	if err = syscall.Setgid(int(insideGid)); err != nil {
-		return err
-	}
-
-	return
+	return nil
 }
@@ -8,6 +8,7 @@ import (
 
 // UTS namespace
 type utsNS struct {
+	nsPath
 	specs specs.Spec
 }
 
@@ -19,8 +20,8 @@ func (u *utsNS) Priority() int {
 	return 0
 }
 
-func newUtsNamespace(spec specs.Spec) *utsNS {
-	return &utsNS{specs: spec}
+func newUtsNamespace(spec specs.Spec, path nsPath) *utsNS {
+	return &utsNS{nsPath: path, specs: spec}
 }
 
 func (u *utsNS) Type() specs.LinuxNamespaceType {
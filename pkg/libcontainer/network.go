@@ -0,0 +1,136 @@
+package libcontainer
+
+import (
+	"errors"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"path/filepath"
+	"roci/pkg/libcontainer/network"
+	"roci/pkg/logger"
+	"roci/pkg/util"
+	"syscall"
+)
+
+const (
+	// endpointFileName is the name of the file in the state dir that stores the network endpoint of a container
+	endpointFileName = "network.json"
+
+	// cniFileName is the name of the file in the state dir that stores the CNI network and result of a container
+	cniFileName = "cni.json"
+)
+
+// createNetwork prepares the network attachment of the container with the given ID for the network mode.
+// It returns nil if the mode is none or the container doesn't create a network namespace.
+func (r *FS) createNetwork(id, stateDir string, spec *specs.Spec) (network.Attachment, error) {
+	if !hasNewNetworkNamespace(spec) {
+		return nil, nil
+	}
+	switch r.network.Mode {
+	case network.ModeBridge:
+		return r.createEndpoint(id, stateDir)
+	case network.ModeCNI:
+		return r.network.CNI.NewCNIAttachment(id, path.Join(stateDir, cniFileName))
+	}
+	return nil, nil
+}
+
+// createEndpoint allocates the network endpoint of the container with the given ID and stores it in the state dir.
+// The container dir is locked during the allocation, so concurrent runtimes don't assign the same address.
+func (r *FS) createEndpoint(id, stateDir string) (*network.Endpoint, error) {
+	lock, err := os.Open(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+	if err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+
+	used, err := r.usedAddresses()
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := r.network.Allocate(id, used)
+	if err != nil {
+		return nil, err
+	}
+	if err = util.WriteJsonFile(path.Join(stateDir, endpointFileName), endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// usedAddresses returns the addresses of the network endpoints of all containers.
+func (r *FS) usedAddresses() (addresses []string, err error) {
+	files, err := filepath.Glob(filepath.Join(r.dir, "*", endpointFileName))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		var endpoint network.Endpoint
+		if err = util.ReadJsonFile(file, &endpoint); err != nil {
+			logger.Log().Warn("failed to read network endpoint", zap.String("path", file), zap.Error(err))
+			continue
+		}
+		addresses = append(addresses, endpoint.Address)
+	}
+	return addresses, nil
+}
+
+// loadNetwork loads the network attachment stored in the state dir.
+// It returns nil if the container has none.
+func loadNetwork(stateDir string) (network.Attachment, error) {
+	var endpoint network.Endpoint
+	err := util.ReadJsonFile(path.Join(stateDir, endpointFileName), &endpoint)
+	if err == nil {
+		return &endpoint, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	attachment, err := network.LoadCNIAttachment(path.Join(stateDir, cniFileName))
+	if err == nil {
+		return attachment, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// removeNetwork detaches the network attachment stored in the state dir, if the container has one.
+func removeNetwork(stateDir string) error {
+	attachment, err := loadNetwork(stateDir)
+	if err != nil || attachment == nil {
+		return err
+	}
+	return attachment.Detach()
+}
+
+// checkNetwork verifies the CNI network of the container with the init process pid, if it has one.
+func checkNetwork(stateDir string, pid int) error {
+	attachment, err := loadNetwork(stateDir)
+	if err != nil {
+		return err
+	}
+	if cni, ok := attachment.(*network.CNIAttachment); ok {
+		return cni.Check(pid)
+	}
+	return nil
+}
+
+// hasNewNetworkNamespace checks whether the spec contains a network namespace that is not joined.
+func hasNewNetworkNamespace(spec *specs.Spec) bool {
+	if spec.Linux == nil {
+		return false
+	}
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.NetworkNamespace && ns.Path == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"roci/pkg/util"
+	"strconv"
+	"strings"
+)
+
+// CNI operations, see the CNI specification
+const (
+	cniAdd   = "ADD"
+	cniDel   = "DEL"
+	cniCheck = "CHECK"
+)
+
+// CNIConfig configures the plugins that are called in the cni network mode.
+type CNIConfig struct {
+	// PluginDirs are searched for the plugin binaries
+	PluginDirs []string `mapstructure:"pluginDirs"`
+
+	// ConfDir contains the network configurations (.conflist, .conf or .json)
+	ConfDir string `mapstructure:"confDir"`
+
+	// Network is the name of the used network configuration, the first one by file name is used if it is empty
+	Network string `mapstructure:"network"`
+
+	// IfName is the name of the interface that is created inside the container
+	IfName string `mapstructure:"ifName"`
+}
+
+// Validate checks that the directories are configured.
+func (c CNIConfig) Validate() error {
+	if len(c.PluginDirs) == 0 {
+		return fmt.Errorf("cni plugin dirs are empty")
+	}
+	if c.ConfDir == "" {
+		return fmt.Errorf("cni conf dir is empty")
+	}
+	return nil
+}
+
+// cniConfList is a network configuration list of the conf dir.
+type cniConfList struct {
+	CNIVersion   string            `json:"cniVersion"`
+	Name         string            `json:"name"`
+	DisableCheck bool              `json:"disableCheck,omitempty"`
+	Plugins      []json.RawMessage `json:"plugins"`
+}
+
+// cniError is written to stdout by a failed plugin.
+type cniError struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Details string `json:"details,omitempty"`
+}
+
+// CNIAttachment connects the network namespace of a container with the plugins of a CNI network.
+// It is stored in the state dir of the container with the result of ADD, so the same network is deleted later on.
+type CNIAttachment struct {
+	ContainerID string          `json:"containerId"`
+	NetNS       string          `json:"netns,omitempty"`
+	IfName      string          `json:"ifName"`
+	PluginDirs  []string        `json:"pluginDirs"`
+	Network     cniConfList     `json:"network"`
+	Result      json.RawMessage `json:"result,omitempty"`
+
+	// file is the path the attachment is stored at after ADD
+	file string
+}
+
+// NewCNIAttachment loads the network configuration of the conf dir for the container with id.
+// The attachment is stored at file once the container was added to the network.
+func (c CNIConfig) NewCNIAttachment(id, file string) (*CNIAttachment, error) {
+	network, err := c.loadNetwork()
+	if err != nil {
+		return nil, err
+	}
+	ifName := c.IfName
+	if ifName == "" {
+		ifName = ContainerInterface
+	}
+	return &CNIAttachment{
+		ContainerID: id,
+		IfName:      ifName,
+		PluginDirs:  c.PluginDirs,
+		Network:     *network,
+		file:        file,
+	}, nil
+}
+
+// LoadCNIAttachment loads the attachment stored at file.
+func LoadCNIAttachment(file string) (*CNIAttachment, error) {
+	a := &CNIAttachment{file: file}
+	if err := util.ReadJsonFile(file, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// loadNetwork reads the network configuration with the configured name from the conf dir.
+// The files are read in lexical order like libcni does, a single plugin configuration is used as list with one plugin.
+func (c CNIConfig) loadNetwork() (*cniConfList, error) {
+	entries, err := os.ReadDir(c.ConfDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".conflist" && ext != ".conf" && ext != ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(c.ConfDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var network cniConfList
+		if err = json.Unmarshal(data, &network); err != nil {
+			return nil, fmt.Errorf("invalid cni configuration %v: %w", entry.Name(), err)
+		}
+		if ext != ".conflist" {
+			network.Plugins = []json.RawMessage{data}
+		}
+		if c.Network != "" && network.Name != c.Network {
+			continue
+		}
+		if len(network.Plugins) == 0 {
+			return nil, fmt.Errorf("cni configuration %v has no plugins", entry.Name())
+		}
+		return &network, nil
+	}
+
+	if c.Network != "" {
+		return nil, fmt.Errorf("cni network %v not found in %v", c.Network, c.ConfDir)
+	}
+	return nil, fmt.Errorf("no cni network found in %v", c.ConfDir)
+}
+
+// Attach adds the network namespace of the process with pid to the network and stores the result.
+// The plugins are called in order, every plugin receives the result of the previous one.
+// If a plugin fails, the network is deleted again.
+func (a *CNIAttachment) Attach(pid int) (err error) {
+	a.NetNS = procfs.Root.NsPath(procfs.Pid(pid), specs.NetworkNamespace)
+
+	var result json.RawMessage
+	for _, plugin := range a.Network.Plugins {
+		if result, err = a.exec(cniAdd, plugin, result); err != nil {
+			_ = a.del()
+			return err
+		}
+	}
+	a.Result = result
+	return util.WriteJsonFile(a.file, a)
+}
+
+// Detach deletes the container from the network.
+// The runtime only detaches stopped containers whose network namespace doesn't exist anymore,
+// the path of the namespace is cleared because its pid might have been reused.
+func (a *CNIAttachment) Detach() error {
+	a.NetNS = ""
+	return a.del()
+}
+
+// del calls DEL of the plugins in reverse order. All plugins are called even if one of them failed,
+// so they release as many resources as possible.
+func (a *CNIAttachment) del() error {
+	var errs []error
+	for i := len(a.Network.Plugins) - 1; i >= 0; i-- {
+		if _, err := a.exec(cniDel, a.Network.Plugins[i], a.Result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check verifies that the network of the container with pid is still set up like the plugins expect it.
+// It is skipped if the network disables it or its version is older than 0.4.0, which introduced CHECK.
+func (a *CNIAttachment) Check(pid int) error {
+	if a.Network.DisableCheck || !cniVersionAtLeast(a.Network.CNIVersion, 0, 4) {
+		return nil
+	}
+	a.NetNS = procfs.Root.NsPath(procfs.Pid(pid), specs.NetworkNamespace)
+	for _, plugin := range a.Network.Plugins {
+		if _, err := a.exec(cniCheck, plugin, a.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exec calls the plugin of the configuration with the command. The configuration passed to the plugin
+// gets the name and version of the network and the result of the previous plugin.
+func (a *CNIAttachment) exec(command string, plugin json.RawMessage, prevResult json.RawMessage) (json.RawMessage, error) {
+	var conf map[string]any
+	if err := json.Unmarshal(plugin, &conf); err != nil {
+		return nil, err
+	}
+	pluginType, _ := conf["type"].(string)
+	if pluginType == "" {
+		return nil, fmt.Errorf("cni plugin configuration of network %v has no type", a.Network.Name)
+	}
+	conf["name"] = a.Network.Name
+	conf["cniVersion"] = a.Network.CNIVersion
+	delete(conf, "prevResult")
+	if prevResult != nil {
+		conf["prevResult"] = prevResult
+	}
+	stdin, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := a.findPlugin(pluginType)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log().Named("cni").Debug("calling plugin", zap.String("command", command), zap.String("plugin", path))
+	var stdout bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"CNI_COMMAND="+command,
+		"CNI_CONTAINERID="+a.ContainerID,
+		"CNI_NETNS="+a.NetNS,
+		"CNI_IFNAME="+a.IfName,
+		"CNI_PATH="+strings.Join(a.PluginDirs, string(os.PathListSeparator)),
+	)
+	if err = cmd.Run(); err != nil {
+		var pluginErr cniError
+		if json.Unmarshal(stdout.Bytes(), &pluginErr) == nil && pluginErr.Msg != "" {
+			return nil, fmt.Errorf("cni plugin %v %v failed with code %d: %v %v", pluginType, command, pluginErr.Code, pluginErr.Msg, pluginErr.Details)
+		}
+		return nil, fmt.Errorf("cni plugin %v %v failed: %w", pluginType, command, err)
+	}
+
+	if command != cniAdd {
+		return nil, nil
+	}
+	result := json.RawMessage(bytes.TrimSpace(stdout.Bytes()))
+	if !json.Valid(result) {
+		return nil, fmt.Errorf("cni plugin %v returned an invalid result", pluginType)
+	}
+	return result, nil
+}
+
+// findPlugin returns the path of the plugin binary in the first plugin dir that contains it.
+func (a *CNIAttachment) findPlugin(pluginType string) (string, error) {
+	if strings.ContainsRune(pluginType, filepath.Separator) {
+		return "", fmt.Errorf("invalid cni plugin type %q", pluginType)
+	}
+	for _, dir := range a.PluginDirs {
+		path := filepath.Join(dir, pluginType)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cni plugin %v not found in %v", pluginType, a.PluginDirs)
+}
+
+// cniVersionAtLeast checks whether the version is at least major.minor.
+func cniVersionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	vMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	vMinor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return vMajor > major || (vMajor == major && vMinor >= minor)
+}
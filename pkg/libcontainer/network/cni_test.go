@@ -0,0 +1,194 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildFakePlugin builds the fake plugin of testdata into a plugin dir.
+func buildFakePlugin(t *testing.T) (pluginDir string) {
+	pluginDir = t.TempDir()
+	out, err := exec.Command("go", "build", "-o", filepath.Join(pluginDir, "fake"), "./testdata/fakeplugin").CombinedOutput()
+	if err != nil {
+		t.Skipf("failed to build fake plugin: %v %s", err, out)
+	}
+	return pluginDir
+}
+
+// writeConf writes a network configuration into the conf dir.
+func writeConf(t *testing.T, confDir, name string, conf any) {
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(confDir, name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readLog returns the invocations logged by the fake plugin.
+func readLog(t *testing.T, log string) []string {
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func fakeNetwork(name, log string, fail string) map[string]any {
+	return map[string]any{
+		"cniVersion": "1.0.0",
+		"name":       name,
+		"plugins": []map[string]any{
+			{"type": "fake", "log": log, "address": "10.0.0.2/24"},
+			{"type": "fake", "log": log, "address": "chained", "fail": fail},
+		},
+	}
+}
+
+func TestCNIConfig_LoadNetwork(t *testing.T) {
+	confDir := t.TempDir()
+	writeConf(t, confDir, "10-first.conflist", map[string]any{"cniVersion": "1.0.0", "name": "first", "plugins": []map[string]any{{"type": "bridge"}}})
+	writeConf(t, confDir, "20-second.conf", map[string]any{"cniVersion": "0.3.1", "name": "second", "type": "ptp"})
+	writeConf(t, confDir, "30-ignored.txt", map[string]any{"name": "ignored"})
+
+	network, err := CNIConfig{ConfDir: confDir}.loadNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network.Name != "first" || len(network.Plugins) != 1 {
+		t.Errorf("network, expected: first with 1 plugin, actual: %v with %d plugins", network.Name, len(network.Plugins))
+	}
+
+	network, err = CNIConfig{ConfDir: confDir, Network: "second"}.loadNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plugin map[string]any
+	if err = json.Unmarshal(network.Plugins[0], &plugin); err != nil {
+		t.Fatal(err)
+	}
+	if network.CNIVersion != "0.3.1" || plugin["type"] != "ptp" {
+		t.Errorf("network, expected: single ptp plugin of version 0.3.1, actual: %v %v", network.CNIVersion, plugin)
+	}
+
+	if _, err = (CNIConfig{ConfDir: confDir, Network: "ignored"}).loadNetwork(); err == nil {
+		t.Error("expected error for missing network")
+	}
+}
+
+func TestCNIAttachment(t *testing.T) {
+	var (
+		pluginDir = buildFakePlugin(t)
+		confDir   = t.TempDir()
+		stateDir  = t.TempDir()
+		log       = filepath.Join(stateDir, "plugin.log")
+		file      = filepath.Join(stateDir, "cni.json")
+		netns     = fmt.Sprintf("/proc/%d/ns/net", os.Getpid())
+	)
+	writeConf(t, confDir, "10-fake.conflist", fakeNetwork("fake", log, ""))
+
+	attachment, err := CNIConfig{PluginDirs: []string{t.TempDir(), pluginDir}, ConfDir: confDir}.NewCNIAttachment("abc", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = attachment.Attach(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadCNIAttachment(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		IPs []struct{ Address string } `json:"ips"`
+	}
+	if err = json.Unmarshal(loaded.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.IPs) != 1 || result.IPs[0].Address != "10.0.0.2/24" {
+		t.Errorf("result, expected address 10.0.0.2/24, actual: %s", loaded.Result)
+	}
+
+	if err = loaded.Check(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.Detach(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"ADD fake 10.0.0.2/24 abc " + netns + " eth0 false",
+		"ADD fake chained abc " + netns + " eth0 true",
+		"CHECK fake 10.0.0.2/24 abc " + netns + " eth0 true",
+		"CHECK fake chained abc " + netns + " eth0 true",
+		"DEL fake chained abc  eth0 true",
+		"DEL fake 10.0.0.2/24 abc  eth0 true",
+	}
+	if actual := readLog(t, log); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("invocations, expected:\n%v\nactual:\n%v", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestCNIAttachment_AttachFailure(t *testing.T) {
+	var (
+		pluginDir = buildFakePlugin(t)
+		confDir   = t.TempDir()
+		stateDir  = t.TempDir()
+		log       = filepath.Join(stateDir, "plugin.log")
+		file      = filepath.Join(stateDir, "cni.json")
+	)
+	writeConf(t, confDir, "10-fake.conflist", fakeNetwork("fake", log, "ADD"))
+
+	attachment, err := CNIConfig{PluginDirs: []string{pluginDir}, ConfDir: confDir}.NewCNIAttachment("abc", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = attachment.Attach(os.Getpid())
+	if err == nil || !strings.Contains(err.Error(), "fake failure") {
+		t.Fatalf("expected error of the plugin, actual: %v", err)
+	}
+	if _, err = os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected no stored attachment, actual: %v", err)
+	}
+
+	// the network is deleted again after the failed ADD
+	invocations := readLog(t, log)
+	if len(invocations) != 4 || !strings.HasPrefix(invocations[2], "DEL fake chained") || !strings.HasPrefix(invocations[3], "DEL fake 10.0.0.2/24") {
+		t.Errorf("invocations, expected ADD, ADD, DEL, DEL, actual: %v", invocations)
+	}
+}
+
+func TestCNIAttachment_FindPlugin(t *testing.T) {
+	attachment := &CNIAttachment{PluginDirs: []string{t.TempDir()}}
+	if _, err := attachment.findPlugin("missing"); err == nil {
+		t.Error("expected error for missing plugin")
+	}
+	if _, err := attachment.findPlugin("../fake"); err == nil {
+		t.Error("expected error for plugin type with path")
+	}
+}
+
+func TestCNIVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{"1.0.0", true},
+		{"0.4.0", true},
+		{"0.3.1", false},
+		{"0.2.0", false},
+		{"", false},
+		{"x.y", false},
+	}
+	for _, tt := range tests {
+		if actual := cniVersionAtLeast(tt.version, 0, 4); actual != tt.expected {
+			t.Errorf("cniVersionAtLeast(%q), expected: %v, actual: %v", tt.version, tt.expected, actual)
+		}
+	}
+}
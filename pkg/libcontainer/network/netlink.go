@@ -0,0 +1,242 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// netlink attribute types that are missing in syscall, see linux/if_link.h and linux/veth.h
+const (
+	iflaLinkInfo = 18 // IFLA_LINKINFO
+	iflaNetNsPid = 19 // IFLA_NET_NS_PID
+	iflaInfoKind = 1  // IFLA_INFO_KIND
+	iflaInfoData = 2  // IFLA_INFO_DATA
+	vethInfoPeer = 1  // VETH_INFO_PEER
+)
+
+// attr is a route attribute of a netlink message. The data of nested attributes is followed by their children.
+type attr struct {
+	typ      uint16
+	data     []byte
+	children []*attr
+}
+
+// add appends a child attribute and returns it.
+func (a *attr) add(typ uint16, data []byte) *attr {
+	child := &attr{typ: typ, data: data}
+	a.children = append(a.children, child)
+	return child
+}
+
+// encode returns the attribute in wire format, padded to the attribute alignment.
+func (a *attr) encode() []byte {
+	payload := append([]byte(nil), a.data...)
+	for _, child := range a.children {
+		payload = append(payload, child.encode()...)
+	}
+
+	length := syscall.SizeofRtAttr + len(payload)
+	b := make([]byte, rtaAlign(length))
+	binary.NativeEndian.PutUint16(b[0:], uint16(length))
+	binary.NativeEndian.PutUint16(b[2:], a.typ)
+	copy(b[syscall.SizeofRtAttr:], payload)
+	return b
+}
+
+// request is a netlink route request that is acknowledged by the kernel.
+type request struct {
+	attr
+	flags uint16
+}
+
+// newRequest creates a request of the message type with the header of the message type as data.
+func newRequest(typ uint16, flags uint16, header []byte) *request {
+	return &request{attr: attr{typ: typ, data: header}, flags: flags}
+}
+
+// encode returns the request as netlink message with the sequence number seq.
+func (r *request) encode(seq uint32) []byte {
+	payload := append([]byte(nil), r.data...)
+	for _, child := range r.children {
+		payload = append(payload, child.encode()...)
+	}
+
+	b := make([]byte, syscall.NLMSG_HDRLEN+len(payload))
+	binary.NativeEndian.PutUint32(b[0:], uint32(len(b)))
+	binary.NativeEndian.PutUint16(b[4:], r.typ)
+	binary.NativeEndian.PutUint16(b[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK|r.flags)
+	binary.NativeEndian.PutUint32(b[8:], seq)
+	copy(b[syscall.NLMSG_HDRLEN:], payload)
+	return b
+}
+
+// execute sends the request and waits for its acknowledgement.
+// The socket is opened for every request, so it belongs to the network namespace of the calling thread.
+func (r *request) execute() error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	kernel := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	const seq = 1
+	if err = syscall.Sendto(fd, r.encode(seq), 0, kernel); err != nil {
+		return err
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq || msg.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+			if len(msg.Data) < 4 {
+				return fmt.Errorf("short netlink error message")
+			}
+			// the error is negative errno, 0 acknowledges the request
+			if errno := -int32(binary.NativeEndian.Uint32(msg.Data)); errno != 0 {
+				return syscall.Errno(errno)
+			}
+			return nil
+		}
+	}
+}
+
+// ifInfomsg returns the header of link messages, see rtnetlink(7).
+func ifInfomsg(index int, flags, change uint32) []byte {
+	b := make([]byte, syscall.SizeofIfInfomsg)
+	b[0] = syscall.AF_UNSPEC
+	binary.NativeEndian.PutUint32(b[4:], uint32(index))
+	binary.NativeEndian.PutUint32(b[8:], flags)
+	binary.NativeEndian.PutUint32(b[12:], change)
+	return b
+}
+
+// ifAddrmsg returns the header of IPv4 address messages, see rtnetlink(7).
+func ifAddrmsg(index int, prefixLen int) []byte {
+	b := make([]byte, syscall.SizeofIfAddrmsg)
+	b[0] = syscall.AF_INET
+	b[1] = byte(prefixLen)
+	b[3] = syscall.RT_SCOPE_UNIVERSE
+	binary.NativeEndian.PutUint32(b[4:], uint32(index))
+	return b
+}
+
+// rtmsg returns the header of IPv4 route messages of the main table, see rtnetlink(7).
+func rtmsg() []byte {
+	b := make([]byte, syscall.SizeofRtMsg)
+	b[0] = syscall.AF_INET
+	b[4] = syscall.RT_TABLE_MAIN
+	b[5] = syscall.RTPROT_BOOT
+	b[6] = syscall.RT_SCOPE_UNIVERSE
+	b[7] = syscall.RTN_UNICAST
+	return b
+}
+
+// linkSetUp sets the link with the index up.
+func linkSetUp(index int) error {
+	return newRequest(syscall.RTM_NEWLINK, 0, ifInfomsg(index, syscall.IFF_UP, syscall.IFF_UP)).execute()
+}
+
+// linkSetMaster attaches the link with the index to the bridge with the index master.
+func linkSetMaster(index, master int) error {
+	r := newRequest(syscall.RTM_NEWLINK, 0, ifInfomsg(index, 0, 0))
+	r.add(syscall.IFLA_MASTER, uint32Attr(uint32(master)))
+	return r.execute()
+}
+
+// linkDel deletes the link with the name.
+func linkDel(name string) error {
+	r := newRequest(syscall.RTM_DELLINK, 0, ifInfomsg(0, 0, 0))
+	r.add(syscall.IFLA_IFNAME, stringAttr(name))
+	return r.execute()
+}
+
+// addBridge creates a bridge with the name.
+func addBridge(name string, mtu int) error {
+	r := newRequest(syscall.RTM_NEWLINK, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, ifInfomsg(0, 0, 0))
+	r.add(syscall.IFLA_IFNAME, stringAttr(name))
+	if mtu > 0 {
+		r.add(syscall.IFLA_MTU, uint32Attr(uint32(mtu)))
+	}
+	r.add(iflaLinkInfo, nil).add(iflaInfoKind, stringAttr("bridge"))
+	return r.execute()
+}
+
+// addVeth creates a veth pair. The peer is created in the network namespace of the process with peerPid.
+func addVeth(name, peer string, peerPid int, mtu int) error {
+	r := newRequest(syscall.RTM_NEWLINK, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, ifInfomsg(0, 0, 0))
+	r.add(syscall.IFLA_IFNAME, stringAttr(name))
+	if mtu > 0 {
+		r.add(syscall.IFLA_MTU, uint32Attr(uint32(mtu)))
+	}
+	info := r.add(iflaLinkInfo, nil)
+	info.add(iflaInfoKind, stringAttr("veth"))
+
+	// the peer is described by a link message nested in the veth data
+	peerInfo := info.add(iflaInfoData, nil).add(vethInfoPeer, ifInfomsg(0, 0, 0))
+	peerInfo.add(syscall.IFLA_IFNAME, stringAttr(peer))
+	peerInfo.add(iflaNetNsPid, uint32Attr(uint32(peerPid)))
+	if mtu > 0 {
+		peerInfo.add(syscall.IFLA_MTU, uint32Attr(uint32(mtu)))
+	}
+	return r.execute()
+}
+
+// addAddr adds the IPv4 address to the link with the index.
+func addAddr(index int, addr *net.IPNet) error {
+	ip := addr.IP.To4()
+	if ip == nil {
+		return fmt.Errorf("%v is not an IPv4 address", addr)
+	}
+	prefixLen, _ := addr.Mask.Size()
+
+	r := newRequest(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, ifAddrmsg(index, prefixLen))
+	r.add(syscall.IFA_LOCAL, ip)
+	r.add(syscall.IFA_ADDRESS, ip)
+	return r.execute()
+}
+
+// addDefaultRoute adds the default route via the IPv4 gateway.
+func addDefaultRoute(gateway net.IP) error {
+	ip := gateway.To4()
+	if ip == nil {
+		return fmt.Errorf("%v is not an IPv4 address", gateway)
+	}
+
+	r := newRequest(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, rtmsg())
+	r.add(syscall.RTA_GATEWAY, ip)
+	return r.execute()
+}
+
+// stringAttr returns the data of a string attribute, which is null terminated.
+func stringAttr(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func uint32Attr(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, v)
+	return b
+}
+
+// rtaAlign rounds the length up to the alignment of route attributes, see RTA_ALIGN.
+func rtaAlign(length int) int {
+	return (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+}
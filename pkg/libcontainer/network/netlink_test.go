@@ -0,0 +1,83 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall"
+	"testing"
+)
+
+func TestAttr_Encode(t *testing.T) {
+	a := &attr{typ: syscall.IFLA_IFNAME, data: stringAttr("eth0")}
+	b := a.encode()
+
+	// 4 bytes header, 5 bytes data, padded to 12
+	if len(b) != 12 {
+		t.Fatalf("length, expected: 12, actual: %d", len(b))
+	}
+	if l := binary.NativeEndian.Uint16(b[0:]); l != 9 {
+		t.Errorf("attribute length, expected: 9, actual: %d", l)
+	}
+	if typ := binary.NativeEndian.Uint16(b[2:]); typ != syscall.IFLA_IFNAME {
+		t.Errorf("attribute type, expected: %d, actual: %d", syscall.IFLA_IFNAME, typ)
+	}
+	if !bytes.Equal(b[4:9], []byte("eth0\x00")) {
+		t.Errorf("attribute data, actual: %q", b[4:9])
+	}
+}
+
+func TestAttr_EncodeNested(t *testing.T) {
+	a := &attr{typ: iflaLinkInfo}
+	a.add(iflaInfoKind, stringAttr("veth"))
+	a.add(iflaInfoData, nil).add(vethInfoPeer, ifInfomsg(0, 0, 0))
+	b := a.encode()
+
+	// kind: 4 + 5 padded to 12, data: 4 + peer (4 + 16)
+	expected := 4 + 12 + 4 + 4 + syscall.SizeofIfInfomsg
+	if len(b) != expected {
+		t.Fatalf("length, expected: %d, actual: %d", expected, len(b))
+	}
+	if l := binary.NativeEndian.Uint16(b[0:]); int(l) != expected {
+		t.Errorf("attribute length, expected: %d, actual: %d", expected, l)
+	}
+	if typ := binary.NativeEndian.Uint16(b[16+2:]); typ != iflaInfoData {
+		t.Errorf("nested attribute type, expected: %d, actual: %d", iflaInfoData, typ)
+	}
+}
+
+func TestRequest_Encode(t *testing.T) {
+	r := newRequest(syscall.RTM_NEWLINK, syscall.NLM_F_CREATE, ifInfomsg(7, syscall.IFF_UP, syscall.IFF_UP))
+	r.add(syscall.IFLA_MTU, uint32Attr(1500))
+	b := r.encode(3)
+
+	expected := syscall.NLMSG_HDRLEN + syscall.SizeofIfInfomsg + 8
+	if len(b) != expected {
+		t.Fatalf("length, expected: %d, actual: %d", expected, len(b))
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("messages, expected: 1, actual: %d", len(msgs))
+	}
+	header := msgs[0].Header
+	if header.Type != syscall.RTM_NEWLINK || header.Seq != 3 {
+		t.Errorf("header, actual: %+v", header)
+	}
+	if header.Flags != syscall.NLM_F_REQUEST|syscall.NLM_F_ACK|syscall.NLM_F_CREATE {
+		t.Errorf("flags, actual: %#x", header.Flags)
+	}
+	if index := binary.NativeEndian.Uint32(msgs[0].Data[4:]); index != 7 {
+		t.Errorf("index, expected: 7, actual: %d", index)
+	}
+
+	attrs, err := syscall.ParseNetlinkRouteAttr(&msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 1 || attrs[0].Attr.Type != syscall.IFLA_MTU || binary.NativeEndian.Uint32(attrs[0].Value) != 1500 {
+		t.Errorf("attributes, actual: %+v", attrs)
+	}
+}
@@ -0,0 +1,275 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"net"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"runtime"
+	"syscall"
+)
+
+// Mode selects how the network namespace of a container is set up.
+type Mode string
+
+const (
+	// ModeNone leaves the network namespace of a container with the loopback device only.
+	ModeNone Mode = "none"
+
+	// ModeBridge connects the network namespace of a container to a bridge of the host with a veth pair.
+	ModeBridge Mode = "bridge"
+
+	// ModeCNI connects the network namespace of a container by calling the plugins of a CNI network.
+	ModeCNI Mode = "cni"
+)
+
+// Attachment connects the network namespace of a container to the host.
+type Attachment interface {
+	// Attach connects the network namespace of the process with pid.
+	Attach(pid int) error
+
+	// Detach releases the connection of a stopped container.
+	Detach() error
+}
+
+// ContainerInterface is the name of the veth peer inside the container.
+const ContainerInterface = "eth0"
+
+// Config is the network configuration of the runtime.
+type Config struct {
+	// Mode selects how the network namespace of a container is set up
+	Mode Mode `mapstructure:"mode"`
+
+	// Bridge is the name of the host bridge, it is created if it doesn't exist
+	Bridge string `mapstructure:"bridge"`
+
+	// Subnet is the IPv4 subnet of the bridge in CIDR notation.
+	// The first address is assigned to the bridge and is the gateway of the containers.
+	Subnet string `mapstructure:"subnet"`
+
+	// MTU of the bridge and the veth pairs, the kernel default is used if it is 0
+	MTU int `mapstructure:"mtu"`
+
+	// CNI configures the plugins of the cni mode
+	CNI CNIConfig `mapstructure:"cni"`
+}
+
+// Validate checks the configuration of the mode.
+func (c Config) Validate() error {
+	switch c.Mode {
+	case ModeNone, "":
+		return nil
+	case ModeBridge:
+		if c.Bridge == "" {
+			return fmt.Errorf("network bridge is empty")
+		}
+		_, err := c.subnet()
+		return err
+	case ModeCNI:
+		return c.CNI.Validate()
+	}
+	return fmt.Errorf("unknown network mode %q", c.Mode)
+}
+
+// subnet parses the subnet, it has to be IPv4 with room for the gateway and at least one container.
+func (c Config) subnet() (*net.IPNet, error) {
+	_, subnet, err := net.ParseCIDR(c.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network subnet: %w", err)
+	}
+	if subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("network subnet %v is not IPv4", subnet)
+	}
+	if ones, _ := subnet.Mask.Size(); ones > 30 {
+		return nil, fmt.Errorf("network subnet %v is too small", subnet)
+	}
+	return subnet, nil
+}
+
+// Endpoint is the connection of a container to the host bridge.
+type Endpoint struct {
+	// Bridge is the name of the host bridge
+	Bridge string `json:"bridge"`
+
+	// HostInterface is the name of the veth of the host
+	HostInterface string `json:"hostInterface"`
+
+	// Address of the container in CIDR notation
+	Address string `json:"address"`
+
+	// Gateway is the address of the bridge in CIDR notation
+	Gateway string `json:"gateway"`
+
+	MTU int `json:"mtu,omitempty"`
+}
+
+// Allocate assigns the first free address of the subnet to the container with id.
+// used contains the addresses of the endpoints of the other containers.
+func (c Config) Allocate(id string, used []string) (*Endpoint, error) {
+	subnet, err := c.subnet()
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := subnet.Mask.Size()
+
+	taken := make(map[uint32]bool, len(used))
+	for _, address := range used {
+		if ip, _, err := net.ParseCIDR(address); err == nil && ip.To4() != nil {
+			taken[binary.BigEndian.Uint32(ip.To4())] = true
+		}
+	}
+
+	var (
+		network   = binary.BigEndian.Uint32(subnet.IP.To4())
+		broadcast = network | (1<<(bits-ones) - 1)
+		gateway   = network + 1
+	)
+	for ip := gateway + 1; ip < broadcast; ip++ {
+		if taken[ip] {
+			continue
+		}
+		return &Endpoint{
+			Bridge:        c.Bridge,
+			HostInterface: hostInterface(id),
+			Address:       cidr(ip, ones),
+			Gateway:       cidr(gateway, ones),
+			MTU:           c.MTU,
+		}, nil
+	}
+	return nil, fmt.Errorf("no free address in network subnet %v", subnet)
+}
+
+// Attach creates the veth pair of the endpoint with the peer in the network namespace of the process with pid.
+// The host veth is attached to the bridge and the peer gets the address and the default route via the gateway.
+func (e *Endpoint) Attach(pid int) error {
+	log := logger.Log().Named("network")
+	address, addressNet, err := net.ParseCIDR(e.Address)
+	if err != nil {
+		return err
+	}
+	addressNet.IP = address
+	gateway, _, err := net.ParseCIDR(e.Gateway)
+	if err != nil {
+		return err
+	}
+
+	bridge, err := e.ensureBridge()
+	if err != nil {
+		return fmt.Errorf("failed to set up bridge %v: %w", e.Bridge, err)
+	}
+
+	log.Debug("creating veth pair", zap.String("host", e.HostInterface), zap.Int("pid", pid))
+	if err = addVeth(e.HostInterface, ContainerInterface, pid, e.MTU); err != nil {
+		return fmt.Errorf("failed to create veth %v: %w", e.HostInterface, err)
+	}
+	host, err := net.InterfaceByName(e.HostInterface)
+	if err == nil {
+		err = linkSetMaster(host.Index, bridge.Index)
+	}
+	if err == nil {
+		err = linkSetUp(host.Index)
+	}
+	if err != nil {
+		_ = e.Detach()
+		return fmt.Errorf("failed to attach veth %v to bridge: %w", e.HostInterface, err)
+	}
+
+	log.Debug("configuring container interface", zap.String("address", e.Address))
+	if err = inNetworkNamespace(pid, func() error {
+		return configureInterface(ContainerInterface, addressNet, gateway)
+	}); err != nil {
+		_ = e.Detach()
+		return fmt.Errorf("failed to configure %v: %w", ContainerInterface, err)
+	}
+	return nil
+}
+
+// Detach deletes the host veth of the endpoint, which deletes its peer as well.
+// The veth pair is already gone if the network namespace of the container was destroyed.
+func (e *Endpoint) Detach() error {
+	err := linkDel(e.HostInterface)
+	if errors.Is(err, syscall.ENODEV) {
+		return nil
+	}
+	return err
+}
+
+// ensureBridge creates the bridge if it doesn't exist, assigns the gateway address and sets it up.
+func (e *Endpoint) ensureBridge() (*net.Interface, error) {
+	gateway, gatewayNet, err := net.ParseCIDR(e.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	gatewayNet.IP = gateway
+
+	// the bridge is shared by all containers, another runtime might create it concurrently
+	if err = addBridge(e.Bridge, e.MTU); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return nil, err
+	}
+	bridge, err := net.InterfaceByName(e.Bridge)
+	if err != nil {
+		return nil, err
+	}
+	if err = addAddr(bridge.Index, gatewayNet); err != nil && !errors.Is(err, syscall.EEXIST) {
+		return nil, err
+	}
+	return bridge, linkSetUp(bridge.Index)
+}
+
+// LoopbackUp sets the loopback device of the current network namespace up.
+func LoopbackUp() error {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		return err
+	}
+	return linkSetUp(lo.Index)
+}
+
+// configureInterface assigns the address to the interface with the name, sets it up and adds the default route.
+func configureInterface(name string, address *net.IPNet, gateway net.IP) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	if err = addAddr(iface.Index, address); err != nil {
+		return err
+	}
+	if err = linkSetUp(iface.Index); err != nil {
+		return err
+	}
+	return addDefaultRoute(gateway)
+}
+
+// inNetworkNamespace calls fn on a thread that joined the network namespace of the process with pid.
+// The thread is never unlocked, so it's terminated by the go runtime instead of being reused.
+func inNetworkNamespace(pid int, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := procfs.Root.Setns(procfs.Pid(pid), specs.NetworkNamespace); err != nil {
+			errCh <- err
+			return
+		}
+		errCh <- fn()
+	}()
+	return <-errCh
+}
+
+// hostInterface returns the name of the host veth of the container with id.
+// Interface names are limited to 15 characters, so the id is hashed.
+func hostInterface(id string) string {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("veth%08x", h.Sum32())
+}
+
+func cidr(ip uint32, ones int) string {
+	b := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(b, ip)
+	return fmt.Sprintf("%v/%d", b, ones)
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"empty", Config{}, true},
+		{"none", Config{Mode: ModeNone}, true},
+		{"bridge", Config{Mode: ModeBridge, Bridge: "roci0", Subnet: "10.77.0.0/16"}, true},
+		{"bridge without name", Config{Mode: ModeBridge, Subnet: "10.77.0.0/16"}, false},
+		{"invalid subnet", Config{Mode: ModeBridge, Bridge: "roci0", Subnet: "10.77.0.0"}, false},
+		{"ipv6 subnet", Config{Mode: ModeBridge, Bridge: "roci0", Subnet: "fd00::/64"}, false},
+		{"small subnet", Config{Mode: ModeBridge, Bridge: "roci0", Subnet: "10.77.0.0/31"}, false},
+		{"unknown mode", Config{Mode: "host"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate(), expected valid: %v, actual: %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestConfig_Allocate(t *testing.T) {
+	config := Config{Mode: ModeBridge, Bridge: "roci0", Subnet: "10.77.0.0/16", MTU: 1400}
+
+	endpoint, err := config.Allocate("abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Endpoint{Bridge: "roci0", HostInterface: hostInterface("abc"), Address: "10.77.0.2/16", Gateway: "10.77.0.1/16", MTU: 1400}
+	if *endpoint != expected {
+		t.Errorf("endpoint, expected: %+v, actual: %+v", expected, *endpoint)
+	}
+
+	endpoint, err = config.Allocate("def", []string{"10.77.0.2/16", "10.77.0.4/16", "invalid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Address != "10.77.0.3/16" {
+		t.Errorf("address, expected: 10.77.0.3/16, actual: %v", endpoint.Address)
+	}
+}
+
+func TestConfig_AllocateExhausted(t *testing.T) {
+	config := Config{Mode: ModeBridge, Bridge: "roci0", Subnet: "10.77.0.0/30"}
+
+	if _, err := config.Allocate("abc", []string{"10.77.0.2/30"}); err == nil {
+		t.Error("expected error for exhausted subnet")
+	}
+}
+
+func TestHostInterface(t *testing.T) {
+	name := hostInterface(strings.Repeat("a", 64))
+	if len(name) > 15 {
+		t.Errorf("interface name %v is longer than 15 characters", name)
+	}
+	if name == hostInterface("b") {
+		t.Error("expected different names for different ids")
+	}
+}
@@ -0,0 +1,64 @@
+// fakeplugin is a CNI plugin for the tests. It appends its invocations to the file of the "log" field
+// and returns the "address" field as result of ADD, chained plugins return the previous result.
+// The command of the "fail" field fails.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type config struct {
+	CNIVersion string          `json:"cniVersion"`
+	Name       string          `json:"name"`
+	Log        string          `json:"log"`
+	Address    string          `json:"address"`
+	Fail       string          `json:"fail"`
+	PrevResult json.RawMessage `json:"prevResult"`
+}
+
+func main() {
+	var conf config
+	if err := json.NewDecoder(os.Stdin).Decode(&conf); err != nil {
+		fail(conf, err.Error())
+	}
+
+	command := os.Getenv("CNI_COMMAND")
+	f, err := os.OpenFile(conf.Log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		fail(conf, err.Error())
+	}
+	fmt.Fprintln(f, strings.Join([]string{
+		command,
+		conf.Name,
+		conf.Address,
+		os.Getenv("CNI_CONTAINERID"),
+		os.Getenv("CNI_NETNS"),
+		os.Getenv("CNI_IFNAME"),
+		fmt.Sprint(conf.PrevResult != nil),
+	}, " "))
+	f.Close()
+
+	if conf.Fail == command {
+		fail(conf, "fake failure")
+	}
+	if command != "ADD" {
+		return
+	}
+	if conf.PrevResult != nil {
+		os.Stdout.Write(conf.PrevResult)
+		return
+	}
+	json.NewEncoder(os.Stdout).Encode(map[string]any{
+		"cniVersion": conf.CNIVersion,
+		"interfaces": []map[string]string{{"name": os.Getenv("CNI_IFNAME")}},
+		"ips":        []map[string]any{{"address": conf.Address, "interface": 0}},
+	})
+}
+
+func fail(conf config, msg string) {
+	json.NewEncoder(os.Stdout).Encode(map[string]any{"cniVersion": conf.CNIVersion, "code": 100, "msg": msg})
+	os.Exit(1)
+}
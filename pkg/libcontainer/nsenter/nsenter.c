@@ -0,0 +1,124 @@
+#define _GNU_SOURCE
+#include <errno.h>
+#include <sched.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+/* environment variables of nsenter.go */
+#define ENV_FDS "_ROCI_NSENTER_FDS"
+#define ENV_ROOT "_ROCI_NSENTER_ROOT"
+#define ENV_CLONE "_ROCI_NSENTER_CLONE"
+#define ENV_PID_FD "_ROCI_NSENTER_PID_FD"
+
+/* the failed step of nsenter, it is reported by nsenter.Err */
+const char *nsenter_op;
+int nsenter_fd = -1;
+int nsenter_errno;
+
+static void fail(const char *op, int fd)
+{
+	nsenter_op = op;
+	nsenter_fd = fd;
+	nsenter_errno = errno;
+}
+
+static int parse_fd(const char *s, char **end)
+{
+	long fd;
+
+	errno = 0;
+	fd = strtol(s, end, 10);
+	if (*end == s || errno != 0 || fd < 0 || fd > 0x7fffffff) {
+		errno = EBADF;
+		return -1;
+	}
+	return fd;
+}
+
+/*
+ * clone_namespaces creates the new namespaces of ENV_CLONE with a clone, which continues as the process.
+ * The clone is a sibling of the process, so the runtime can wait for it. Its pid is written to the fd of
+ * ENV_PID_FD by the process, which exits afterward.
+ */
+static void clone_namespaces(const char *flags)
+{
+	char *end;
+	unsigned long cloneflags;
+	int pid_fd;
+	long pid;
+
+	errno = 0;
+	cloneflags = strtoul(flags, &end, 10);
+	if (end == flags || errno != 0) {
+		errno = EINVAL;
+		fail("parse " ENV_CLONE, -1);
+		return;
+	}
+	if ((pid_fd = parse_fd(getenv(ENV_PID_FD) ?: "", &end)) < 0) {
+		fail("parse " ENV_PID_FD, -1);
+		return;
+	}
+
+	/* without a stack the clone continues on a copy of the stack like a fork */
+	pid = syscall(SYS_clone, CLONE_PARENT | cloneflags | SIGCHLD, 0, 0, 0, 0);
+	if (pid < 0) {
+		fail("clone namespaces", -1);
+		return;
+	}
+	if (pid > 0) {
+		dprintf(pid_fd, "%ld\n", pid);
+		_exit(0);
+	}
+	close(pid_fd);
+
+	/* the session isn't inherited as leader */
+	if (setsid() < 0)
+		fail("create session", -1);
+}
+
+/*
+ * nsenter joins the namespaces passed in ENV_FDS in order and changes the root to the directory of ENV_ROOT.
+ * Afterward the new namespaces of ENV_CLONE are created, so they are owned by a joined user namespace.
+ * It runs before the go runtime starts its threads, because user, mount and time namespaces can only be
+ * joined by a single-threaded process. Processes without the environment variables are not changed.
+ */
+__attribute__((constructor)) static void nsenter(void)
+{
+	const char *fds = getenv(ENV_FDS);
+	const char *root = getenv(ENV_ROOT);
+	const char *flags = getenv(ENV_CLONE);
+	char *end;
+	int fd;
+
+	for (const char *s = fds; s != NULL && *s != '\0'; s = end) {
+		if ((fd = parse_fd(s, &end)) < 0) {
+			fail("parse " ENV_FDS, -1);
+			return;
+		}
+		if (setns(fd, 0) < 0) {
+			fail("join namespace", fd);
+			return;
+		}
+		close(fd);
+		if (*end == ',')
+			end++;
+	}
+
+	if (root != NULL && *root != '\0') {
+		if ((fd = parse_fd(root, &end)) < 0) {
+			fail("parse " ENV_ROOT, -1);
+			return;
+		}
+		if (fchdir(fd) < 0 || chroot(".") < 0) {
+			fail("change root", fd);
+			return;
+		}
+		close(fd);
+	}
+
+	if (flags != NULL && *flags != '\0')
+		clone_namespaces(flags);
+}
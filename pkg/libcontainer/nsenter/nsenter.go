@@ -0,0 +1,97 @@
+// Package nsenter joins namespaces before the go runtime starts. The go runtime is multithreaded from the start,
+// but user, mount and time namespaces can only be joined by a single-threaded process. The constructor in
+// nsenter.c runs before the go runtime of every roci process that imports the package, so a re-executed
+// roci joins the namespaces it was passed before any go code runs.
+package nsenter
+
+/*
+extern const char *nsenter_op;
+extern int nsenter_fd;
+extern int nsenter_errno;
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// EnvFds contains the comma separated fds of the namespaces that are joined in order, see nsenter.c
+	EnvFds = "_ROCI_NSENTER_FDS"
+
+	// EnvRoot contains the fd of the directory that becomes the root after the namespaces were joined
+	EnvRoot = "_ROCI_NSENTER_ROOT"
+
+	// EnvClone contains the clone flags of the new namespaces that are created after the namespaces were joined
+	EnvClone = "_ROCI_NSENTER_CLONE"
+
+	// EnvPidFd contains the fd the pid of the clone is written to
+	EnvPidFd = "_ROCI_NSENTER_PID_FD"
+)
+
+// Configure makes the re-executed roci of cmd join the namespaces in their order and change its root to root,
+// if it is not nil. The files are appended to the extra files of cmd, they are closed after they were used.
+func Configure(cmd *exec.Cmd, namespaces []*os.File, root *os.File) {
+	fds := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, ns)
+		fds[i] = strconv.Itoa(2 + len(cmd.ExtraFiles))
+	}
+	if len(fds) > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", EnvFds, strings.Join(fds, ",")))
+	}
+	if root != nil {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, root)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%d", EnvRoot, 2+len(cmd.ExtraFiles)))
+	}
+}
+
+// Clone makes the re-executed roci of cmd create the new namespaces of flags with a clone after it joined the
+// namespaces, so a joined user namespace owns them. The clone continues as the process. It is a child of the
+// process that starts cmd, which reads its pid from r with ReadPid. w has to be closed after cmd was started.
+func Clone(cmd *exec.Cmd, flags uintptr) (r, w *os.File, err error) {
+	r, w, err = os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("%v=%d", EnvClone, flags),
+		fmt.Sprintf("%v=%d", EnvPidFd, 2+len(cmd.ExtraFiles)))
+	return r, w, nil
+}
+
+// ReadPid reads the pid of the clone from the pipe of Clone. It returns 0 if the process failed before the
+// clone, the process reports the error itself then.
+func ReadPid(r *os.File) (int, error) {
+	var pid int
+	_, err := fmt.Fscanln(r, &pid)
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	}
+	return pid, err
+}
+
+// Err returns why the constructor failed to join the namespaces, to change the root or to clone the namespaces. The process stays in
+// the namespaces that were joined before the error.
+func Err() error {
+	_ = os.Unsetenv(EnvFds)
+	_ = os.Unsetenv(EnvRoot)
+	_ = os.Unsetenv(EnvClone)
+	_ = os.Unsetenv(EnvPidFd)
+	if C.nsenter_op == nil {
+		return nil
+	}
+	op := C.GoString(C.nsenter_op)
+	if fd := int(C.nsenter_fd); fd >= 0 {
+		return fmt.Errorf("nsenter: failed to %v, fd %d: %w", op, fd, syscall.Errno(C.nsenter_errno))
+	}
+	return fmt.Errorf("nsenter: failed to %v: %w", op, syscall.Errno(C.nsenter_errno))
+}
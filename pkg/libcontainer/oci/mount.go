@@ -1,19 +1,92 @@
 package oci
 
 import (
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"strings"
 	"syscall"
 )
 
-func MountOptions(mount *specs.Mount) (flags int, opts string) {
-	return ParseMountOptions(mount.Options)
+// attributes of mount_setattr(2), MOUNT_ATTR_*
+const (
+	mountAttrRdonly      = 0x1
+	mountAttrNosuid      = 0x2
+	mountAttrNodev       = 0x4
+	mountAttrNoexec      = 0x8
+	mountAttrAtime       = 0x70 // MOUNT_ATTR__ATIME, the mask of the atime attributes
+	mountAttrRelatime    = 0x0
+	mountAttrNoatime     = 0x10
+	mountAttrStrictatime = 0x20
+	mountAttrNodiratime  = 0x80
+	mountAttrNosymfollow = 0x200000
+)
+
+// propagationFlags are the flags that change the propagation type of a mount
+const propagationFlags = syscall.MS_SHARED | syscall.MS_PRIVATE | syscall.MS_SLAVE | syscall.MS_UNBINDABLE
+
+// MountPlan describes the calls that perform a mount of the spec. The kernel rejects propagation flags
+// combined with other flags and ignores the flags of a new bind mount except MS_REC, so the mount is split
+// into the base mount, one call per propagation type and a remount of bind mounts.
+type MountPlan struct {
+	Flags int    // flags of the base mount
+	Data  string // filesystem specific options of the base mount
+
+	// Propagation are the propagation types in the order of the options, each is set by its own call
+	Propagation []int
+
+	// RemountFlags are the flags that a bind mount gets by a remount after the base mount
+	RemountFlags int
+
+	// AttrSet and AttrClear are the attributes that are set and cleared recursively with mount_setattr,
+	// they come from the recursive options like rro
+	AttrSet, AttrClear uint64
+
+	// TmpCopyUp copies the content of the destination into the new tmpfs
+	TmpCopyUp bool
+}
+
+// Bind checks whether the mount is a bind mount.
+func (p *MountPlan) Bind() bool {
+	return p.Flags&syscall.MS_BIND != 0
+}
+
+// Recursive checks whether the mount has recursive attributes.
+func (p *MountPlan) Recursive() bool {
+	return p.AttrSet != 0 || p.AttrClear != 0
+}
+
+// MountOptions plans the mount of the spec based on its options.
+func MountOptions(mount *specs.Mount) *MountPlan {
+	plan := ParseMountOptions(mount.Options)
+	if mount.Type == "bind" {
+		plan.Flags |= syscall.MS_BIND
+	}
+	if plan.Bind() {
+		plan.RemountFlags = plan.Flags &^ (syscall.MS_BIND | syscall.MS_REC | syscall.MS_REMOUNT)
+		plan.Flags &= syscall.MS_BIND | syscall.MS_REC
+	}
+	if mount.Type != "tmpfs" {
+		plan.TmpCopyUp = false
+	}
+	return plan
 }
 
-func ParseMountOptions(options []string) (flags int, opts string) {
-	var data []string
+// ParseMountOptions converts the options of a mount into mount flags, propagation types and recursive
+// attributes. Unknown options are passed to the filesystem as data.
+func ParseMountOptions(options []string) *MountPlan {
+	var (
+		plan  = &MountPlan{}
+		flags int
+		data  []string
+	)
 
 	for _, opt := range options {
+		if set, clear, ok := recursiveAttr(opt); ok {
+			plan.AttrSet = plan.AttrSet&^clear | set
+			plan.AttrClear = plan.AttrClear&^set | clear
+			continue
+		}
+
 		switch opt {
 		case "async":
 			flags &= ^syscall.MS_SYNCHRONOUS
@@ -52,7 +125,7 @@ func ParseMountOptions(options []string) (flags int, opts string) {
 		case "nosuid":
 			flags |= syscall.MS_NOSUID
 		case "private":
-			flags |= syscall.MS_PRIVATE
+			plan.Propagation = append(plan.Propagation, syscall.MS_PRIVATE)
 		case "rbind":
 			flags |= syscall.MS_BIND | syscall.MS_REC
 		case "relatime":
@@ -62,21 +135,21 @@ func ParseMountOptions(options []string) (flags int, opts string) {
 		case "ro":
 			flags |= syscall.MS_RDONLY
 		case "rprivate":
-			flags |= syscall.MS_PRIVATE | syscall.MS_REC
+			plan.Propagation = append(plan.Propagation, syscall.MS_PRIVATE|syscall.MS_REC)
 		case "rshared":
-			flags |= syscall.MS_SHARED | syscall.MS_REC
+			plan.Propagation = append(plan.Propagation, syscall.MS_SHARED|syscall.MS_REC)
 		case "rslave":
-			flags |= syscall.MS_SLAVE | syscall.MS_REC
+			plan.Propagation = append(plan.Propagation, syscall.MS_SLAVE|syscall.MS_REC)
 		case "runbindable":
-			flags |= syscall.MS_UNBINDABLE | syscall.MS_REC
+			plan.Propagation = append(plan.Propagation, syscall.MS_UNBINDABLE|syscall.MS_REC)
 		case "rw":
 			flags &= ^syscall.MS_RDONLY
 		case "shared":
-			flags |= syscall.MS_SHARED
+			plan.Propagation = append(plan.Propagation, syscall.MS_SHARED)
 		case "silent":
 			flags |= syscall.MS_SILENT
 		case "slave":
-			flags |= syscall.MS_SLAVE
+			plan.Propagation = append(plan.Propagation, syscall.MS_SLAVE)
 		case "strictatime":
 			flags |= syscall.MS_STRICTATIME
 		case "suid":
@@ -84,13 +157,81 @@ func ParseMountOptions(options []string) (flags int, opts string) {
 		case "sync":
 			flags |= syscall.MS_SYNCHRONOUS
 		case "tmpcopyup":
-			data = append(data, "tmpcopyup")
+			plan.TmpCopyUp = true
 		case "unbindable":
-			flags |= syscall.MS_UNBINDABLE
+			plan.Propagation = append(plan.Propagation, syscall.MS_UNBINDABLE)
 		default:
 			data = append(data, opt)
 		}
 	}
 
-	return flags, strings.Join(data, ",")
+	plan.Flags = flags &^ propagationFlags
+	plan.Data = strings.Join(data, ",")
+	return plan
+}
+
+// recursiveAttr converts a recursive option into the mount_setattr attributes it sets and clears.
+// The atime attributes share a field that is always cleared completely, see mount_setattr(2).
+func recursiveAttr(opt string) (set, clear uint64, ok bool) {
+	switch opt {
+	case "rro":
+		return mountAttrRdonly, 0, true
+	case "rrw":
+		return 0, mountAttrRdonly, true
+	case "rnosuid":
+		return mountAttrNosuid, 0, true
+	case "rsuid":
+		return 0, mountAttrNosuid, true
+	case "rnodev":
+		return mountAttrNodev, 0, true
+	case "rdev":
+		return 0, mountAttrNodev, true
+	case "rnoexec":
+		return mountAttrNoexec, 0, true
+	case "rexec":
+		return 0, mountAttrNoexec, true
+	case "rnodiratime":
+		return mountAttrNodiratime, 0, true
+	case "rdiratime":
+		return 0, mountAttrNodiratime, true
+	case "rrelatime":
+		return mountAttrRelatime, mountAttrAtime, true
+	case "rnoatime":
+		return mountAttrNoatime, mountAttrAtime, true
+	case "rstrictatime", "rnorelatime":
+		return mountAttrStrictatime, mountAttrAtime, true
+	case "ratime", "rnostrictatime":
+		// the atime attributes are a value, so clearing one falls back to relatime, the default of the kernel
+		return mountAttrRelatime, mountAttrAtime, true
+	case "rnosymfollow":
+		return mountAttrNosymfollow, 0, true
+	case "rsymfollow":
+		return 0, mountAttrNosymfollow, true
+	}
+	return 0, 0, false
+}
+
+// RootfsPropagation converts the rootfs propagation of the spec into mount flags.
+// The propagation is applied to the root mount of the mount namespace, the recursive values and the
+// default rslave apply it to all mounts below as well.
+func RootfsPropagation(propagation string) (flags int, err error) {
+	switch propagation {
+	case "", "rslave":
+		return syscall.MS_SLAVE | syscall.MS_REC, nil
+	case "slave":
+		return syscall.MS_SLAVE, nil
+	case "rshared":
+		return syscall.MS_SHARED | syscall.MS_REC, nil
+	case "shared":
+		return syscall.MS_SHARED, nil
+	case "rprivate":
+		return syscall.MS_PRIVATE | syscall.MS_REC, nil
+	case "private":
+		return syscall.MS_PRIVATE, nil
+	case "runbindable":
+		return syscall.MS_UNBINDABLE | syscall.MS_REC, nil
+	case "unbindable":
+		return syscall.MS_UNBINDABLE, nil
+	}
+	return 0, fmt.Errorf("invalid rootfs propagation %q", propagation)
 }
@@ -0,0 +1,99 @@
+package oci
+
+import (
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestRootfsPropagation(t *testing.T) {
+	tests := []struct {
+		propagation string
+		expected    int
+	}{
+		{"", syscall.MS_SLAVE | syscall.MS_REC},
+		{"slave", syscall.MS_SLAVE},
+		{"rslave", syscall.MS_SLAVE | syscall.MS_REC},
+		{"shared", syscall.MS_SHARED},
+		{"rshared", syscall.MS_SHARED | syscall.MS_REC},
+		{"private", syscall.MS_PRIVATE},
+		{"rprivate", syscall.MS_PRIVATE | syscall.MS_REC},
+		{"unbindable", syscall.MS_UNBINDABLE},
+		{"runbindable", syscall.MS_UNBINDABLE | syscall.MS_REC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.propagation, func(t *testing.T) {
+			flags, err := RootfsPropagation(tt.propagation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flags != tt.expected {
+				t.Errorf("RootfsPropagation(%q), expected: %#x, actual: %#x", tt.propagation, tt.expected, flags)
+			}
+		})
+	}
+
+	if _, err := RootfsPropagation("foo"); err == nil {
+		t.Error("expected error for invalid propagation")
+	}
+}
+
+func TestMountOptions(t *testing.T) {
+	plan := MountOptions(&specs.Mount{Type: "tmpfs", Options: []string{"nosuid", "rshared", "private", "mode=755", "tmpcopyup"}})
+	if plan.Flags != syscall.MS_NOSUID {
+		t.Errorf("flags, expected: %#x, actual: %#x", syscall.MS_NOSUID, plan.Flags)
+	}
+	if plan.Data != "mode=755" {
+		t.Errorf("data, expected: %q, actual: %q", "mode=755", plan.Data)
+	}
+	if expected := []int{syscall.MS_SHARED | syscall.MS_REC, syscall.MS_PRIVATE}; !slices.Equal(plan.Propagation, expected) {
+		t.Errorf("propagation, expected: %v, actual: %v", expected, plan.Propagation)
+	}
+	if !plan.TmpCopyUp {
+		t.Error("expected tmpcopyup")
+	}
+
+	plan = MountOptions(&specs.Mount{Type: "bind", Options: []string{"rbind", "ro", "nodev", "tmpcopyup"}})
+	if plan.Flags != syscall.MS_BIND|syscall.MS_REC {
+		t.Errorf("bind flags, expected: %#x, actual: %#x", syscall.MS_BIND|syscall.MS_REC, plan.Flags)
+	}
+	if plan.RemountFlags != syscall.MS_RDONLY|syscall.MS_NODEV {
+		t.Errorf("remount flags, expected: %#x, actual: %#x", syscall.MS_RDONLY|syscall.MS_NODEV, plan.RemountFlags)
+	}
+	if plan.TmpCopyUp {
+		t.Error("expected tmpcopyup to be ignored for bind mounts")
+	}
+}
+
+func TestParseMountOptions_Recursive(t *testing.T) {
+	tests := []struct {
+		options      []string
+		set, clear   uint64
+		dataExpected string
+	}{
+		{[]string{"rro", "rnosuid"}, mountAttrRdonly | mountAttrNosuid, 0, ""},
+		{[]string{"rro", "rrw"}, 0, mountAttrRdonly, ""},
+		{[]string{"rnoatime"}, mountAttrNoatime, mountAttrAtime, ""},
+		{[]string{"rnoatime", "rrelatime"}, mountAttrRelatime, mountAttrAtime, ""},
+		{[]string{"rnodev", "size=1m"}, mountAttrNodev, 0, "size=1m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.options, ","), func(t *testing.T) {
+			plan := ParseMountOptions(tt.options)
+			if plan.AttrSet != tt.set || plan.AttrClear != tt.clear {
+				t.Errorf("attributes, expected: %#x %#x, actual: %#x %#x", tt.set, tt.clear, plan.AttrSet, plan.AttrClear)
+			}
+			if plan.Data != tt.dataExpected {
+				t.Errorf("data, expected: %q, actual: %q", tt.dataExpected, plan.Data)
+			}
+			if plan.Flags != 0 {
+				t.Errorf("flags, expected: 0, actual: %#x", plan.Flags)
+			}
+		})
+	}
+}
@@ -11,6 +11,10 @@ const (
 
 var Version = fmt.Sprintf("%v%v", specs.Version, reducedOciVersionTag)
 
+// StatePaused indicates that the processes of the container are frozen.
+// It is not defined by the OCI runtime spec, but reported by runc and expected by container managers.
+const StatePaused specs.ContainerState = "paused"
+
 func Rootfs(spec *specs.Root) string {
 	if spec == nil {
 		return ""
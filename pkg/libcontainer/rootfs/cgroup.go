@@ -0,0 +1,85 @@
+package rootfs
+
+import (
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"path/filepath"
+	"roci/pkg/libcontainer/cgroups"
+	"roci/pkg/libcontainer/oci"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"syscall"
+)
+
+// isCgroupMount checks whether the mount of the spec mounts the cgroup hierarchy.
+// Specs usually use the cgroup v1 type, roci only supports cgroup v2 and mounts it for both types.
+func isCgroupMount(mount specs.Mount) bool {
+	return mount.Type == "cgroup" || mount.Type == "cgroup2"
+}
+
+// mountCgroup mounts the cgroup of the init process read-only at the destination of the mount, so processes
+// of the container can read their limits without being able to change them.
+// In a cgroup namespace a new cgroup2 mount is rooted at the cgroup the runtime moved the init process into.
+// Without one, the cgroup of the init process is bind mounted from the hierarchy of the host instead.
+func mountCgroup(rootfs string, mount specs.Mount, cgroupNamespace bool) error {
+	dir, err := mkdirAllInRoot(rootfs, mount.Destination)
+	if err != nil {
+		return err
+	}
+	dir.Close()
+
+	// the cgroup is mounted with the flags of the spec, a bind mount of the spec has them as remount flags
+	plan := oci.MountOptions(&mount)
+	flags := plan.Flags | plan.RemountFlags
+	flags &^= syscall.MS_BIND | syscall.MS_REC
+	flags |= syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC
+
+	if cgroupNamespace {
+		logger.Log().Debug("mounting cgroup2", zap.String("dest", mount.Destination))
+		return performMount("cgroup2", rootfs, mount.Destination, "cgroup2", &oci.MountPlan{Flags: flags})
+	}
+
+	cgroup, err := procfs.Root.Cgroup(procfs.PidSelf)
+	if err != nil {
+		return err
+	}
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(cgroups.DefaultMountpoint, &stat); err != nil {
+		return err
+	}
+	if stat.Type != cgroup2SuperMagic {
+		return fmt.Errorf("no cgroup v2 hierarchy mounted at %v", cgroups.DefaultMountpoint)
+	}
+	source := filepath.Join(cgroups.DefaultMountpoint, cgroup)
+	logger.Log().Debug("bind mounting cgroup", zap.String("source", source), zap.String("dest", mount.Destination))
+	// the flags of a bind mount can only be changed by a remount
+	return performMount(source, rootfs, mount.Destination, "bind", &oci.MountPlan{
+		Flags:        syscall.MS_BIND | syscall.MS_REC,
+		RemountFlags: flags,
+	})
+}
+
+// lockedFlags returns the flags of the mount at path that a bind mount of it has to keep when it is remounted,
+// because a user namespace isn't allowed to clear them, see mount_namespaces(7).
+func lockedFlags(path string) int {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0
+	}
+	// the ST_ flags of these options have the same values as the MS_ flags
+	const locked = syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME
+	flags := int(stat.Flags) & locked
+	if stat.Flags&stRelatime != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	return flags
+}
+
+const (
+	// stRelatime is the ST_RELATIME flag of statfs(2), it differs from MS_RELATIME
+	stRelatime = 0x1000
+
+	// cgroup2SuperMagic is the filesystem type of cgroup v2, see statfs(2)
+	cgroup2SuperMagic = 0x63677270
+)
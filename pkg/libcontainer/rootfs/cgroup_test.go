@@ -0,0 +1,28 @@
+package rootfs
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestIsCgroupMount(t *testing.T) {
+	for typ, expected := range map[string]bool{"cgroup": true, "cgroup2": true, "tmpfs": false, "bind": false} {
+		if actual := isCgroupMount(specs.Mount{Destination: "/sys/fs/cgroup", Type: typ}); actual != expected {
+			t.Errorf("isCgroupMount(%v), expected: %v, actual: %v", typ, expected, actual)
+		}
+	}
+}
+
+func TestLockedFlags(t *testing.T) {
+	if flags := lockedFlags("/does/not/exist"); flags != 0 {
+		t.Errorf("flags of missing path, expected: 0, actual: %#x", flags)
+	}
+
+	const known = syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+	if flags := lockedFlags(t.TempDir()); flags&^known != 0 {
+		t.Errorf("unexpected flags %#x", flags&^known)
+	}
+}
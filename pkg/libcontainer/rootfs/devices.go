@@ -0,0 +1,191 @@
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"roci/pkg/libcontainer/oci"
+	"roci/pkg/logger"
+	"syscall"
+)
+
+// DevPtsMount is mounted if the spec has no mount at /dev/pts.
+// The new instance keeps the ptys of the container separated from the ones of the host.
+var DevPtsMount = specs.Mount{
+	Destination: "/dev/pts",
+	Type:        "devpts",
+	Source:      "devpts",
+	Options:     []string{"nosuid", "noexec", "newinstance", "ptmxmode=0666", "mode=0620"},
+}
+
+// DevShmMount is mounted if the spec has no mount at /dev/shm.
+var DevShmMount = specs.Mount{
+	Destination: "/dev/shm",
+	Type:        "tmpfs",
+	Source:      "shm",
+	Options:     []string{"nosuid", "noexec", "nodev", "mode=1777", "size=65536k"},
+}
+
+var defaultDeviceMode os.FileMode = 0o666
+
+// DefaultDevices are created in every container, see the default devices of the OCI runtime spec.
+// /dev/ptmx is a symlink to the ptmx of /dev/pts instead of a device node.
+var DefaultDevices = []specs.LinuxDevice{
+	{Path: "/dev/null", Type: "c", Major: 1, Minor: 3, FileMode: &defaultDeviceMode},
+	{Path: "/dev/zero", Type: "c", Major: 1, Minor: 5, FileMode: &defaultDeviceMode},
+	{Path: "/dev/full", Type: "c", Major: 1, Minor: 7, FileMode: &defaultDeviceMode},
+	{Path: "/dev/random", Type: "c", Major: 1, Minor: 8, FileMode: &defaultDeviceMode},
+	{Path: "/dev/urandom", Type: "c", Major: 1, Minor: 9, FileMode: &defaultDeviceMode},
+	{Path: "/dev/tty", Type: "c", Major: 5, Minor: 0, FileMode: &defaultDeviceMode},
+}
+
+// specDevices returns the default devices followed by the devices of the spec.
+// A device of the spec replaces the default device with the same path.
+func specDevices(spec *specs.Spec) []specs.LinuxDevice {
+	var devices []specs.LinuxDevice
+	if spec.Linux != nil {
+		devices = spec.Linux.Devices
+	}
+
+	result := make([]specs.LinuxDevice, 0, len(DefaultDevices)+len(devices))
+	for _, device := range DefaultDevices {
+		if !hasDevice(devices, device.Path) {
+			result = append(result, device)
+		}
+	}
+	return append(result, devices...)
+}
+
+// hasDevice checks whether one of the devices has the path.
+func hasDevice(devices []specs.LinuxDevice, path string) bool {
+	for _, device := range devices {
+		if filepath.Clean(device.Path) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// createDevs creates the device nodes in the rootfs. Device nodes can't be created inside of a
+// user namespace, so with bind or if mknod isn't permitted the devices of the host are bind mounted instead.
+func createDevs(rootfs string, devices []specs.LinuxDevice, bind bool) (err error) {
+	for _, device := range devices {
+		if err = createDev(rootfs, device, bind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createDev creates the device in its directory, which is resolved inside of the rootfs.
+func createDev(rootfs string, device specs.LinuxDevice, bind bool) error {
+	dir, err := mkdirAllInRoot(rootfs, filepath.Dir(device.Path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	dest := pathIn(dir, filepath.Base(device.Path))
+
+	if !bind {
+		err = mknodDevice(dest, device)
+		if !errors.Is(err, syscall.EPERM) {
+			if err != nil {
+				return fmt.Errorf("failed to create device %v: %w", device.Path, err)
+			}
+			return nil
+		}
+		logger.Log().Named("devices").Debug("mknod not permitted, falling back to bind mount", zap.String("path", device.Path))
+	}
+
+	if err = bindDevice(rootfs, dest, device); err != nil {
+		return fmt.Errorf("failed to bind mount device %v: %w", device.Path, err)
+	}
+	return nil
+}
+
+// mknodDevice creates the device node at dest with the mode, uid and gid of the device.
+// An existing file at dest is kept.
+func mknodDevice(dest string, device specs.LinuxDevice) error {
+	fileType, err := deviceType(device.Type)
+	if err != nil {
+		return err
+	}
+
+	perm := defaultDeviceMode
+	if device.FileMode != nil {
+		perm = device.FileMode.Perm()
+	}
+
+	err = syscall.Mknod(dest, fileType|uint32(perm), mkdev(device.Major, device.Minor))
+	if err != nil {
+		if errors.Is(err, syscall.EEXIST) {
+			return nil
+		}
+		return err
+	}
+
+	// the mode passed to mknod is masked by the umask, the node was just created so it isn't a symlink
+	if err = syscall.Chmod(dest, uint32(perm)); err != nil {
+		return err
+	}
+
+	var uid, gid uint32
+	if device.UID != nil {
+		uid = *device.UID
+	}
+	if device.GID != nil {
+		gid = *device.GID
+	}
+	return syscall.Lchown(dest, int(uid), int(gid))
+}
+
+// bindDevice bind mounts the device of the host onto an empty file at dest.
+// An existing file at dest is used as mount point, it isn't opened because it might be a device.
+// A symlink at dest is resolved inside of the rootfs by the mount.
+func bindDevice(rootfs, dest string, device specs.LinuxDevice) error {
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_RDONLY, 0o644)
+	switch {
+	case err == nil:
+		f.Close()
+	case !errors.Is(err, os.ErrExist):
+		return err
+	}
+
+	return performMount(device.Path, rootfs, device.Path, "bind", &oci.MountPlan{Flags: syscall.MS_BIND})
+}
+
+// setupPtmx replaces /dev/ptmx of the rootfs with a symlink to the ptmx of /dev/pts.
+func setupPtmx(rootfs string) error {
+	dev, err := openInRoot(rootfs, "/dev", syscall.O_DIRECTORY)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	ptmx := pathIn(dev, "ptmx")
+	if err = os.Remove(ptmx); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Symlink("pts/ptmx", ptmx)
+}
+
+// deviceType returns the file type bits of the device type, see mknod(2).
+func deviceType(t string) (uint32, error) {
+	switch t {
+	case "c", "u":
+		return syscall.S_IFCHR, nil
+	case "b":
+		return syscall.S_IFBLK, nil
+	case "p":
+		return syscall.S_IFIFO, nil
+	}
+	return 0, fmt.Errorf("unsupported device type %q", t)
+}
+
+// mkdev encodes the major and minor number of a device like makedev(3).
+func mkdev(major, minor int64) int {
+	return int((minor & 0xff) | (major&0xfff)<<8 | (minor&^0xff)<<12 | (major&^0xfff)<<32)
+}
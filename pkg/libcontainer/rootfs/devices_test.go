@@ -0,0 +1,122 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestMkdev(t *testing.T) {
+	tests := []struct {
+		major, minor int64
+		expected     int
+	}{
+		{1, 3, 0x103},
+		{5, 0, 0x500},
+		{259, 65536, 0x1001_0300},
+		{4096, 1, 0x1_0000_0000_001},
+	}
+	for _, test := range tests {
+		if actual := mkdev(test.major, test.minor); actual != test.expected {
+			t.Errorf("mkdev(%d, %d), expected: %#x, actual: %#x", test.major, test.minor, test.expected, actual)
+		}
+	}
+}
+
+func TestSpecDevices(t *testing.T) {
+	mode := os.FileMode(0o600)
+	spec := &specs.Spec{Linux: &specs.Linux{Devices: []specs.LinuxDevice{
+		{Path: "/dev/null", Type: "c", Major: 1, Minor: 3, FileMode: &mode},
+		{Path: "/dev/fuse", Type: "c", Major: 10, Minor: 229},
+	}}}
+
+	devices := specDevices(spec)
+	if len(devices) != len(DefaultDevices)+1 {
+		t.Fatalf("devices, expected: %d, actual: %d", len(DefaultDevices)+1, len(devices))
+	}
+
+	var null int
+	for _, device := range devices {
+		if device.Path == "/dev/null" {
+			null++
+			if *device.FileMode != mode {
+				t.Errorf("/dev/null mode, expected: %v, actual: %v", mode, *device.FileMode)
+			}
+		}
+	}
+	if null != 1 {
+		t.Errorf("/dev/null, expected: 1, actual: %d", null)
+	}
+	if !hasDevice(devices, "/dev/fuse") {
+		t.Error("expected /dev/fuse")
+	}
+
+	if len(specDevices(&specs.Spec{})) != len(DefaultDevices) {
+		t.Error("expected the default devices without linux config")
+	}
+}
+
+func TestDeviceType(t *testing.T) {
+	for typ, expected := range map[string]uint32{"c": syscall.S_IFCHR, "u": syscall.S_IFCHR, "b": syscall.S_IFBLK, "p": syscall.S_IFIFO} {
+		actual, err := deviceType(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("type %v, expected: %o, actual: %o", typ, expected, actual)
+		}
+	}
+	if _, err := deviceType("x"); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+}
+
+func TestMknodDevice(t *testing.T) {
+	var (
+		dest     = filepath.Join(t.TempDir(), "null")
+		mode     = os.FileMode(0o640)
+		uid, gid = uint32(1000), uint32(1001)
+	)
+	err := mknodDevice(dest, specs.LinuxDevice{Path: "/dev/null", Type: "c", Major: 1, Minor: 3, FileMode: &mode, UID: &uid, GID: &gid})
+	if err != nil {
+		t.Skipf("mknod not permitted: %v", err)
+	}
+
+	var stat syscall.Stat_t
+	if err = syscall.Stat(dest, &stat); err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode != syscall.S_IFCHR|uint32(mode) {
+		t.Errorf("mode, expected: %o, actual: %o", syscall.S_IFCHR|uint32(mode), stat.Mode)
+	}
+	if stat.Rdev != uint64(mkdev(1, 3)) {
+		t.Errorf("rdev, expected: %#x, actual: %#x", mkdev(1, 3), stat.Rdev)
+	}
+	if stat.Uid != uid || stat.Gid != gid {
+		t.Errorf("owner, expected: %d:%d, actual: %d:%d", uid, gid, stat.Uid, stat.Gid)
+	}
+}
+
+func TestSetupPtmx(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.Mkdir(filepath.Join(rootfs, "dev"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "dev/ptmx"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setupPtmx(rootfs); err != nil {
+		t.Fatal(err)
+	}
+	link, err := os.Readlink(filepath.Join(rootfs, "dev/ptmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "pts/ptmx" {
+		t.Errorf("link, expected: pts/ptmx, actual: %v", link)
+	}
+}
@@ -0,0 +1,275 @@
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"roci/pkg/libcontainer/oci"
+	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"slices"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// sysMountSetattr is the number of mount_setattr(2), it is the same on all architectures
+	sysMountSetattr = 442
+
+	// atRecursive applies mount_setattr to the whole mount tree, AT_RECURSIVE
+	atRecursive = 0x8000
+
+	// atFdcwd resolves relative paths against the working directory, AT_FDCWD
+	atFdcwd = -100
+)
+
+// MountPolicy is the mount configuration of the runtime.
+type MountPolicy struct {
+	// Tolerate are the destinations of spec mounts whose failure is only logged, the container starts
+	// without them. Any other failed mount fails the container.
+	Tolerate []string `mapstructure:"tolerate" json:"tolerate,omitempty"`
+}
+
+// Validate checks that the tolerated destinations are absolute.
+func (p MountPolicy) Validate() error {
+	for _, destination := range p.Tolerate {
+		if !filepath.IsAbs(destination) {
+			return fmt.Errorf("tolerated mount destination %q is not absolute", destination)
+		}
+	}
+	return nil
+}
+
+// Tolerates checks whether a failed mount at the destination is tolerated.
+func (p MountPolicy) Tolerates(destination string) bool {
+	return slices.ContainsFunc(p.Tolerate, func(tolerated string) bool {
+		return filepath.Clean(tolerated) == filepath.Clean(destination)
+	})
+}
+
+// mountAttr is struct mount_attr of mount_setattr(2).
+type mountAttr struct {
+	attrSet     uint64
+	attrClr     uint64
+	propagation uint64
+	usernsFd    uint64
+}
+
+// performMount executes the plan of a mount at the destination inside of the rootfs. The base mount is followed
+// by the propagation types, the remount that applies the flags of a bind mount and the recursive attributes.
+// The base mount is undone if one of the following calls fails.
+// The destination is resolved inside of the rootfs and mounted through its fd, so a symlink of the rootfs
+// can't send the mount to the host.
+func performMount(source, rootfs, destination, fstype string, plan *oci.MountPlan) (err error) {
+	target, err := openInRoot(rootfs, destination, 0)
+	if err != nil {
+		return err
+	}
+	if plan.TmpCopyUp {
+		err = mountTmpCopyUp(source, fdPath(target), plan)
+	} else {
+		err = syscall.Mount(source, fdPath(target), fstype, uintptr(plan.Flags), plan.Data)
+	}
+	target.Close()
+	if err != nil {
+		return err
+	}
+
+	// the fd refers to the directory below the new mount, the mount itself is reached by a new lookup
+	if target, err = openInRoot(rootfs, destination, 0); err != nil {
+		return err
+	}
+	defer target.Close()
+	mountPoint := fdPath(target)
+	defer func() {
+		if err != nil {
+			_ = syscall.Unmount(mountPoint, syscall.MNT_DETACH)
+		}
+	}()
+
+	for _, propagation := range plan.Propagation {
+		if err = syscall.Mount("", mountPoint, "", uintptr(propagation), ""); err != nil {
+			return fmt.Errorf("failed to set propagation of %v: %w", destination, err)
+		}
+	}
+
+	if plan.Bind() && plan.RemountFlags != 0 {
+		flags := syscall.MS_BIND | syscall.MS_REMOUNT | plan.RemountFlags | lockedFlags(mountPoint)
+		if err = syscall.Mount("", mountPoint, "", uintptr(flags), ""); err != nil {
+			return fmt.Errorf("failed to remount bind mount %v: %w", destination, err)
+		}
+	}
+
+	if plan.Recursive() {
+		if err = mountSetattr(mountPoint, plan.AttrSet, plan.AttrClear); err != nil {
+			return fmt.Errorf("failed to set recursive attributes of %v: %w", destination, err)
+		}
+	}
+	return nil
+}
+
+// mountTmpCopyUp mounts a tmpfs that contains the content of the destination. The tmpfs is mounted on a
+// temporary directory first and moved onto the destination after the content was copied.
+// The temporary directory is a private bind mount, because mounts below shared mounts can't be moved.
+func mountTmpCopyUp(source, destination string, plan *oci.MountPlan) (err error) {
+	tmp, err := os.MkdirTemp("", "roci-tmpcopyup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err = syscall.Mount(tmp, tmp, "bind", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+	defer syscall.Unmount(tmp, syscall.MNT_DETACH)
+	if err = syscall.Mount("", tmp, "", syscall.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+
+	if err = syscall.Mount(source, tmp, "tmpfs", uintptr(plan.Flags), plan.Data); err != nil {
+		return err
+	}
+	logger.Log().Debug("copying up into tmpfs", zap.String("dest", destination))
+	// the trailing slash makes the walk follow a destination in procfs to its directory
+	if err = copyDir(destination+"/", tmp); err != nil {
+		_ = syscall.Unmount(tmp, syscall.MNT_DETACH)
+		return fmt.Errorf("failed to copy up %v: %w", destination, err)
+	}
+	if err = syscall.Mount(tmp, destination, "", syscall.MS_MOVE, ""); err != nil {
+		_ = syscall.Unmount(tmp, syscall.MNT_DETACH)
+		return err
+	}
+	return nil
+}
+
+// copyDir copies the tree of src into the existing directory dst. Ownership and permissions are kept,
+// symlinks and special files are recreated instead of followed.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("no stat of %v", path)
+		}
+
+		switch {
+		case rel == ".":
+		case d.IsDir():
+			err = os.Mkdir(target, 0o700)
+		case d.Type().IsRegular():
+			err = copyFile(path, target)
+		case d.Type()&fs.ModeSymlink != 0:
+			var link string
+			if link, err = os.Readlink(path); err == nil {
+				err = os.Symlink(link, target)
+			}
+		default:
+			err = syscall.Mknod(target, stat.Mode, int(stat.Rdev))
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = os.Lchown(target, int(stat.Uid), int(stat.Gid)); err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		// chown clears the setuid and setgid bits, so the mode is set afterward
+		return syscall.Chmod(target, stat.Mode&0o7777)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// mountSetattr sets and clears the attributes of the mount at path and all mounts below it.
+func mountSetattr(path string, set, clear uint64) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	var (
+		fd   = atFdcwd
+		attr = mountAttr{attrSet: set, attrClr: clear}
+	)
+	_, _, errno := syscall.Syscall6(sysMountSetattr, uintptr(fd), uintptr(unsafe.Pointer(p)), atRecursive,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errors.Is(errno, syscall.ENOSYS) {
+		return fmt.Errorf("recursive mount options require mount_setattr: %w", errno)
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// mountIDs returns the IDs of the mounts of the calling process.
+func mountIDs() (map[int]bool, error) {
+	mounts, err := procfs.Root.MountInfo(procfs.PidSelf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mountinfo: %w", err)
+	}
+	ids := make(map[int]bool, len(mounts))
+	for _, m := range mounts {
+		ids[m.ID] = true
+	}
+	return ids, nil
+}
+
+// unwindMounts unmounts the mounts at or below the rootfs that are not in before. They are unmounted in
+// the reverse order of mountinfo, so mounts on top of others go first. Failures are only logged.
+func unwindMounts(rootfs string, before map[int]bool) {
+	log := logger.Log().With(zap.String("rootfs", rootfs))
+	path, err := realPath(rootfs)
+	if err != nil {
+		log.Warn("failed to unwind mounts", zap.Error(err))
+		return
+	}
+	mounts, err := procfs.Root.MountInfo(procfs.PidSelf)
+	if err != nil {
+		log.Warn("failed to unwind mounts", zap.Error(err))
+		return
+	}
+	for i := len(mounts) - 1; i >= 0; i-- {
+		m := mounts[i]
+		if before[m.ID] || !isWithin(m.MountPoint, path) {
+			continue
+		}
+		log.Debug("unwinding mount", zap.String("dest", m.MountPoint))
+		if err = syscall.Unmount(m.MountPoint, syscall.MNT_DETACH); err != nil && !errors.Is(err, syscall.EINVAL) {
+			log.Warn("failed to unwind mount", zap.String("dest", m.MountPoint), zap.Error(err))
+		}
+	}
+}
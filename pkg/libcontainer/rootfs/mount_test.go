@@ -0,0 +1,206 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"roci/pkg/libcontainer/oci"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// tmpfsMagic is the filesystem type of tmpfs, see statfs(2)
+const tmpfsMagic = 0x01021994
+
+func TestCopyDir(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub/dir"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub/file"), []byte("content"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0o777|os.ModeSticky); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dst, "sub/file")); err != nil || string(data) != "content" {
+		t.Errorf("file, expected: %q, actual: %q %v", "content", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "sub/file" {
+		t.Errorf("symlink, expected: %q, actual: %q %v", "sub/file", link, err)
+	}
+	for path, mode := range map[string]os.FileMode{".": 0o777 | os.ModeSticky, "sub/dir": 0o750, "sub/file": 0o640} {
+		info, err := os.Lstat(filepath.Join(dst, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()|info.Mode()&os.ModeSticky != mode {
+			t.Errorf("mode of %v, expected: %v, actual: %v", path, mode, info.Mode())
+		}
+	}
+}
+
+func TestPerformMount_TmpCopyUp(t *testing.T) {
+	destination := t.TempDir()
+	if err := os.WriteFile(filepath.Join(destination, "file"), []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := oci.MountOptions(&specs.Mount{Type: "tmpfs", Options: []string{"tmpcopyup", "size=1m"}})
+	err := performMount("tmpfs", destination, "/", "tmpfs", plan)
+	t.Cleanup(func() {
+		_ = syscall.Unmount(destination, syscall.MNT_DETACH)
+	})
+	if err != nil {
+		t.Skipf("mount not permitted: %v", err)
+	}
+
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(destination, &stat); err != nil || stat.Type != tmpfsMagic {
+		t.Errorf("expected tmpfs at destination, actual: %#x %v", stat.Type, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(destination, "file")); err != nil || string(data) != "content" {
+		t.Errorf("copied file, expected: %q, actual: %q %v", "content", data, err)
+	}
+}
+
+func TestPerformMount_Recursive(t *testing.T) {
+	source, destination := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(source, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mount("tmpfs", filepath.Join(source, "sub"), "tmpfs", 0, ""); err != nil {
+		t.Skipf("mount not permitted: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = syscall.Unmount(filepath.Join(source, "sub"), syscall.MNT_DETACH)
+	})
+
+	plan := oci.MountOptions(&specs.Mount{Type: "bind", Options: []string{"rbind", "rro"}})
+	err := performMount(source, destination, "/", "bind", plan)
+	t.Cleanup(func() {
+		_ = syscall.Unmount(destination, syscall.MNT_DETACH)
+	})
+	if err != nil {
+		t.Skipf("mount_setattr not supported: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(destination, "sub/file"), nil, 0o644); err == nil {
+		t.Error("expected read-only submount")
+	}
+	if err = os.WriteFile(filepath.Join(source, "sub/file"), nil, 0o644); err != nil {
+		t.Errorf("expected writable source: %v", err)
+	}
+}
+
+func TestMountPolicy(t *testing.T) {
+	policy := MountPolicy{Tolerate: []string{"/mnt/cache/"}}
+	if err := policy.Validate(); err != nil {
+		t.Error(err)
+	}
+	if !policy.Tolerates("/mnt/cache") {
+		t.Error("expected /mnt/cache to be tolerated")
+	}
+	if policy.Tolerates("/mnt") {
+		t.Error("expected /mnt not to be tolerated")
+	}
+	if err := (MountPolicy{Tolerate: []string{"mnt"}}).Validate(); err == nil {
+		t.Error("expected error for relative destination")
+	}
+}
+
+func TestFinalizeRootfs_MountFailure(t *testing.T) {
+	rootfs := t.TempDir()
+	spec := &specs.Spec{
+		Root: &specs.Root{Path: rootfs},
+		Mounts: []specs.Mount{
+			{Destination: "/tmp", Type: "tmpfs", Source: "tmpfs"},
+			{Destination: "/data", Type: "bind", Source: filepath.Join(rootfs, "missing"), Options: []string{"rbind"}},
+		},
+		Linux: &specs.Linux{},
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		_ = syscall.Unmount(filepath.Join(rootfs, "tmp"), syscall.MNT_DETACH)
+	})
+
+	err = FinalizeRootfs(rootfs, spec, true, MountPolicy{})
+	if err != nil && strings.HasPrefix(err.Error(), "failed to mount /tmp ") {
+		t.Skipf("mount not permitted: %v", err)
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to mount /data ") {
+		t.Fatalf("expected error of the /data mount, actual: %v", err)
+	}
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(filepath.Join(rootfs, "tmp"), &stat); err != nil || stat.Type == tmpfsMagic {
+		t.Errorf("expected /tmp mount to be unwound: %v", err)
+	}
+}
+
+func TestMountInRootfs_Escape(t *testing.T) {
+	rootfs, outside := escapingRootfs(t)
+	mount := specs.Mount{Destination: "/abs/volume", Type: "tmpfs", Source: "tmpfs"}
+	err := mountInRootfs(rootfs, mount)
+	t.Cleanup(func() {
+		_ = syscall.Unmount(filepath.Join(rootfs, outside, "volume"), syscall.MNT_DETACH)
+		_ = syscall.Unmount(filepath.Join(outside, "volume"), syscall.MNT_DETACH)
+	})
+	if err != nil {
+		t.Skipf("mount not permitted: %v", err)
+	}
+
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(filepath.Join(rootfs, outside, "volume"), &stat); err != nil || stat.Type != tmpfsMagic {
+		t.Errorf("expected tmpfs inside of the rootfs, actual: %#x %v", stat.Type, err)
+	}
+	if _, err = os.Stat(filepath.Join(outside, "volume")); !os.IsNotExist(err) {
+		t.Errorf("expected no mount outside of the rootfs, actual: %v", err)
+	}
+}
+
+func TestMountInRootfs_File(t *testing.T) {
+	rootfs := t.TempDir()
+	source := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(source, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(rootfs, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "etc/hostname"), []byte("image\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing destination is created as file, an existing file is used as mount point
+	for _, destination := range []string{"/etc/hosts", "/etc/hostname"} {
+		mount := specs.Mount{Destination: destination, Type: "bind", Source: source, Options: []string{"rbind", "ro"}}
+		err := mountInRootfs(rootfs, mount)
+		t.Cleanup(func() {
+			_ = syscall.Unmount(filepath.Join(rootfs, destination), syscall.MNT_DETACH)
+		})
+		if err != nil {
+			t.Skipf("mount not permitted: %v", err)
+		}
+
+		if data, err := os.ReadFile(filepath.Join(rootfs, destination)); err != nil || string(data) != "127.0.0.1 localhost\n" {
+			t.Errorf("%v, expected the content of the source, actual: %q %v", destination, data, err)
+		}
+		if err = os.WriteFile(filepath.Join(rootfs, destination), nil, 0o644); err == nil {
+			t.Errorf("%v, expected read-only bind mount", destination)
+		}
+	}
+}
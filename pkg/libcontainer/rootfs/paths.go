@@ -0,0 +1,69 @@
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"roci/pkg/libcontainer/oci"
+	"roci/pkg/logger"
+	"syscall"
+)
+
+// devNull covers masked files, it is taken from the host because the root isn't changed yet
+const devNull = "/dev/null"
+
+// maskPaths hides the paths of the rootfs from the container. Files are covered by a bind mount of /dev/null
+// and directories by an empty read-only tmpfs. Paths that don't exist are skipped.
+func maskPaths(rootfs string, paths []string) error {
+	for _, path := range paths {
+		target, err := openInRoot(rootfs, path, 0)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to mask %v: %w", path, err)
+		}
+		info, err := target.Stat()
+		target.Close()
+		if err != nil {
+			return fmt.Errorf("failed to mask %v: %w", path, err)
+		}
+
+		logger.Log().Debug("masking path", zap.String("path", path), zap.Bool("dir", info.IsDir()))
+		if info.IsDir() {
+			err = performMount("tmpfs", rootfs, path, "tmpfs", &oci.MountPlan{Flags: syscall.MS_RDONLY})
+		} else {
+			err = performMount(devNull, rootfs, path, "bind", &oci.MountPlan{Flags: syscall.MS_BIND})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to mask %v: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// readonlyPaths remounts the paths of the rootfs read-only. A path that isn't a mount point is bind mounted
+// onto itself first, because only the flags of a mount can be changed. Paths that don't exist are skipped.
+func readonlyPaths(rootfs string, paths []string) error {
+	for _, path := range paths {
+		target, err := openInRoot(rootfs, path, 0)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to make %v read-only: %w", path, err)
+		}
+
+		logger.Log().Debug("making path read-only", zap.String("path", path))
+		err = performMount(fdPath(target), rootfs, path, "bind", &oci.MountPlan{
+			Flags:        syscall.MS_BIND | syscall.MS_REC,
+			RemountFlags: syscall.MS_RDONLY,
+		})
+		target.Close()
+		if err != nil {
+			return fmt.Errorf("failed to remount %v read-only: %w", path, err)
+		}
+	}
+	return nil
+}
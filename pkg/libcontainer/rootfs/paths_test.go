@@ -0,0 +1,62 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestMaskPaths(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootfs, "proc/acpi"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "proc/kcore"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "proc/acpi/table"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := maskPaths(rootfs, []string{"/proc/kcore", "/proc/acpi", "/proc/missing"})
+	t.Cleanup(func() {
+		_ = syscall.Unmount(filepath.Join(rootfs, "proc/kcore"), syscall.MNT_DETACH)
+		_ = syscall.Unmount(filepath.Join(rootfs, "proc/acpi"), syscall.MNT_DETACH)
+	})
+	if err != nil {
+		t.Skipf("mount not permitted: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(rootfs, "proc/kcore")); err != nil || len(data) != 0 {
+		t.Errorf("masked file, expected to be empty, actual: %q %v", data, err)
+	}
+	if entries, err := os.ReadDir(filepath.Join(rootfs, "proc/acpi")); err != nil || len(entries) != 0 {
+		t.Errorf("masked dir, expected to be empty, actual: %v %v", entries, err)
+	}
+	if err = os.WriteFile(filepath.Join(rootfs, "proc/acpi/new"), nil, 0o644); err == nil {
+		t.Error("masked dir, expected to be read-only")
+	}
+}
+
+func TestReadonlyPaths(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootfs, "proc/sys"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := readonlyPaths(rootfs, []string{"/proc/sys", "/proc/missing"})
+	t.Cleanup(func() {
+		_ = syscall.Unmount(filepath.Join(rootfs, "proc/sys"), syscall.MNT_DETACH)
+	})
+	if err != nil {
+		t.Skipf("mount not permitted: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(rootfs, "proc/sys/new"), nil, 0o644); err == nil {
+		t.Error("expected read-only path")
+	}
+	if err = os.WriteFile(filepath.Join(rootfs, "proc/new"), nil, 0o644); err != nil {
+		t.Errorf("expected writable parent: %v", err)
+	}
+}
@@ -2,12 +2,15 @@ package rootfs
 
 import (
 	"errors"
+	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"go.uber.org/zap"
 	"os"
 	"path/filepath"
 	"roci/pkg/libcontainer/oci"
 	"roci/pkg/logger"
+	"roci/pkg/procfs"
+	"strings"
 	"syscall"
 )
 
@@ -23,48 +26,235 @@ type DevSymlink struct {
 	Target string
 }
 
-func FinalizeRootfs(rootfs string, spec *specs.Spec) (err error) {
+// FinalizeRootfs mounts the spec mounts into the rootfs and changes the root of the calling process to it.
+// With a mount namespace the root is changed with pivot_root, so the old root isn't reachable anymore.
+// Without a mount namespace or with noPivot, e.g. for a rootfs on a ramdisk, chroot is used instead.
+// A failed spec mount fails the rootfs unless the policy tolerates its destination. The mounts that were
+// done in the rootfs are unwound if the root can't be changed.
+func FinalizeRootfs(rootfs string, spec *specs.Spec, noPivot bool, policy MountPolicy) (err error) {
 	var (
 		log              = logger.Log().With(zap.String("rootfs", rootfs))
 		mounts           = spec.Mounts
 		setupDevRequired = checkSetupDevRequired(mounts)
+		mountNamespace   = hasNamespace(spec, specs.MountNamespace)
+		cgroupNamespace  = hasNamespace(spec, specs.CgroupNamespace)
 	)
 
+	// the mounts are unwound from the outside, they are out of reach after the root was changed
+	before, err := mountIDs()
+	if err != nil {
+		return err
+	}
+	rootChanged := false
+	defer func() {
+		if err != nil && !rootChanged {
+			unwindMounts(rootfs, before)
+		}
+	}()
+
+	if mountNamespace {
+		if err = prepareRoot(rootfs); err != nil {
+			return err
+		}
+	}
+
 	err = syscall.Chdir(rootfs)
 	if err != nil {
 		return err
 	}
 
 	for _, mount := range mounts {
-		err = mountInRootfs(rootfs, mount)
-		if err != nil {
-			log.Warn("mount failed", zap.String("type", mount.Type), zap.String("dest", mount.Destination))
+		if isCgroupMount(mount) {
+			err = mountCgroup(rootfs, mount, cgroupNamespace)
+		} else {
+			err = mountInRootfs(rootfs, mount)
+		}
+		if err != nil && policy.Tolerates(mount.Destination) {
+			log.Warn("tolerating failed mount", zap.String("type", mount.Type), zap.String("source", mount.Source),
+				zap.String("dest", mount.Destination), zap.Error(err))
 			continue
 		}
+		if err != nil {
+			return fmt.Errorf("failed to mount %v (type %q, source %q): %w", mount.Destination, mount.Type, mount.Source, err)
+		}
+	}
+
+	// devices are created before the root is changed, the host devices are bind mounted in user namespaces
+	if setupDevRequired {
+		log.Debug("setup dev is required")
+		err = setupDev(rootfs, spec)
+		if err != nil {
+			log.Warn("setup dev failed")
+			return err
+		}
 	}
 
-	err = syscall.Chroot(rootfs)
+	// the paths are hidden after all mounts are done, so they cover the spec mounts
+	if spec.Linux != nil {
+		if err = maskPaths(rootfs, spec.Linux.MaskedPaths); err != nil {
+			return err
+		}
+		if err = readonlyPaths(rootfs, spec.Linux.ReadonlyPaths); err != nil {
+			return err
+		}
+	}
+
+	if mountNamespace && !noPivot {
+		log.Debug("pivot root")
+		err = pivotRoot(rootfs)
+	} else {
+		log.Debug("chroot")
+		err = chroot(rootfs)
+	}
 	if err != nil {
 		return err
 	}
+	rootChanged = true
+	if !mountNamespace {
+		return nil
+	}
+	return finalizeRoot(spec)
+}
+
+// prepareRoot bind mounts the rootfs onto itself, because pivot_root requires the new root to be a mount point.
+// The propagation of the mount namespace was already set by its Finalize.
+func prepareRoot(rootfs string) error {
+	if err := makeParentPrivate(rootfs); err != nil {
+		return err
+	}
+	if err := syscall.Mount(rootfs, rootfs, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind mount rootfs: %w", err)
+	}
+	return nil
+}
 
-	err = syscall.Chdir("/")
+// makeParentPrivate makes the mount that contains the rootfs private if it is shared, because
+// pivot_root fails if the parent of the new root is shared, e.g. with rootfs propagation shared.
+func makeParentPrivate(rootfs string) error {
+	path, err := realPath(rootfs)
 	if err != nil {
 		return err
 	}
+	mounts, err := procfs.Root.MountInfo(procfs.PidSelf)
+	if err != nil {
+		return fmt.Errorf("failed to read mountinfo: %w", err)
+	}
 
-	if setupDevRequired {
-		log.Debug("setup dev is required")
-		err = setupDev(rootfs, spec)
+	// the parent is the last mount with the longest mount point that contains the rootfs
+	var parent *procfs.MountInfo
+	for i, m := range mounts {
+		if !isWithin(path, m.MountPoint) {
+			continue
+		}
+		if parent == nil || len(m.MountPoint) >= len(parent.MountPoint) {
+			parent = &mounts[i]
+		}
+	}
+	if parent == nil || !parent.Shared() {
+		return nil
+	}
+	if err = syscall.Mount("", parent.MountPoint, "", syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make parent mount %v of the rootfs private: %w", parent.MountPoint, err)
+	}
+	return nil
+}
+
+// realPath returns the absolute path without symlinks, as mountinfo shows it.
+func realPath(path string) (string, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// isWithin checks whether path is dir or below dir.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// finalizeRoot applies the rootfs propagation again after the root was changed, because the bind mount
+// of the rootfs became private with its parent, and remounts the root read-only if the spec requires it.
+func finalizeRoot(spec *specs.Spec) error {
+	propagation := spec.Linux.RootfsPropagation
+	if propagation != "" && propagation != "private" && propagation != "rprivate" {
+		flags, err := oci.RootfsPropagation(propagation)
 		if err != nil {
-			log.Warn("setup dev failed")
 			return err
 		}
+		if err = syscall.Mount("", "/", "", uintptr(flags), ""); err != nil {
+			return fmt.Errorf("failed to set rootfs propagation: %w", err)
+		}
 	}
 
+	if spec.Root != nil && spec.Root.Readonly {
+		flags := syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | lockedFlags("/")
+		if err := syscall.Mount("", "/", "", uintptr(flags), ""); err != nil {
+			return fmt.Errorf("failed to remount root read-only: %w", err)
+		}
+	}
 	return nil
 }
 
+// pivotRoot changes the root to rootfs and detaches the old root, see pivot_root(2).
+// The new and old root are both "." so no directory for the old root is required inside of the rootfs.
+func pivotRoot(rootfs string) error {
+	oldroot, err := syscall.Open("/", syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(oldroot)
+
+	newroot, err := syscall.Open(rootfs, syscall.O_DIRECTORY|syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(newroot)
+
+	if err = syscall.Fchdir(newroot); err != nil {
+		return err
+	}
+	if err = syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+
+	// the old root is stacked on top of the new root now and is unmounted from inside of it
+	if err = syscall.Fchdir(oldroot); err != nil {
+		return err
+	}
+	// the unmount must not propagate to the host
+	if err = syscall.Mount("", ".", "", syscall.MS_SLAVE|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to make old root rslave: %w", err)
+	}
+	if err = syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount old root: %w", err)
+	}
+	return syscall.Chdir("/")
+}
+
+// chroot changes the root to rootfs. Unlike pivot_root the old root stays reachable for
+// privileged processes.
+func chroot(rootfs string) error {
+	if err := syscall.Chroot(rootfs); err != nil {
+		return err
+	}
+	return syscall.Chdir("/")
+}
+
+// hasNamespace checks whether the spec contains a namespace of namespaceType.
+func hasNamespace(spec *specs.Spec, namespaceType specs.LinuxNamespaceType) bool {
+	if spec.Linux == nil {
+		return false
+	}
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == namespaceType {
+			return true
+		}
+	}
+	return false
+}
+
 func CleanRootfs(rootfs string, spec *specs.Spec) (err error) {
 	var (
 		mounts = spec.Mounts
@@ -77,21 +267,42 @@ func CleanRootfs(rootfs string, spec *specs.Spec) (err error) {
 		}
 	}
 
+	_ = unmountInRootfs(rootfs, "dev/pts", false)
+	_ = unmountInRootfs(rootfs, "dev/shm", false)
 	_ = unmountInRootfs(rootfs, "dev", false)
 
 	return nil
 }
 
 func mountInRootfs(rootfs string, mount specs.Mount) (err error) {
-	destination := filepath.Join(rootfs, mount.Destination)
-	if err := os.MkdirAll(destination, 0o755); err != nil {
+	plan := oci.MountOptions(&mount)
+	if err = createMountPoint(rootfs, mount, plan.Bind()); err != nil {
 		return err
 	}
 
-	flags, data := oci.MountOptions(&mount)
 	wd, _ := os.Getwd()
-	logger.Log().Debug("mounting", zap.String("cwd", wd), zap.String("source", mount.Source), zap.String("dest", destination), zap.String("type", mount.Type))
-	return syscall.Mount(mount.Source, destination, mount.Type, uintptr(flags), data)
+	logger.Log().Debug("mounting", zap.String("cwd", wd), zap.String("source", mount.Source), zap.String("dest", mount.Destination), zap.String("type", mount.Type))
+	return performMount(mount.Source, rootfs, mount.Destination, mount.Type, plan)
+}
+
+// createMountPoint creates the destination of the mount inside of the rootfs. A bind mount of a file, like
+// /etc/hosts, needs an empty file as mount point, all other mounts a directory. An existing file is kept.
+func createMountPoint(rootfs string, mount specs.Mount, bind bool) error {
+	if bind {
+		info, err := os.Stat(mount.Source)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return createFileInRoot(rootfs, mount.Destination)
+		}
+	}
+
+	dir, err := mkdirAllInRoot(rootfs, mount.Destination)
+	if err != nil {
+		return err
+	}
+	return dir.Close()
 }
 
 func unmountInRootfs(rootfs, mountDestination string, force bool) (err error) {
@@ -128,34 +339,63 @@ func checkSetupDevRequired(mounts []specs.Mount) bool {
 	return true
 }
 
+// setupDev populates /dev of the rootfs with devpts, shm, the device nodes and the standard symlinks.
 func setupDev(rootfs string, spec *specs.Spec) (err error) {
-	devdir := filepath.Join(rootfs, "dev")
-	err = os.MkdirAll(devdir, 0755)
+	devdir, err := mkdirAllInRoot(rootfs, "/dev")
 	if err != nil {
 		return err
 	}
+	devdir.Close()
 
-	err = createDevs(spec)
+	if !hasMount(spec.Mounts, "/dev/pts") {
+		err = mountInRootfs(rootfs, DevPtsMount)
+		if err != nil {
+			return fmt.Errorf("failed to mount /dev/pts: %w", err)
+		}
+	}
+	if !hasMount(spec.Mounts, "/dev/shm") {
+		err = mountInRootfs(rootfs, DevShmMount)
+		if err != nil {
+			return fmt.Errorf("failed to mount /dev/shm: %w", err)
+		}
+	}
+
+	devices := specDevices(spec)
+	err = createDevs(rootfs, devices, hasNamespace(spec, specs.UserNamespace))
 	if err != nil {
 		return err
 	}
 
+	if !hasDevice(devices, "/dev/ptmx") {
+		err = setupPtmx(rootfs)
+		if err != nil {
+			return err
+		}
+	}
+
 	return createDevSymlinks(rootfs)
 }
 
-func createDevs(spec *specs.Spec) error {
-	//TODO create devices
-	logger.Log().Warn("create devs is not implemented")
-	return nil
+// hasMount checks whether one of the mounts has the destination.
+func hasMount(mounts []specs.Mount, destination string) bool {
+	for _, m := range mounts {
+		if filepath.Clean(m.Destination) == destination {
+			return true
+		}
+	}
+	return false
 }
 
+// createDevSymlinks creates the standard symlinks of /dev in their directory, which is resolved inside of the rootfs.
 func createDevSymlinks(rootfs string) error {
 	for _, link := range SpecDevSymlinks {
-		var (
-			source = link.Source
-			target = filepath.Join(rootfs, link.Target)
-		)
-		if err := os.Symlink(source, target); err != nil && !os.IsExist(err) {
+		dir, err := openInRoot(rootfs, filepath.Dir(link.Target), syscall.O_DIRECTORY)
+		if err != nil {
+			return err
+		}
+		err = os.Symlink(link.Source, pathIn(dir, filepath.Base(link.Target)))
+		dir.Close()
+		if err != nil && !os.IsExist(err) {
 			return err
 		}
 	}
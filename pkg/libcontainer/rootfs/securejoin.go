@@ -0,0 +1,177 @@
+package rootfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"roci/pkg/procfs"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// sysOpenat2 is the number of openat2(2), it is the same on all architectures
+	sysOpenat2 = 437
+
+	// resolve flags of openat2(2), RESOLVE_*
+	resolveNoMagiclinks = 0x02
+	resolveInRoot       = 0x10
+
+	// oPath opens a file only as location in the filesystem, O_PATH
+	oPath = 0x200000
+
+	// maxSymlinks is the maximum number of symlinks of a lookup without openat2, like MAXSYMLINKS of the kernel
+	maxSymlinks = 40
+)
+
+// openHow is struct open_how of openat2(2).
+type openHow struct {
+	flags   uint64
+	mode    uint64
+	resolve uint64
+}
+
+// openInRoot opens the path inside of the rootfs with O_PATH. Every component is resolved as if the rootfs
+// was the root, so neither symlinks nor ".." of the rootfs lead out of it.
+// Files of the rootfs are changed through the returned fd, a path could be swapped for a symlink meanwhile.
+func openInRoot(rootfs, path string, flags int) (*os.File, error) {
+	name := filepath.Join(rootfs, path)
+	root, err := os.OpenFile(rootfs, oPath|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	how := openHow{
+		flags:   uint64(oPath | syscall.O_CLOEXEC | flags),
+		resolve: resolveInRoot | resolveNoMagiclinks,
+	}
+	fd, err := openat2(int(root.Fd()), path, &how)
+	if errors.Is(err, syscall.ENOSYS) {
+		fd, err = openInRootFallback(rootfs, path, flags)
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "open in root", Path: name, Err: err}
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+func openat2(dirfd int, path string, how *openHow) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	for {
+		fd, _, errno := syscall.Syscall6(sysOpenat2, uintptr(dirfd), uintptr(unsafe.Pointer(p)),
+			uintptr(unsafe.Pointer(how)), unsafe.Sizeof(*how), 0, 0)
+		// EAGAIN is returned if a rename raced with the lookup of ".."
+		if errno == syscall.EAGAIN || errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return -1, errno
+		}
+		return int(fd), nil
+	}
+}
+
+// openInRootFallback resolves the path like openat2 with RESOLVE_IN_ROOT for kernels without it.
+// The symlinks are read and resolved in user space, which a concurrent rename of the rootfs could race.
+func openInRootFallback(rootfs, path string, flags int) (int, error) {
+	var (
+		resolved   = "/"
+		components = strings.Split(path, "/")
+		links      int
+	)
+	for len(components) > 0 {
+		name := components[0]
+		components = components[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		info, err := os.Lstat(filepath.Join(rootfs, next))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			// the open fails if a component doesn't exist
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return -1, syscall.ELOOP
+		}
+		target, err := os.Readlink(filepath.Join(rootfs, next))
+		if err != nil {
+			return -1, err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		components = append(strings.Split(target, "/"), components...)
+	}
+	return syscall.Open(filepath.Join(rootfs, resolved), oPath|syscall.O_CLOEXEC|syscall.O_NOFOLLOW|flags, 0)
+}
+
+// mkdirAllInRoot creates the directory path and its parents inside of the rootfs like os.MkdirAll and
+// opens it with O_PATH. Each directory is created in its parent, which was resolved inside of the rootfs.
+func mkdirAllInRoot(rootfs, path string) (*os.File, error) {
+	dir, err := openInRoot(rootfs, "/", syscall.O_DIRECTORY)
+	if err != nil {
+		return nil, err
+	}
+
+	current := "/"
+	for _, name := range strings.Split(path, "/") {
+		if name == "" || name == "." {
+			continue
+		}
+		current = filepath.Join(current, name)
+		err = syscall.Mkdirat(int(dir.Fd()), name, 0o755)
+		dir.Close()
+		if err != nil && !errors.Is(err, syscall.EEXIST) {
+			return nil, &os.PathError{Op: "mkdir in root", Path: filepath.Join(rootfs, current), Err: err}
+		}
+		// an existing symlink is resolved inside of the rootfs, like the rest of the path
+		if dir, err = openInRoot(rootfs, current, syscall.O_DIRECTORY); err != nil {
+			return nil, err
+		}
+	}
+	return dir, nil
+}
+
+// createFileInRoot creates an empty file at path inside of the rootfs, the parent directories are created
+// like by mkdirAllInRoot. An existing file or a symlink is kept, the symlink is resolved inside of the rootfs
+// when the file is opened.
+func createFileInRoot(rootfs, path string) error {
+	dir, err := mkdirAllInRoot(rootfs, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	fd, err := syscall.Openat(int(dir.Fd()), filepath.Base(path), syscall.O_CREAT|syscall.O_EXCL|syscall.O_RDONLY|syscall.O_CLOEXEC, 0o644)
+	if errors.Is(err, syscall.EEXIST) {
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{Op: "create in root", Path: filepath.Join(rootfs, path), Err: err}
+	}
+	return syscall.Close(fd)
+}
+
+// fdPath returns the path that reaches the file of f in procfs. The path isn't resolved again,
+// so it refers to the file even if the path it was opened with changed.
+func fdPath(f *os.File) string {
+	return procfs.Root.FdPath(procfs.PidSelf, f.Fd())
+}
+
+// pathIn returns the path of the entry name in the directory dir.
+func pathIn(dir *os.File, name string) string {
+	return filepath.Join(fdPath(dir), name)
+}
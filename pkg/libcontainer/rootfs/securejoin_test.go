@@ -0,0 +1,115 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// escapingRootfs returns a rootfs with symlinks that point out of it if they are resolved on the host.
+// The directory outside of the rootfs exists inside of it as well.
+func escapingRootfs(t *testing.T) (rootfs, outside string) {
+	rootfs, outside = t.TempDir(), t.TempDir()
+	for _, dir := range []string{"etc", "real/dir", outside} {
+		if err := os.MkdirAll(filepath.Join(rootfs, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"abs":      outside,
+		"rel":      "../../../../../.." + outside,
+		"data":     "/etc",
+		"relative": "real/dir",
+	} {
+		if err := os.Symlink(target, filepath.Join(rootfs, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rootfs, outside
+}
+
+func TestOpenInRoot(t *testing.T) {
+	rootfs, outside := escapingRootfs(t)
+	paths := map[string]string{
+		"/data":     "/etc",
+		"data/":     "/etc",
+		"/relative": "/real/dir",
+		"/abs":      outside,
+		"/rel":      outside,
+		"/../..":    "/",
+	}
+
+	for _, tt := range []struct {
+		name string
+		open func(rootfs, path string) (*os.File, error)
+	}{
+		{"openat2", func(rootfs, path string) (*os.File, error) {
+			return openInRoot(rootfs, path, 0)
+		}},
+		{"fallback", func(rootfs, path string) (*os.File, error) {
+			fd, err := openInRootFallback(rootfs, path, 0)
+			if err != nil {
+				return nil, err
+			}
+			return os.NewFile(uintptr(fd), path), nil
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for path, expected := range paths {
+				f, err := tt.open(rootfs, path)
+				if err != nil {
+					t.Errorf("%v: %v", path, err)
+					continue
+				}
+				link, err := os.Readlink(fdPath(f))
+				f.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expected = filepath.Join(rootfs, expected); link != expected {
+					t.Errorf("%v, expected: %v, actual: %v", path, expected, link)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenInRoot_NotExist(t *testing.T) {
+	rootfs, _ := escapingRootfs(t)
+	if _, err := openInRoot(rootfs, "/data/missing", 0); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, actual: %v", err)
+	}
+}
+
+func TestMkdirAllInRoot(t *testing.T) {
+	rootfs, outside := escapingRootfs(t)
+	dir, err := mkdirAllInRoot(rootfs, "/abs/new/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir.Close()
+
+	if _, err = os.Stat(filepath.Join(rootfs, outside, "new/dir")); err != nil {
+		t.Errorf("expected directory inside of the rootfs: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected no directory outside of the rootfs, actual: %v", err)
+	}
+}
+
+func TestCreateDevSymlinks_Escape(t *testing.T) {
+	rootfs, outside := escapingRootfs(t)
+	if err := os.Symlink(outside, filepath.Join(rootfs, "dev")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := createDevSymlinks(rootfs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(rootfs, outside, "stdin")); err != nil {
+		t.Errorf("expected symlink inside of the rootfs: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "stdin")); !os.IsNotExist(err) {
+		t.Errorf("expected no symlink outside of the rootfs, actual: %v", err)
+	}
+}
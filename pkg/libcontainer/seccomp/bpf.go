@@ -0,0 +1,130 @@
+package seccomp
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// offsets of the fields of struct seccomp_data, the input of the filter
+const (
+	offsetNr   = 0
+	offsetArch = 4
+	offsetArgs = 16
+)
+
+// instruction is a bpf instruction whose jumps refer to labels. An empty label is the next instruction.
+type instruction struct {
+	syscall.SockFilter
+	jt, jf, target string // targets of a conditional jump or of ja
+}
+
+// program assembles bpf instructions with symbolic jump targets.
+type program struct {
+	instructions []instruction
+
+	// positions are the indexes of the instructions the labels point to
+	positions map[string]int
+	labels    int
+}
+
+func newProgram() *program {
+	return &program{positions: map[string]int{}}
+}
+
+// newLabel returns a unique label with the prefix.
+func (p *program) newLabel(prefix string) string {
+	p.labels++
+	return fmt.Sprintf("%v_%d", prefix, p.labels)
+}
+
+// mark points the label to the next instruction.
+func (p *program) mark(label string) {
+	p.positions[label] = len(p.instructions)
+}
+
+// load loads the 32-bit word at offset of the seccomp data into the accumulator.
+func (p *program) load(offset uint32) {
+	p.emit(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, offset, "", "", "")
+}
+
+// and masks the accumulator.
+func (p *program) and(mask uint32) {
+	p.emit(syscall.BPF_ALU|syscall.BPF_AND|syscall.BPF_K, mask, "", "", "")
+}
+
+// jump compares the accumulator with k using the jump operation and continues at jt or jf.
+func (p *program) jump(op uint16, k uint32, jt, jf string) {
+	p.emit(syscall.BPF_JMP|op|syscall.BPF_K, k, jt, jf, "")
+}
+
+// jumpTo jumps to the label if the comparison of the accumulator with k is true, the label may be far.
+func (p *program) jumpTo(op uint16, k uint32, label string) {
+	skip := p.newLabel("skip")
+	p.jump(op, k, "", skip)
+	p.goTo(label)
+	p.mark(skip)
+}
+
+// goTo jumps to the label unconditionally, its offset is not limited to 255 instructions.
+func (p *program) goTo(label string) {
+	p.emit(syscall.BPF_JMP|syscall.BPF_JA, 0, "", "", label)
+}
+
+// ret returns the action.
+func (p *program) ret(action uint32) {
+	p.emit(syscall.BPF_RET|syscall.BPF_K, action, "", "", "")
+}
+
+func (p *program) emit(code uint16, k uint32, jt, jf, target string) {
+	p.instructions = append(p.instructions, instruction{
+		SockFilter: syscall.SockFilter{Code: code, K: k},
+		jt:         jt,
+		jf:         jf,
+		target:     target,
+	})
+}
+
+// assemble resolves the labels into jump offsets.
+// Conditional jumps only reach 255 instructions, so far targets have to be reached with goTo.
+func (p *program) assemble() ([]syscall.SockFilter, error) {
+	offset := func(from int, label string) (int, error) {
+		if label == "" {
+			return 0, nil
+		}
+		to, ok := p.positions[label]
+		switch {
+		case !ok:
+			return 0, fmt.Errorf("unknown label %v", label)
+		case to <= from || to >= len(p.instructions):
+			return 0, fmt.Errorf("invalid jump to label %v", label)
+		}
+		return to - from - 1, nil
+	}
+
+	filters := make([]syscall.SockFilter, len(p.instructions))
+	for i, ins := range p.instructions {
+		filter := ins.SockFilter
+		if ins.target != "" {
+			k, err := offset(i, ins.target)
+			if err != nil {
+				return nil, err
+			}
+			filter.K = uint32(k)
+		}
+		for _, jump := range []struct {
+			label string
+			field *uint8
+		}{{ins.jt, &filter.Jt}, {ins.jf, &filter.Jf}} {
+			o, err := offset(i, jump.label)
+			if err != nil {
+				return nil, err
+			}
+			if o > 255 {
+				return nil, fmt.Errorf("jump to label %v is too far", jump.label)
+			}
+			*jump.field = uint8(o)
+		}
+		filters[i] = filter
+	}
+	return filters, nil
+}
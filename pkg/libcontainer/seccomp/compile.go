@@ -0,0 +1,214 @@
+package seccomp
+
+import (
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"roci/pkg/logger"
+	"syscall"
+)
+
+// compiler generates the program of the rules.
+type compiler struct {
+	program       *program
+	defaultAction uint32
+}
+
+// numberedRules are the rules of a syscall of an architecture.
+type numberedRules struct {
+	nr    uint32
+	rules []rule
+}
+
+// compile dispatches the syscalls by their audit architecture to the rules of the architecture.
+// x86_64 and x32 share their audit architecture, they are told apart by the x32 syscall bit.
+func (c *compiler) compile(selected []specs.Arch, names []string, rules map[string][]rule) {
+	p := c.program
+	var audits []uint32
+	byAudit := make(map[uint32][]arch)
+	for _, name := range selected {
+		a := arches[name]
+		if _, ok := byAudit[a.audit]; !ok {
+			audits = append(audits, a.audit)
+		}
+		byAudit[a.audit] = append(byAudit[a.audit], a)
+	}
+	logUnknown(selected, names)
+
+	p.load(offsetArch)
+	labels := make([]string, len(audits))
+	for i, audit := range audits {
+		labels[i] = p.newLabel("arch")
+		p.jumpTo(syscall.BPF_JEQ, audit, labels[i])
+	}
+	p.ret(retBadArch)
+
+	for i, audit := range audits {
+		p.mark(labels[i])
+		p.load(offsetNr)
+		var withBit, withoutBit *arch
+		for _, a := range byAudit[audit] {
+			if a.bit != 0 {
+				withBit = &a
+			} else {
+				withoutBit = &a
+			}
+		}
+		if withBit == nil && audit != auditArchX86_64 {
+			c.section(*withoutBit, names, rules)
+			continue
+		}
+
+		// the numbers of the architecture with syscall bit are checked first, the others are bad
+		bitLabel := p.newLabel("bit")
+		p.jumpTo(syscall.BPF_JGE, x32SyscallBit, bitLabel)
+		if withoutBit != nil {
+			c.section(*withoutBit, names, rules)
+		} else {
+			p.ret(retBadArch)
+		}
+		p.mark(bitLabel)
+		if withBit != nil {
+			c.section(*withBit, names, rules)
+		} else {
+			p.ret(retBadArch)
+		}
+	}
+}
+
+// section generates the rules of the architecture, the accumulator holds the syscall number.
+// Syscalls whose first rule has no conditions jump to a shared return of the action, the others to
+// a block that checks their rules in order.
+func (c *compiler) section(a arch, names []string, rules map[string][]rule) {
+	p := c.program
+	var (
+		actions       []uint32
+		unconditional = make(map[uint32][]uint32)
+		conditional   []numberedRules
+	)
+	for _, name := range names {
+		nr, ok := a.table[name]
+		if !ok {
+			continue
+		}
+		nr |= a.bit
+		first := rules[name][0]
+		if len(first.args) > 0 {
+			conditional = append(conditional, numberedRules{nr: nr, rules: rules[name]})
+			continue
+		}
+		if _, ok = unconditional[first.action]; !ok {
+			actions = append(actions, first.action)
+		}
+		unconditional[first.action] = append(unconditional[first.action], nr)
+	}
+
+	for _, action := range actions {
+		nrs := unconditional[action]
+		for len(nrs) > 0 {
+			chunk := nrs[:min(len(nrs), maxChunk)]
+			nrs = nrs[len(chunk):]
+			ret, next := p.newLabel("ret"), p.newLabel("next")
+			for _, nr := range chunk {
+				p.jump(syscall.BPF_JEQ, nr, ret, "")
+			}
+			p.goTo(next)
+			p.mark(ret)
+			p.ret(action)
+			p.mark(next)
+		}
+	}
+
+	blocks := make([]string, len(conditional))
+	for i, s := range conditional {
+		blocks[i] = p.newLabel("syscall")
+		p.jumpTo(syscall.BPF_JEQ, s.nr, blocks[i])
+	}
+	p.ret(c.defaultAction)
+
+	for i, s := range conditional {
+		p.mark(blocks[i])
+		for _, r := range s.rules {
+			next := p.newLabel("rule")
+			for _, arg := range r.args {
+				c.compare(arg, a.wide, next)
+			}
+			p.ret(r.action)
+			p.mark(next)
+		}
+		p.ret(c.defaultAction)
+	}
+}
+
+// compare generates the condition of an argument. The program continues after it if the condition
+// matches, otherwise it jumps to fail. The 64-bit comparisons are split into the upper and lower half,
+// only the lower half is compared on architectures with 32-bit arguments.
+func (c *compiler) compare(arg specs.LinuxSeccompArg, wide bool, fail string) {
+	p := c.program
+	var (
+		lo         = offsetArgs + 8*uint32(arg.Index)
+		hi         = lo + 4
+		value      = arg.Value
+		ok, failed = p.newLabel("ok"), p.newLabel("failed")
+	)
+	if arg.Op == specs.OpMaskedEqual {
+		value = arg.ValueTwo
+	}
+
+	// the upper half decides if it differs, otherwise the lower half is compared
+	if wide {
+		p.load(hi)
+		switch arg.Op {
+		case specs.OpEqualTo:
+			p.jump(syscall.BPF_JEQ, uint32(value>>32), "", failed)
+		case specs.OpNotEqual:
+			p.jump(syscall.BPF_JEQ, uint32(value>>32), "", ok)
+		case specs.OpMaskedEqual:
+			p.and(uint32(arg.Value >> 32))
+			p.jump(syscall.BPF_JEQ, uint32(value>>32), "", failed)
+		case specs.OpGreaterThan, specs.OpGreaterEqual:
+			p.jump(syscall.BPF_JGT, uint32(value>>32), ok, "")
+			p.jump(syscall.BPF_JEQ, uint32(value>>32), "", failed)
+		case specs.OpLessThan, specs.OpLessEqual:
+			p.jump(syscall.BPF_JGT, uint32(value>>32), failed, "")
+			p.jump(syscall.BPF_JEQ, uint32(value>>32), "", ok)
+		}
+	}
+
+	p.load(lo)
+	switch arg.Op {
+	case specs.OpEqualTo:
+		p.jump(syscall.BPF_JEQ, uint32(value), ok, failed)
+	case specs.OpNotEqual:
+		p.jump(syscall.BPF_JEQ, uint32(value), failed, ok)
+	case specs.OpMaskedEqual:
+		p.and(uint32(arg.Value))
+		p.jump(syscall.BPF_JEQ, uint32(value), ok, failed)
+	case specs.OpGreaterThan:
+		p.jump(syscall.BPF_JGT, uint32(value), ok, failed)
+	case specs.OpGreaterEqual:
+		p.jump(syscall.BPF_JGE, uint32(value), ok, failed)
+	case specs.OpLessThan:
+		p.jump(syscall.BPF_JGE, uint32(value), failed, ok)
+	case specs.OpLessEqual:
+		p.jump(syscall.BPF_JGT, uint32(value), failed, ok)
+	}
+
+	p.mark(failed)
+	p.goTo(fail)
+	p.mark(ok)
+}
+
+// logUnknown logs the syscalls that none of the architectures knows. Profiles contain the syscalls
+// of many architectures, so they are ignored like runc does.
+func logUnknown(selected []specs.Arch, names []string) {
+	for _, name := range names {
+		known := false
+		for _, a := range selected {
+			_, ok := arches[a].table[name]
+			known = known || ok
+		}
+		if !known {
+			logger.Log().Named("seccomp").Debug("ignoring unknown syscall", zap.String("syscall", name))
+		}
+	}
+}
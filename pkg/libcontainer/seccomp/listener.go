@@ -0,0 +1,39 @@
+package seccomp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"net"
+	"os"
+	"syscall"
+)
+
+// EnvListenerFd is the environment variable that contains the fd of the listener socket in the init process
+const EnvListenerFd = "_ROCI_SECCOMP_LISTENER_FD"
+
+// Connect connects to the socket at the listener path of the spec.
+// It returns the socket as file, so it can be passed to the init process.
+func Connect(path string) (*os.File, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to seccomp listener socket: %w", err)
+	}
+	defer conn.Close()
+	return conn.File()
+}
+
+// SendListener sends the listener of a loaded filter with SCM_RIGHTS over the socket.
+// The state of the container process is sent as message, see the runtime spec.
+func SendListener(socket, listener *os.File, state specs.ContainerProcessState) error {
+	state.Fds = []string{specs.SeccompFdName}
+	message, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(listener.Fd()))
+	if err = syscall.Sendmsg(int(socket.Fd()), message, rights, nil, 0); err != nil {
+		return fmt.Errorf("failed to send seccomp listener: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+// Package seccomp compiles the seccomp configuration of the spec into a bpf program and loads it
+// with seccomp(2), without libseccomp.
+//
+// The program checks the architecture of a syscall first and continues with the rules of the architecture.
+// The rules of a syscall are checked in the order of the spec, the first matching rule returns its action.
+// Syscalls without matching rule return the default action, syscalls of other architectures kill the thread
+// like the bad arch action of libseccomp.
+package seccomp
+
+import (
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"go.uber.org/zap"
+	"os"
+	"roci/pkg/logger"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	seccompSetModeFilter = 1 // SECCOMP_SET_MODE_FILTER
+
+	// flags of seccomp(2)
+	flagLog              = 1 << 1 // SECCOMP_FILTER_FLAG_LOG
+	flagSpecAllow        = 1 << 2 // SECCOMP_FILTER_FLAG_SPEC_ALLOW
+	flagNewListener      = 1 << 3 // SECCOMP_FILTER_FLAG_NEW_LISTENER
+	flagWaitKillableRecv = 1 << 5 // SECCOMP_FILTER_FLAG_WAIT_KILLABLE_RECV
+
+	// return values of the filter, SECCOMP_RET_*
+	retKillProcess = 0x80000000
+	retKillThread  = 0x00000000
+	retTrap        = 0x00030000
+	retErrno       = 0x00050000
+	retUserNotif   = 0x7fc00000
+	retTrace       = 0x7ff00000
+	retLog         = 0x7ffc0000
+	retAllow       = 0x7fff0000
+
+	// retBadArch is returned for syscalls of architectures that are not in the spec
+	retBadArch = retKillThread
+
+	// the audit architectures of seccomp_data, AUDIT_ARCH_*
+	auditArchX86_64  = 0xc000003e
+	auditArchI386    = 0x40000003
+	auditArchAARCH64 = 0xc00000b7
+	auditArchARM     = 0x40000028
+
+	// x32SyscallBit is set in the numbers of x32 syscalls, which share the audit architecture of x86_64
+	x32SyscallBit = 0x40000000
+
+	// maxInstructions is the maximum length of a program, BPF_MAXINSNS
+	maxInstructions = 4096
+
+	// maxChunk is the maximum number of syscalls that jump to the same return of an action.
+	// Conditional jumps only reach 255 instructions.
+	maxChunk = 250
+)
+
+// arch is an architecture with its syscall table.
+type arch struct {
+	audit uint32
+	bit   uint32 // set in the syscall numbers of the architecture
+	wide  bool   // syscall arguments are 64-bit
+	table map[string]uint32
+}
+
+// arches are the supported architectures. The numbers are the same on both byte orders,
+// the arguments are compared as little endian.
+var arches = map[specs.Arch]arch{
+	specs.ArchX86_64:  {audit: auditArchX86_64, wide: true, table: syscallsX86_64},
+	specs.ArchX86:     {audit: auditArchI386, table: syscallsX86},
+	specs.ArchX32:     {audit: auditArchX86_64, bit: x32SyscallBit, wide: true, table: syscallsX32},
+	specs.ArchAARCH64: {audit: auditArchAARCH64, wide: true, table: syscallsAARCH64},
+	specs.ArchARM:     {audit: auditArchARM, table: syscallsARM},
+}
+
+// nativeArches maps GOARCH to the architecture of the runtime, it is always part of the filter.
+var nativeArches = map[string]specs.Arch{
+	"amd64": specs.ArchX86_64,
+	"386":   specs.ArchX86,
+	"arm64": specs.ArchAARCH64,
+	"arm":   specs.ArchARM,
+}
+
+// Filter is a compiled seccomp filter.
+type Filter struct {
+	program []syscall.SockFilter
+	flags   uintptr
+
+	// notify is set if a rule uses SCMP_ACT_NOTIFY, loading the filter returns a listener then
+	notify bool
+
+	// seccomp is the number of seccomp(2) on the native architecture
+	seccomp uintptr
+}
+
+// rule is a rule of a syscall, it returns the action if all conditions match.
+type rule struct {
+	action uint32
+	args   []specs.LinuxSeccompArg
+}
+
+// Compile compiles the seccomp configuration into a filter.
+// It returns nil if config is nil, the process isn't filtered then.
+func Compile(config *specs.LinuxSeccomp) (*Filter, error) {
+	if config == nil {
+		return nil, nil
+	}
+	native, ok := nativeArches[runtime.GOARCH]
+	if !ok {
+		return nil, fmt.Errorf("seccomp is not supported on %v", runtime.GOARCH)
+	}
+
+	defaultAction, err := action(config.DefaultAction, config.DefaultErrnoRet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seccomp default action: %w", err)
+	}
+	flags, err := filterFlags(config.Flags)
+	if err != nil {
+		return nil, err
+	}
+	names, rules, err := syscallRules(config.Syscalls)
+	if err != nil {
+		return nil, err
+	}
+
+	notify := defaultAction == retUserNotif
+	for _, name := range names {
+		for _, r := range rules[name] {
+			notify = notify || r.action == retUserNotif
+		}
+	}
+	if notify {
+		if config.ListenerPath == "" {
+			return nil, fmt.Errorf("seccomp action %v requires a listener path", specs.ActNotify)
+		}
+		flags |= flagNewListener
+	}
+
+	c := &compiler{program: newProgram(), defaultAction: defaultAction}
+	c.compile(selectArches(native, config.Architectures), names, rules)
+	program, err := c.program.assemble()
+	if err != nil {
+		return nil, err
+	}
+	if len(program) > maxInstructions {
+		return nil, fmt.Errorf("seccomp filter has %d instructions, the maximum is %d", len(program), maxInstructions)
+	}
+
+	return &Filter{
+		program: program,
+		flags:   flags,
+		notify:  notify,
+		seccomp: uintptr(arches[native].table["seccomp"]),
+	}, nil
+}
+
+// Load loads the filter for the calling thread, it's inherited by the process executed by the thread.
+// The thread needs CAP_SYS_ADMIN or no_new_privs. If the filter notifies, the listener is returned,
+// otherwise it is nil.
+func (f *Filter) Load() (listener *os.File, err error) {
+	if f == nil {
+		return nil, nil
+	}
+	prog := syscall.SockFprog{Len: uint16(len(f.program)), Filter: &f.program[0]}
+	fd, _, errno := syscall.RawSyscall(f.seccomp, seccompSetModeFilter, f.flags, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return nil, fmt.Errorf("failed to load seccomp filter: %w", errno)
+	}
+	if !f.notify {
+		return nil, nil
+	}
+	return os.NewFile(fd, "seccomp-listener"), nil
+}
+
+// Notifies checks whether loading the filter returns a listener.
+func (f *Filter) Notifies() bool {
+	return f != nil && f.notify
+}
+
+// action converts the action of the spec into the return value of the filter.
+// The errno is returned by SCMP_ACT_ERRNO and passed to the tracer by SCMP_ACT_TRACE, it defaults to EPERM.
+func action(act specs.LinuxSeccompAction, errnoRet *uint) (uint32, error) {
+	errno := uint32(syscall.EPERM)
+	if errnoRet != nil {
+		if act != specs.ActErrno && act != specs.ActTrace {
+			return 0, fmt.Errorf("errnoRet is not supported by action %v", act)
+		}
+		if *errnoRet > 0xffff {
+			return 0, fmt.Errorf("errnoRet %d is too large", *errnoRet)
+		}
+		errno = uint32(*errnoRet)
+	}
+
+	switch act {
+	case specs.ActKill, specs.ActKillThread:
+		return retKillThread, nil
+	case specs.ActKillProcess:
+		return retKillProcess, nil
+	case specs.ActTrap:
+		return retTrap, nil
+	case specs.ActErrno:
+		return retErrno | errno, nil
+	case specs.ActTrace:
+		return retTrace | errno, nil
+	case specs.ActAllow:
+		return retAllow, nil
+	case specs.ActLog:
+		return retLog, nil
+	case specs.ActNotify:
+		return retUserNotif, nil
+	}
+	return 0, fmt.Errorf("unknown seccomp action %q", act)
+}
+
+// filterFlags converts the flags of the spec into flags of seccomp(2).
+func filterFlags(names []specs.LinuxSeccompFlag) (flags uintptr, err error) {
+	for _, name := range names {
+		switch name {
+		case "SECCOMP_FILTER_FLAG_TSYNC":
+			// the filter is loaded by the thread that executes the container process, the other threads
+			// of the init process are gone after the exec
+		case specs.LinuxSeccompFlagLog:
+			flags |= flagLog
+		case specs.LinuxSeccompFlagSpecAllow:
+			flags |= flagSpecAllow
+		case specs.LinuxSeccompFlagWaitKillableRecv:
+			flags |= flagWaitKillableRecv
+		default:
+			return 0, fmt.Errorf("unknown seccomp flag %q", name)
+		}
+	}
+	return flags, nil
+}
+
+// syscallRules collects the rules of the syscalls by name. names are the syscalls in the order of the spec.
+// Multiple conditions of the same argument in a rule are alternatives like in runc, so the rule is split
+// into one rule per condition.
+func syscallRules(syscalls []specs.LinuxSyscall) (names []string, rules map[string][]rule, err error) {
+	rules = make(map[string][]rule)
+	for _, sc := range syscalls {
+		act, err := action(sc.Action, sc.ErrnoRet)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid seccomp action of %v: %w", sc.Names, err)
+		}
+
+		var (
+			conditions = make(map[uint]int)
+			split      bool
+		)
+		for _, arg := range sc.Args {
+			if arg.Index >= 6 {
+				return nil, nil, fmt.Errorf("invalid seccomp argument index %d of %v", arg.Index, sc.Names)
+			}
+			if !knownOperator(arg.Op) {
+				return nil, nil, fmt.Errorf("unknown seccomp operator %q of %v", arg.Op, sc.Names)
+			}
+			conditions[arg.Index]++
+			split = split || conditions[arg.Index] > 1
+		}
+
+		scRules := []rule{{action: act, args: sc.Args}}
+		if split {
+			scRules = scRules[:0]
+			for _, arg := range sc.Args {
+				scRules = append(scRules, rule{action: act, args: []specs.LinuxSeccompArg{arg}})
+			}
+		}
+
+		for _, name := range sc.Names {
+			if _, ok := rules[name]; !ok {
+				names = append(names, name)
+			}
+			rules[name] = append(rules[name], scRules...)
+		}
+	}
+	return names, rules, nil
+}
+
+func knownOperator(op specs.LinuxSeccompOperator) bool {
+	switch op {
+	case specs.OpNotEqual, specs.OpLessThan, specs.OpLessEqual, specs.OpEqualTo,
+		specs.OpGreaterEqual, specs.OpGreaterThan, specs.OpMaskedEqual:
+		return true
+	}
+	return false
+}
+
+// selectArches returns the native architecture and the supported architectures of the spec.
+func selectArches(native specs.Arch, names []specs.Arch) []specs.Arch {
+	selected := []specs.Arch{native}
+	for _, name := range names {
+		if _, ok := arches[name]; !ok {
+			logger.Log().Named("seccomp").Warn("architecture is not supported", zap.String("arch", string(name)))
+			continue
+		}
+		duplicate := false
+		for _, s := range selected {
+			duplicate = duplicate || s == name
+		}
+		if !duplicate {
+			selected = append(selected, name)
+		}
+	}
+	return selected
+}
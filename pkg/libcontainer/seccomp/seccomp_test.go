@@ -0,0 +1,339 @@
+package seccomp
+
+import (
+	"encoding/binary"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// run interprets the program for a syscall like the kernel does and returns the action.
+func run(t *testing.T, filter *Filter, audit, nr uint32, args ...uint64) uint32 {
+	t.Helper()
+	data := make([]byte, offsetArgs+6*8)
+	binary.LittleEndian.PutUint32(data[offsetNr:], nr)
+	binary.LittleEndian.PutUint32(data[offsetArch:], audit)
+	for i, arg := range args {
+		binary.LittleEndian.PutUint64(data[offsetArgs+8*i:], arg)
+	}
+
+	var a uint32
+	for pc := 0; pc < len(filter.program); pc++ {
+		ins := filter.program[pc]
+		jump := func(condition bool) {
+			if condition {
+				pc += int(ins.Jt)
+			} else {
+				pc += int(ins.Jf)
+			}
+		}
+		switch ins.Code {
+		case syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS:
+			a = binary.LittleEndian.Uint32(data[ins.K:])
+		case syscall.BPF_ALU | syscall.BPF_AND | syscall.BPF_K:
+			a &= ins.K
+		case syscall.BPF_JMP | syscall.BPF_JA:
+			pc += int(ins.K)
+		case syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K:
+			jump(a == ins.K)
+		case syscall.BPF_JMP | syscall.BPF_JGT | syscall.BPF_K:
+			jump(a > ins.K)
+		case syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K:
+			jump(a >= ins.K)
+		case syscall.BPF_RET | syscall.BPF_K:
+			return ins.K
+		default:
+			t.Fatalf("unknown instruction %#x at %d", ins.Code, pc)
+		}
+	}
+	t.Fatal("program has no return")
+	return 0
+}
+
+func errnoRet(errno uint) *uint {
+	return &errno
+}
+
+func compile(t *testing.T, config *specs.LinuxSeccomp) *Filter {
+	t.Helper()
+	filter, err := Compile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+func TestCompile_Nil(t *testing.T) {
+	filter, err := Compile(nil)
+	if err != nil || filter != nil {
+		t.Errorf("expected no filter, actual: %v %v", filter, err)
+	}
+	if _, err = filter.Load(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config specs.LinuxSeccomp
+	}{
+		{"unknown default action", specs.LinuxSeccomp{DefaultAction: "SCMP_ACT_FOO"}},
+		{"errno of allow", specs.LinuxSeccomp{DefaultAction: specs.ActAllow, DefaultErrnoRet: errnoRet(1)}},
+		{"unknown flag", specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Flags: []specs.LinuxSeccompFlag{"FOO"}}},
+		{"unknown action", specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read"}, Action: "SCMP_ACT_FOO"},
+		}}},
+		{"unknown operator", specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read"}, Action: specs.ActErrno, Args: []specs.LinuxSeccompArg{{Index: 0, Op: "SCMP_CMP_FOO"}}},
+		}}},
+		{"invalid index", specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read"}, Action: specs.ActErrno, Args: []specs.LinuxSeccompArg{{Index: 6, Op: specs.OpEqualTo}}},
+		}}},
+		{"notify without listener", specs.LinuxSeccomp{DefaultAction: specs.ActAllow, Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"mkdir"}, Action: specs.ActNotify},
+		}}},
+	}
+	for _, tt := range tests {
+		if _, err := Compile(&tt.config); err == nil {
+			t.Errorf("%v: expected error", tt.name)
+		}
+	}
+}
+
+func TestCompile_Actions(t *testing.T) {
+	filter := compile(t, &specs.LinuxSeccomp{
+		DefaultAction:   specs.ActErrno,
+		DefaultErrnoRet: errnoRet(uint(syscall.ENOSYS)),
+		Architectures:   []specs.Arch{specs.ArchX86_64},
+		Flags:           []specs.LinuxSeccompFlag{specs.LinuxSeccompFlagLog},
+		ListenerPath:    "/run/listener.sock",
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"read", "write", "unknown_syscall"}, Action: specs.ActAllow},
+			{Names: []string{"kill"}, Action: specs.ActKillProcess},
+			{Names: []string{"mkdir"}, Action: specs.ActNotify},
+			{Names: []string{"ptrace"}, Action: specs.ActErrno},
+			{Names: []string{"read"}, Action: specs.ActKill},
+		},
+	})
+	if filter.flags != flagLog|flagNewListener || !filter.Notifies() {
+		t.Errorf("flags, expected: %#x, actual: %#x", flagLog|flagNewListener, filter.flags)
+	}
+
+	tests := []struct {
+		syscall  string
+		expected uint32
+	}{
+		{"read", retAllow},
+		{"write", retAllow},
+		{"kill", retKillProcess},
+		{"mkdir", retUserNotif},
+		{"ptrace", retErrno | uint32(syscall.EPERM)},
+		{"open", retErrno | uint32(syscall.ENOSYS)},
+	}
+	for _, tt := range tests {
+		if actual := run(t, filter, auditArchX86_64, syscallsX86_64[tt.syscall]); actual != tt.expected {
+			t.Errorf("%v, expected: %#x, actual: %#x", tt.syscall, tt.expected, actual)
+		}
+	}
+}
+
+func TestCompile_Arguments(t *testing.T) {
+	arg := func(index uint, op specs.LinuxSeccompOperator, value, valueTwo uint64) specs.LinuxSeccompArg {
+		return specs.LinuxSeccompArg{Index: index, Op: op, Value: value, ValueTwo: valueTwo}
+	}
+	tests := []struct {
+		arg      specs.LinuxSeccompArg
+		matching []uint64
+		other    []uint64
+	}{
+		{arg(1, specs.OpEqualTo, 0x1_00000002, 0), []uint64{0x1_00000002}, []uint64{2, 0x1_00000003, 0x2_00000002}},
+		{arg(1, specs.OpNotEqual, 0x1_00000002, 0), []uint64{2, 0x1_00000003}, []uint64{0x1_00000002}},
+		{arg(1, specs.OpMaskedEqual, 0xff_000000ff, 0x12_00000034), []uint64{0x12_00000034, 0xff12_0000ff34}, []uint64{0x12_00000035, 0x13_00000034}},
+		{arg(1, specs.OpGreaterThan, 0x1_00000002, 0), []uint64{0x1_00000003, 0x2_00000000}, []uint64{0x1_00000002, 0x0_ffffffff}},
+		{arg(1, specs.OpGreaterEqual, 0x1_00000002, 0), []uint64{0x1_00000002, 0x2_00000000}, []uint64{0x1_00000001, 0x0_ffffffff}},
+		{arg(1, specs.OpLessThan, 0x1_00000002, 0), []uint64{0x1_00000001, 0x0_ffffffff}, []uint64{0x1_00000002, 0x2_00000000}},
+		{arg(1, specs.OpLessEqual, 0x1_00000002, 0), []uint64{0x1_00000002, 0x0_ffffffff}, []uint64{0x1_00000003, 0x2_00000000}},
+	}
+	for _, tt := range tests {
+		filter := compile(t, &specs.LinuxSeccomp{
+			DefaultAction: specs.ActAllow,
+			Architectures: []specs.Arch{specs.ArchX86_64},
+			Syscalls: []specs.LinuxSyscall{
+				{Names: []string{"ioctl"}, Action: specs.ActErrno, Args: []specs.LinuxSeccompArg{tt.arg}},
+			},
+		})
+		for _, value := range tt.matching {
+			if actual := run(t, filter, auditArchX86_64, syscallsX86_64["ioctl"], 0, value); actual == retAllow {
+				t.Errorf("%v %#x: expected match of %#x", tt.arg.Op, tt.arg.Value, value)
+			}
+		}
+		for _, value := range tt.other {
+			if actual := run(t, filter, auditArchX86_64, syscallsX86_64["ioctl"], 0, value); actual != retAllow {
+				t.Errorf("%v %#x: expected no match of %#x", tt.arg.Op, tt.arg.Value, value)
+			}
+		}
+	}
+}
+
+func TestCompile_RuleOrder(t *testing.T) {
+	filter := compile(t, &specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Architectures: []specs.Arch{specs.ArchX86_64},
+		Syscalls: []specs.LinuxSyscall{
+			// conditions of different arguments have to match all
+			{Names: []string{"socket"}, Action: specs.ActAllow, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Op: specs.OpEqualTo, Value: 1},
+				{Index: 1, Op: specs.OpEqualTo, Value: 2},
+			}},
+			// conditions of the same argument are alternatives
+			{Names: []string{"socket"}, Action: specs.ActLog, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Op: specs.OpEqualTo, Value: 3},
+				{Index: 0, Op: specs.OpEqualTo, Value: 4},
+			}},
+			{Names: []string{"socket"}, Action: specs.ActTrap},
+		},
+	})
+
+	tests := []struct {
+		args     []uint64
+		expected uint32
+	}{
+		{[]uint64{1, 2}, retAllow},
+		{[]uint64{1, 3}, retTrap},
+		{[]uint64{3, 2}, retLog},
+		{[]uint64{4}, retLog},
+		{[]uint64{5}, retTrap},
+	}
+	for _, tt := range tests {
+		if actual := run(t, filter, auditArchX86_64, syscallsX86_64["socket"], tt.args...); actual != tt.expected {
+			t.Errorf("socket %v, expected: %#x, actual: %#x", tt.args, tt.expected, actual)
+		}
+	}
+}
+
+func TestCompile_Architectures(t *testing.T) {
+	config := &specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"getpid", "rt_sigreturn"}, Action: specs.ActAllow},
+			{Names: []string{"personality"}, Action: specs.ActAllow, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Op: specs.OpEqualTo, Value: 0xffffffff},
+			}},
+		},
+	}
+
+	config.Architectures = []specs.Arch{specs.ArchX86_64, specs.ArchMIPS}
+	filter := compile(t, config)
+	if actual := run(t, filter, auditArchX86_64, syscallsX86_64["getpid"]); actual != retAllow {
+		t.Errorf("x86_64 getpid, expected: allow, actual: %#x", actual)
+	}
+	if actual := run(t, filter, auditArchI386, syscallsX86["getpid"]); actual != retBadArch {
+		t.Errorf("x86 getpid without x86, expected: bad arch, actual: %#x", actual)
+	}
+	if actual := run(t, filter, auditArchX86_64, syscallsX32["getpid"]|x32SyscallBit); actual != retBadArch {
+		t.Errorf("x32 getpid without x32, expected: bad arch, actual: %#x", actual)
+	}
+
+	config.Architectures = []specs.Arch{specs.ArchX86_64, specs.ArchX86, specs.ArchX32}
+	filter = compile(t, config)
+	tests := []struct {
+		audit, nr uint32
+		args      []uint64
+		expected  uint32
+	}{
+		{auditArchI386, syscallsX86["getpid"], nil, retAllow},
+		{auditArchI386, syscallsX86["ptrace"], nil, retErrno | uint32(syscall.EPERM)},
+		{auditArchX86_64, syscallsX32["rt_sigreturn"] | x32SyscallBit, nil, retAllow},
+		{auditArchX86_64, syscallsX86_64["rt_sigreturn"], nil, retAllow},
+		{auditArchX86_64, syscallsX32["getpid"] | x32SyscallBit, nil, retAllow},
+		// the upper half of the arguments is only compared on architectures with 64-bit arguments
+		{auditArchI386, syscallsX86["personality"], []uint64{0xffffffff_ffffffff}, retAllow},
+		{auditArchX86_64, syscallsX86_64["personality"], []uint64{0xffffffff_ffffffff}, retErrno | uint32(syscall.EPERM)},
+		{auditArchX86_64, syscallsX86_64["personality"], []uint64{0xffffffff}, retAllow},
+	}
+	for _, tt := range tests {
+		if actual := run(t, filter, tt.audit, tt.nr, tt.args...); actual != tt.expected {
+			t.Errorf("arch %#x syscall %#x, expected: %#x, actual: %#x", tt.audit, tt.nr, tt.expected, actual)
+		}
+	}
+}
+
+func TestCompile_LargeProfile(t *testing.T) {
+	// like the default profiles of container engines, which allow most syscalls of three architectures
+	var names []string
+	for name := range syscallsX86_64 {
+		names = append(names, name)
+	}
+	config := &specs.LinuxSeccomp{
+		DefaultAction: specs.ActErrno,
+		Architectures: []specs.Arch{specs.ArchX86_64, specs.ArchX86, specs.ArchX32},
+		Syscalls: []specs.LinuxSyscall{
+			{Names: []string{"clone"}, Action: specs.ActErrno, Args: []specs.LinuxSeccompArg{
+				{Index: 0, Op: specs.OpMaskedEqual, Value: syscall.CLONE_NEWNS, ValueTwo: syscall.CLONE_NEWNS},
+			}},
+			{Names: names, Action: specs.ActAllow},
+		},
+	}
+	filter := compile(t, config)
+	if len(filter.program) > 2000 {
+		t.Errorf("program, expected less than 2000 instructions, actual: %d", len(filter.program))
+	}
+	for _, name := range names {
+		if name == "clone" {
+			continue
+		}
+		if actual := run(t, filter, auditArchX86_64, syscallsX86_64[name]); actual != retAllow {
+			t.Fatalf("%v, expected: allow, actual: %#x", name, actual)
+		}
+	}
+	if actual := run(t, filter, auditArchX86_64, syscallsX86_64["clone"], syscall.CLONE_NEWNS); actual == retAllow {
+		t.Errorf("clone with CLONE_NEWNS, expected: errno, actual: allow")
+	}
+	if actual := run(t, filter, auditArchX86_64, syscallsX86_64["clone"], syscall.CLONE_THREAD); actual != retAllow {
+		t.Errorf("clone, expected: allow, actual: %#x", actual)
+	}
+	if actual := run(t, filter, auditArchX86_64, 1000); actual != retErrno|uint32(syscall.EPERM) {
+		t.Errorf("unknown syscall, expected: errno, actual: %#x", actual)
+	}
+}
+
+func TestProgram_Assemble(t *testing.T) {
+	p := newProgram()
+	p.goTo("missing")
+	if _, err := p.assemble(); err == nil || !strings.Contains(err.Error(), "unknown label") {
+		t.Errorf("expected unknown label error, actual: %v", err)
+	}
+
+	p = newProgram()
+	far := p.newLabel("far")
+	p.jump(syscall.BPF_JEQ, 0, far, "")
+	for i := 0; i < 256; i++ {
+		p.ret(retAllow)
+	}
+	p.mark(far)
+	p.ret(retKillProcess)
+	if _, err := p.assemble(); err == nil || !strings.Contains(err.Error(), "too far") {
+		t.Errorf("expected too far error, actual: %v", err)
+	}
+
+	p = newProgram()
+	p.jumpTo(syscall.BPF_JEQ, 0, far)
+	for i := 0; i < 256; i++ {
+		p.ret(retAllow)
+	}
+	p.mark(far)
+	p.ret(retKillProcess)
+	program, err := p.assemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := run(t, &Filter{program: program}, 0, 0); actual != retKillProcess {
+		t.Errorf("far jump, expected: %#x, actual: %#x", retKillProcess, actual)
+	}
+	if program[1].K != 256 {
+		t.Errorf("ja offset, expected: 256, actual: %d", program[1].K)
+	}
+}
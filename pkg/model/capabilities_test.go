@@ -0,0 +1,81 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCapability(t *testing.T) {
+	tests := []struct {
+		name           string
+		capabilityName string
+		want           int
+		expectError    bool
+	}{
+		{
+			name:           "Capability with CAP prefix",
+			capabilityName: "CAP_CHOWN",
+			want:           0,
+		},
+		{
+			name:           "Capability without CAP prefix",
+			capabilityName: "SYS_ADMIN",
+			want:           21,
+		},
+		{
+			name:           "Last capability",
+			capabilityName: "CAP_CHECKPOINT_RESTORE",
+			want:           40,
+		},
+		{
+			name:           "Unknown capability name",
+			capabilityName: "CAP_UNKNOWN",
+			expectError:    true,
+		},
+		{
+			name:           "Empty capability name",
+			capabilityName: "",
+			expectError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Capability(tt.capabilityName)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Capability(%v) error = %v, expectError %v", tt.capabilityName, err, tt.expectError)
+				return
+			}
+			if !tt.expectError && got != tt.want {
+				t.Errorf("Capability(%v) = %v, want %v", tt.capabilityName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	got, err := Capabilities([]string{"CAP_KILL", "CAP_NET_RAW"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 5 || got[1] != 13 {
+		t.Errorf("Capabilities() = %v, want [5 13]", got)
+	}
+
+	_, err = Capabilities([]string{"CAP_KILL", "CAP_FOO", "CAP_BAR"})
+	if err == nil || !strings.Contains(err.Error(), "CAP_FOO, CAP_BAR") {
+		t.Errorf("expected error that lists the unknown capabilities, actual: %v", err)
+	}
+}
+
+func TestCapabilityMapComplete(t *testing.T) {
+	numbers := make(map[int]bool, len(capabilityMap))
+	for _, capability := range capabilityMap {
+		numbers[capability] = true
+	}
+	for capability := 0; capability < len(capabilityMap); capability++ {
+		if !numbers[capability] {
+			t.Errorf("capability %d is missing", capability)
+		}
+	}
+}
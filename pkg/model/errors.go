@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 )
 
@@ -28,6 +29,14 @@ var (
 	// ErrNotRunning indicates that the container is not currently running.
 	ErrNotRunning     = errors.New("container not running")
 	ErrNotRunningExit = 105
+
+	// ErrPaused indicates that the container is paused and cannot perform the requested operation.
+	ErrPaused     = errors.New("container paused")
+	ErrPausedExit = 106
+
+	// ErrNotPaused indicates that the container is not paused.
+	ErrNotPaused     = errors.New("container not paused")
+	ErrNotPausedExit = 107
 )
 
 var (
@@ -41,9 +50,22 @@ var (
 	UnknownErrorExit       = 1
 )
 
+// ProcessExitError reports the exit status of a process that was run inside a container.
+// The runtime exits with the same status, like runc does.
+type ProcessExitError struct {
+	Status int
+}
+
+func (e *ProcessExitError) Error() string {
+	return fmt.Sprintf("process exited with status %d", e.Status)
+}
+
 // ExitCode maps an error to a return code
 func ExitCode(err error) int {
+	var exitErr *ProcessExitError
 	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.Status
 	case errors.Is(err, ErrExist):
 		return ErrExistExit
 	case errors.Is(err, ErrInvalidID):
@@ -54,6 +76,10 @@ func ExitCode(err error) int {
 		return ErrRunningExit
 	case errors.Is(err, ErrNotRunning):
 		return ErrNotRunningExit
+	case errors.Is(err, ErrPaused):
+		return ErrPausedExit
+	case errors.Is(err, ErrNotPaused):
+		return ErrNotPausedExit
 	case errors.Is(err, ErrNoSudo):
 		return ErrNoSudoExit
 	case os.IsNotExist(err):
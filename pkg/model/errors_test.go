@@ -38,6 +38,16 @@ func TestExitCode(t *testing.T) {
 			err:      ErrNotRunning,
 			wantCode: ErrNotRunningExit,
 		},
+		{
+			name:     "ErrPaused",
+			err:      ErrPaused,
+			wantCode: ErrPausedExit,
+		},
+		{
+			name:     "ErrNotPaused",
+			err:      ErrNotPaused,
+			wantCode: ErrNotPausedExit,
+		},
 		{
 			name:     "ErrNoSudo",
 			err:      ErrNoSudo,
@@ -58,6 +68,11 @@ func TestExitCode(t *testing.T) {
 			err:      context.Canceled,
 			wantCode: ErrContextCanceledExit,
 		},
+		{
+			name:     "ProcessExitError",
+			err:      &ProcessExitError{Status: 137},
+			wantCode: 137,
+		},
 		{
 			name:     "Unknown error",
 			err:      errors.New("unknown error"),
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rlimit converts a resource name (e.g., "RLIMIT_NOFILE") into its number, see getrlimit(2).
+// It returns an error if the resource name is unknown.
+func Rlimit(name string) (int, error) {
+	resource, exists := rlimitMap[name]
+	if !exists {
+		return 0, fmt.Errorf("unknown rlimit %v", name)
+	}
+	return resource, nil
+}
+
+// Rlimits checks that the resources of the limits are known.
+// It returns an error that lists all unknown names.
+func Rlimits(names []string) error {
+	var unknown []string
+	for _, name := range names {
+		if _, err := Rlimit(name); err != nil {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown rlimits: %v", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+var rlimitMap = map[string]int{
+	"RLIMIT_CPU":        0,
+	"RLIMIT_FSIZE":      1,
+	"RLIMIT_DATA":       2,
+	"RLIMIT_STACK":      3,
+	"RLIMIT_CORE":       4,
+	"RLIMIT_RSS":        5,
+	"RLIMIT_NPROC":      6,
+	"RLIMIT_NOFILE":     7,
+	"RLIMIT_MEMLOCK":    8,
+	"RLIMIT_AS":         9,
+	"RLIMIT_LOCKS":      10,
+	"RLIMIT_SIGPENDING": 11,
+	"RLIMIT_MSGQUEUE":   12,
+	"RLIMIT_NICE":       13,
+	"RLIMIT_RTPRIO":     14,
+	"RLIMIT_RTTIME":     15,
+}
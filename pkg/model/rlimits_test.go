@@ -0,0 +1,40 @@
+package model
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestRlimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int
+	}{
+		{"RLIMIT_NOFILE", syscall.RLIMIT_NOFILE},
+		{"RLIMIT_CORE", syscall.RLIMIT_CORE},
+		{"RLIMIT_RTTIME", 15},
+	}
+	for _, tt := range tests {
+		actual, err := Rlimit(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != tt.expected {
+			t.Errorf("%v, expected: %d, actual: %d", tt.name, tt.expected, actual)
+		}
+	}
+
+	if _, err := Rlimit("NOFILE"); err == nil {
+		t.Error("expected error for name without prefix")
+	}
+}
+
+func TestRlimits(t *testing.T) {
+	if err := Rlimits([]string{"RLIMIT_NOFILE", "RLIMIT_NPROC"}); err != nil {
+		t.Error(err)
+	}
+	err := Rlimits([]string{"RLIMIT_FOO", "RLIMIT_NOFILE", "RLIMIT_BAR"})
+	if expected := "unknown rlimits: RLIMIT_FOO, RLIMIT_BAR"; err == nil || err.Error() != expected {
+		t.Errorf("error, expected: %v, actual: %v", expected, err)
+	}
+}
@@ -29,6 +29,15 @@ func SyscallSignal(signalName string) (syscall.Signal, error) {
 	return signal, nil
 }
 
+// ExitStatus converts the wait status of a process into an exit code.
+// Processes terminated by a signal report 128 plus the signal number, like runc does.
+func ExitStatus(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
 var signalMap = map[string]syscall.Signal{
 	"SIGHUP":    1,
 	"SIGINT":    2,
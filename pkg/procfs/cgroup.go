@@ -0,0 +1,32 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cgroup returns the cgroup v2 path of the specified process ID (pid).
+// The path is relative to the root of the cgroup v2 hierarchy.
+func (F *FS) Cgroup(pid Pid) (string, error) {
+	f, err := os.Open(filepath.Join(F.procfsPath, pid.String(), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// the cgroup v2 entry has the format "0::<path>"
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("no cgroup v2 entry found for pid %v", pid)
+}
@@ -0,0 +1,42 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFS_Cgroup(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	content := "1:cpu:/\n0::/user.slice/session-1.scope\n"
+	err := os.WriteFile(filepath.Join(fs.procfsPath, testPidStr, "cgroup"), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := fs.Cgroup(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/user.slice/session-1.scope" {
+		t.Errorf("expected: %v, actual: %v", "/user.slice/session-1.scope", path)
+	}
+}
+
+func TestFS_Cgroup_NoUnifiedEntry(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	err := os.WriteFile(filepath.Join(fs.procfsPath, testPidStr, "cgroup"), []byte("1:cpu:/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fs.Cgroup(testPid)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
@@ -2,16 +2,21 @@ package procfs
 
 import (
 	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const (
 	// uidMapFileName is the name of the file that stores UID mapping information.
-	uidMapFileName = "uid.map"
+	uidMapFileName = "uid_map"
 
 	// gidMapFileName is the name of the file that stores GID mapping information.
-	gidMapFileName = "gid.map"
+	gidMapFileName = "gid_map"
+
+	// setgroupsFileName is the name of the file that allows or denies setgroups(2) in a user namespace.
+	setgroupsFileName = "setgroups"
 
 	// mapFilePermissions defines the file permissions for the UID/GID map files.
 	mapFilePermissions = 0666
@@ -19,29 +24,56 @@ const (
 
 // IdMapper writes mappings for UIDs and GIDs for a given process ID.
 type IdMapper interface {
-	// MapUid maps a UID from inside a container to an outside UID for the given process ID (pid).
-	MapUid(pid Pid, insideId, outsideId uint32) error
+	// MapUids maps ranges of UIDs from inside a container to outside UIDs for the given process ID (pid).
+	MapUids(pid Pid, mappings []specs.LinuxIDMapping) error
+
+	// MapGids maps ranges of GIDs from inside a container to outside GIDs for the given process ID (pid).
+	MapGids(pid Pid, mappings []specs.LinuxIDMapping) error
+
+	// SetGroups allows or denies setgroups(2) in the user namespace of the given process ID (pid).
+	// It has to be set before the GIDs are mapped.
+	SetGroups(pid Pid, allow bool) error
+}
 
-	// MapGid maps a GID from inside a container to an outside GID for the given process ID (pid).
-	MapGid(pid Pid, insideId, outsideId uint32) error
+// MapUids maps ranges of UIDs from inside a container to outside UIDs using the uid_map file.
+// It writes the mappings to the appropriate file in the specified procfs path for the process ID (pid).
+func (F *FS) MapUids(pid Pid, mappings []specs.LinuxIDMapping) error {
+	return mapIds(F.procfsPath, pid, uidMapFileName, mappings)
 }
 
-// MapUid maps a UID from inside a container to an outside UID using the uid.map file.
-// It writes the mapping to the appropriate file in the specified procfs path for the process ID (pid).
-func (F *FS) MapUid(pid Pid, insideId, outsideId uint32) error {
-	return mapId(F.procfsPath, pid, uidMapFileName, insideId, outsideId)
+// MapGids maps ranges of GIDs from inside a container to outside GIDs using the gid_map file.
+// It writes the mappings to the appropriate file in the specified procfs path for the process ID (pid).
+func (F *FS) MapGids(pid Pid, mappings []specs.LinuxIDMapping) error {
+	return mapIds(F.procfsPath, pid, gidMapFileName, mappings)
 }
 
-// MapGid maps a GID from inside a container to an outside GID using the gid.map file.
-// It writes the mapping to the appropriate file in the specified procfs path for the process ID (pid).
-func (F *FS) MapGid(pid Pid, insideId, outsideId uint32) error {
-	return mapId(F.procfsPath, pid, gidMapFileName, insideId, outsideId)
+// SetGroups writes "allow" or "deny" to the setgroups file of the process ID (pid).
+// Unprivileged processes have to deny setgroups before they are allowed to map GIDs, see user_namespaces(7).
+func (F *FS) SetGroups(pid Pid, allow bool) error {
+	value := "deny"
+	if allow {
+		value = "allow"
+	}
+	path := filepath.Join(F.procfsPath, pid.String(), setgroupsFileName)
+	return os.WriteFile(path, []byte(value), mapFilePermissions)
 }
 
-// mapId is a helper function that performs the actual ID mapping.
-// It writes the mapping of IDs to the appropriate map file (uid.map or gid.map) in the specified base path.
-func mapId(basePath string, pid Pid, mapFile string, insideId, outsideId uint32) error {
+// mapIds is a helper function that performs the actual ID mapping.
+// It writes the mappings of IDs to the appropriate map file (uid_map or gid_map) in the specified base path.
+// All mappings are written at once, because the kernel only accepts a single write to a map file.
+func mapIds(basePath string, pid Pid, mapFile string, mappings []specs.LinuxIDMapping) error {
+	if len(mappings) == 0 {
+		return fmt.Errorf("no mappings for %v", mapFile)
+	}
+
+	var b strings.Builder
+	for _, m := range mappings {
+		if m.Size == 0 {
+			return fmt.Errorf("mapping %d %d of %v has size 0", m.ContainerID, m.HostID, mapFile)
+		}
+		fmt.Fprintf(&b, "%d %d %d\n", m.ContainerID, m.HostID, m.Size)
+	}
+
 	path := filepath.Join(basePath, pid.String(), mapFile)
-	mapping := fmt.Sprintf("%d %d %d", insideId, outsideId, 1)
-	return os.WriteFile(path, []byte(mapping), mapFilePermissions)
+	return os.WriteFile(path, []byte(b.String()), mapFilePermissions)
 }
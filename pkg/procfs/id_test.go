@@ -5,11 +5,20 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
 const testPid = 42
 const testPidStr = "42"
 
+var testMappings = []specs.LinuxIDMapping{
+	{ContainerID: 0, HostID: 100000, Size: 1},
+	{ContainerID: 1, HostID: 200000, Size: 65535},
+}
+
+const testMapFile = "0 100000 1\n1 200000 65535\n"
+
 func newTestFs() (fs *FS, deleteFS func()) {
 	testFSPath, err := os.MkdirTemp("", "procfs")
 	if err != nil {
@@ -26,17 +35,17 @@ func newTestFs() (fs *FS, deleteFS func()) {
 	}
 }
 
-func TestFS_MapGid(t *testing.T) {
+func TestFS_MapGids(t *testing.T) {
 	fs, deleteFs := newTestFs()
 	defer deleteFs()
 
-	err := fs.MapGid(testPid, 0, 2)
+	err := fs.MapGids(testPid, testMappings)
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
-	mapFilePath := filepath.Join(fs.procfsPath, testPidStr, "gid.map")
+	mapFilePath := filepath.Join(fs.procfsPath, testPidStr, "gid_map")
 	_, err = os.Stat(mapFilePath)
 	if errors.Is(err, os.ErrNotExist) {
 		t.Error(err)
@@ -49,23 +58,23 @@ func TestFS_MapGid(t *testing.T) {
 		return
 	}
 
-	if string(mapFile) != "0 2 1" {
+	if string(mapFile) != testMapFile {
 		t.Log(string(mapFile))
 		t.Fail()
 	}
 }
 
-func TestFS_MapUid(t *testing.T) {
+func TestFS_MapUids(t *testing.T) {
 	fs, deleteFs := newTestFs()
 	defer deleteFs()
 
-	err := fs.MapUid(testPid, 0, 2)
+	err := fs.MapUids(testPid, testMappings)
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
-	mapFilePath := filepath.Join(fs.procfsPath, testPidStr, "uid.map")
+	mapFilePath := filepath.Join(fs.procfsPath, testPidStr, "uid_map")
 	_, err = os.Stat(mapFilePath)
 	if errors.Is(err, os.ErrNotExist) {
 		t.Error(err)
@@ -78,8 +87,42 @@ func TestFS_MapUid(t *testing.T) {
 		return
 	}
 
-	if string(mapFile) != "0 2 1" {
+	if string(mapFile) != testMapFile {
 		t.Log(string(mapFile))
 		t.Fail()
 	}
 }
+
+func TestFS_MapUidsInvalid(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	if err := fs.MapUids(testPid, nil); err == nil {
+		t.Error("expected error for empty mappings")
+	}
+	if err := fs.MapUids(testPid, []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000}}); err == nil {
+		t.Error("expected error for mapping without size")
+	}
+}
+
+func TestFS_SetGroups(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	for allow, expected := range map[bool]string{true: "allow", false: "deny"} {
+		err := fs.SetGroups(testPid, allow)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		setgroups, err := os.ReadFile(filepath.Join(fs.procfsPath, testPidStr, "setgroups"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(setgroups) != expected {
+			t.Errorf("setgroups, expected: %v, actual: %v", expected, string(setgroups))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MountInfo contains the fields of an entry of /proc/<pid>/mountinfo, see proc(5).
+type MountInfo struct {
+	ID         int
+	ParentID   int
+	Root       string   // root of the mount within the filesystem
+	MountPoint string   // relative to the root of the process
+	Options    string   // per mount options
+	Optional   []string // optional fields, e.g. shared:1 or master:2
+	FSType     string
+	Source     string
+}
+
+// Shared checks whether the mount is in a shared peer group.
+func (m MountInfo) Shared() bool {
+	for _, field := range m.Optional {
+		if strings.HasPrefix(field, "shared:") {
+			return true
+		}
+	}
+	return false
+}
+
+// MountInfo reads and parses /proc/<pid>/mountinfo of the specified process ID (pid).
+// The mounts are returned in the order of the file, later mounts are on top of earlier ones.
+func (F *FS) MountInfo(pid Pid) ([]MountInfo, error) {
+	data, err := os.ReadFile(filepath.Join(F.procfsPath, pid.String(), "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	return parseMountInfo(data)
+}
+
+func parseMountInfo(data []byte) (mounts []MountInfo, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if separator < 6 || len(fields) < separator+3 {
+			return nil, fmt.Errorf("invalid mountinfo line %q", scanner.Text())
+		}
+
+		var m MountInfo
+		if m.ID, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, err
+		}
+		if m.ParentID, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, err
+		}
+		m.Root = unescapeOctal(fields[3])
+		m.MountPoint = unescapeOctal(fields[4])
+		m.Options = fields[5]
+		m.Optional = fields[6:separator]
+		m.FSType = fields[separator+1]
+		m.Source = unescapeOctal(fields[separator+2])
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeOctal replaces the octal escapes of spaces, tabs, newlines and backslashes in paths of mountinfo.
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testMountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+36 22 8:1 /srv/with\040space /mnt/with\040space rw,noatime master:1 - ext4 /dev/sda1 rw,errors=continue
+37 22 0:33 / /tmp rw - tmpfs tmpfs rw
+`
+
+func TestFS_MountInfo(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+	if err := os.WriteFile(filepath.Join(fs.procfsPath, testPidStr, "mountinfo"), []byte(testMountInfo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts, err := fs.MountInfo(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 4 {
+		t.Fatalf("mounts, expected: 4, actual: %d", len(mounts))
+	}
+
+	m := mounts[2]
+	if m.ID != 36 || m.ParentID != 22 || m.Root != "/srv/with space" || m.MountPoint != "/mnt/with space" ||
+		m.Options != "rw,noatime" || m.FSType != "ext4" || m.Source != "/dev/sda1" {
+		t.Errorf("unexpected mount %+v", m)
+	}
+	if m.Shared() || !mounts[0].Shared() || mounts[3].Shared() {
+		t.Errorf("shared, expected: true for / only, actual: %v %v %v", mounts[0].Shared(), m.Shared(), mounts[3].Shared())
+	}
+}
+
+func TestParseMountInfo_Invalid(t *testing.T) {
+	if _, err := parseMountInfo([]byte("22 1 8:1 / / rw shared:1\n")); err == nil {
+		t.Error("expected error for line without separator")
+	}
+}
+
+func TestUnescapeOctal(t *testing.T) {
+	for escaped, expected := range map[string]string{
+		`/a\040b`:    "/a b",
+		`/a\134b`:    `/a\b`,
+		`/plain`:     "/plain",
+		`/trailing\`: `/trailing\`,
+	} {
+		if actual := unescapeOctal(escaped); actual != expected {
+			t.Errorf("unescapeOctal(%q), expected: %q, actual: %q", escaped, expected, actual)
+		}
+	}
+}
@@ -3,26 +3,112 @@ package procfs
 import (
 	"fmt"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"os"
 	"path/filepath"
 	"syscall"
 )
 
+// nsFileNames maps the OCI namespace types to the file names used in /proc/<pid>/ns
+var nsFileNames = map[specs.LinuxNamespaceType]string{
+	specs.PIDNamespace:     "pid",
+	specs.NetworkNamespace: "net",
+	specs.MountNamespace:   "mnt",
+	specs.IPCNamespace:     "ipc",
+	specs.UTSNamespace:     "uts",
+	specs.UserNamespace:    "user",
+	specs.CgroupNamespace:  "cgroup",
+	specs.TimeNamespace:    "time",
+}
+
+// NsPath returns the path of the namespace file of the specified process ID (pid) and namespace type.
+func (F *FS) NsPath(pid Pid, namespaceType specs.LinuxNamespaceType) string {
+	name, ok := nsFileNames[namespaceType]
+	if !ok {
+		name = string(namespaceType)
+	}
+	return filepath.Join(F.procfsPath, pid.String(), "ns", name)
+}
+
+// nsCloneFlags maps the OCI namespace types to the clone flags that identify them in setns(2)
+var nsCloneFlags = map[specs.LinuxNamespaceType]int{
+	specs.PIDNamespace:     syscall.CLONE_NEWPID,
+	specs.NetworkNamespace: syscall.CLONE_NEWNET,
+	specs.MountNamespace:   syscall.CLONE_NEWNS,
+	specs.IPCNamespace:     syscall.CLONE_NEWIPC,
+	specs.UTSNamespace:     syscall.CLONE_NEWUTS,
+	specs.UserNamespace:    syscall.CLONE_NEWUSER,
+	specs.CgroupNamespace:  syscall.CLONE_NEWCGROUP,
+	specs.TimeNamespace:    syscall.CLONE_NEWTIME,
+}
+
+const (
+	// nsfsMagic is the file system type of namespace files (NSFS_MAGIC)
+	nsfsMagic = 0x6e736673
+
+	// nsGetNsType is the ioctl that returns the clone flag of a namespace file (NS_GET_NSTYPE)
+	nsGetNsType = 0xb703
+)
+
 // Setns changes the namespace of the current process to the namespace of the specified process ID (pid).
 func (F *FS) Setns(pid Pid, namespaceType specs.LinuxNamespaceType) error {
-	// Open the namespace fd in the procfs directory
-	nsFdPath := filepath.Join(F.procfsPath, pid.String(), "ns", string(namespaceType))
-	fd, err := syscall.Open(nsFdPath, syscall.O_RDONLY, 0644)
+	return JoinNamespace(F.NsPath(pid, namespaceType), namespaceType)
+}
+
+// JoinNamespace changes the namespace of the calling thread to the namespace at path,
+// e.g. /proc/<pid>/ns/net or a bind mount of it. The path has to refer to a namespace of namespaceType.
+func JoinNamespace(path string, namespaceType specs.LinuxNamespaceType) error {
+	f, err := OpenNamespace(path, namespaceType)
 	if err != nil {
 		return err
 	}
-	defer syscall.Close(fd)
+	defer f.Close()
+
+	// Perform the setns syscall to change the current thread's namespace to the target namespace.
+	// 308 is the syscall number for setns on Linux. The nstype argument makes the kernel check the type again.
+	_, _, errno := syscall.RawSyscall(308, f.Fd(), uintptr(nsCloneFlags[namespaceType]), 0)
+	if errno != 0 {
+		return fmt.Errorf("setns %v: %w", path, errno)
+	}
 
-	// Perform the setns syscall to change the current process's namespace to the target namespace.
-	// 308 is the syscall number for setns on Linux.
-	setnserr, _, _ := syscall.RawSyscall(308, uintptr(fd), 0, 0)
-	if setnserr != 0 {
-		return fmt.Errorf("%v", setnserr)
+	return nil
+}
+
+// OpenNamespace opens the namespace file at path, which has to refer to a namespace of namespaceType.
+// The file can be passed to a process that joins the namespace itself.
+func OpenNamespace(path string, namespaceType specs.LinuxNamespaceType) (*os.File, error) {
+	flag, ok := nsCloneFlags[namespaceType]
+	if !ok {
+		return nil, fmt.Errorf("unknown namespace type %v", namespaceType)
+	}
+
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkNamespace(fd, flag); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("%v: %w", path, err)
 	}
+	return os.NewFile(uintptr(fd), path), nil
+}
 
+// checkNamespace checks that fd is a namespace file of the namespace identified by the clone flag.
+func checkNamespace(fd int, flag int) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Fstatfs(fd, &stat); err != nil {
+		return err
+	}
+	if stat.Type != nsfsMagic {
+		return fmt.Errorf("not a namespace file")
+	}
+
+	nsType, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), nsGetNsType, 0)
+	if errno != 0 {
+		// kernels before 4.11 don't support NS_GET_NSTYPE, setns checks the type instead
+		return nil
+	}
+	if int(nsType) != flag {
+		return fmt.Errorf("namespace type mismatch, expected: %#x, actual: %#x", flag, nsType)
+	}
 	return nil
 }
@@ -0,0 +1,48 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func openNsFile(t *testing.T, path string) int {
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("namespace file not available: %v", err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	return fd
+}
+
+func TestCheckNamespace(t *testing.T) {
+	fd := openNsFile(t, Root.NsPath(PidSelf, specs.NetworkNamespace))
+
+	if err := checkNamespace(fd, syscall.CLONE_NEWNET); err != nil {
+		t.Error(err)
+	}
+	if err := checkNamespace(fd, syscall.CLONE_NEWIPC); err == nil {
+		t.Error("expected type mismatch")
+	}
+}
+
+func TestCheckNamespaceRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "net")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fd := openNsFile(t, path)
+
+	if err := checkNamespace(fd, syscall.CLONE_NEWNET); err == nil {
+		t.Error("expected error for regular file")
+	}
+}
+
+func TestJoinNamespaceUnknownType(t *testing.T) {
+	if err := JoinNamespace("/proc/self/ns/net", "unknown"); err == nil {
+		t.Error("expected error for unknown namespace type")
+	}
+}
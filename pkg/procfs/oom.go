@@ -0,0 +1,17 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// oomScoreAdjFileName is the name of the file with the adjustment of the oom killer score of a process, see proc(5).
+const oomScoreAdjFileName = "oom_score_adj"
+
+// SetOOMScoreAdj writes the adjustment of the oom killer score of the process ID (pid).
+// Lowering it requires CAP_SYS_RESOURCE.
+func (F *FS) SetOOMScoreAdj(pid Pid, score int) error {
+	path := filepath.Join(F.procfsPath, pid.String(), oomScoreAdjFileName)
+	return os.WriteFile(path, []byte(strconv.Itoa(score)), mapFilePermissions)
+}
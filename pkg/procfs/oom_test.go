@@ -0,0 +1,24 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFS_SetOOMScoreAdj(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	if err := fs.SetOOMScoreAdj(testPid, -500); err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := os.ReadFile(filepath.Join(fs.procfsPath, testPidStr, oomScoreAdjFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(score) != "-500" {
+		t.Errorf("score, expected: -500, actual: %q", string(score))
+	}
+}
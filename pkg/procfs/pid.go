@@ -3,6 +3,9 @@ package procfs
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
+	"strconv"
 )
 
 // Pid represents a process ID.
@@ -27,3 +30,17 @@ func pid(pid Pid) string {
 		return fmt.Sprintf("%d", pid)
 	}
 }
+
+// Self returns the pid of the calling process in the pid namespace of the procfs mount.
+// It differs from os.Getpid if the process was moved into a new pid namespace.
+func (F *FS) Self() (Pid, error) {
+	link, err := os.Readlink(filepath.Join(F.procfsPath, PidSelf.String()))
+	if err != nil {
+		return 0, err
+	}
+	p, err := strconv.Atoi(link)
+	if err != nil {
+		return 0, fmt.Errorf("invalid self link %q: %w", link, err)
+	}
+	return Pid(p), nil
+}
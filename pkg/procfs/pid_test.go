@@ -2,6 +2,7 @@ package procfs
 
 import (
 	"fmt"
+	"os"
 	"testing"
 )
 
@@ -13,3 +14,13 @@ func TestPid_String_PidSelf(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestFS_Self(t *testing.T) {
+	pid, err := Root.Self()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(pid) != os.Getpid() {
+		t.Errorf("pid, expected: %d, actual: %d", os.Getpid(), pid)
+	}
+}
@@ -0,0 +1,317 @@
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ClockTicks is the number of clock ticks per second (USER_HZ) used by the times in /proc/<pid>/stat
+const ClockTicks = 100
+
+// ProcStat contains the fields of /proc/<pid>/stat used by the runtime.
+// See proc(5) for a description of the fields.
+type ProcStat struct {
+	Pid        int
+	Comm       string
+	State      string
+	PPid       int
+	Pgrp       int
+	Session    int
+	TtyNr      int
+	UTime      uint64 // in clock ticks
+	STime      uint64 // in clock ticks
+	NumThreads int
+	StartTime  uint64 // in clock ticks after boot
+	VSize      uint64 // in bytes
+	Rss        int64  // in pages
+}
+
+// ProcStatus contains the fields of /proc/<pid>/status used by the runtime.
+type ProcStatus struct {
+	Name    string
+	State   string
+	Pid     int
+	PPid    int
+	Uids    [4]uint32 // real, effective, saved set, filesystem
+	Gids    [4]uint32 // real, effective, saved set, filesystem
+	VmRSS   uint64    // in bytes
+	Threads int
+	NSpid   []int // pid in each pid namespace, the last entry is the innermost namespace
+}
+
+// ProcIO contains the fields of /proc/<pid>/io.
+type ProcIO struct {
+	ReadChars  uint64 // rchar
+	WriteChars uint64 // wchar
+	ReadCalls  uint64 // syscr
+	WriteCalls uint64 // syscw
+	ReadBytes  uint64 // read_bytes, bytes fetched from the storage layer
+	WriteBytes uint64 // write_bytes, bytes sent to the storage layer
+}
+
+// Stat reads and parses /proc/<pid>/stat of the specified process ID (pid).
+func (F *FS) Stat(pid Pid) (stat ProcStat, err error) {
+	content, err := os.ReadFile(filepath.Join(F.procfsPath, pid.String(), "stat"))
+	if err != nil {
+		return stat, err
+	}
+	return parseStat(content)
+}
+
+// parseStat parses the content of /proc/<pid>/stat
+func parseStat(content []byte) (stat ProcStat, err error) {
+	// comm is enclosed in parentheses and may contain spaces and parentheses itself
+	start, end := bytes.IndexByte(content, '('), bytes.LastIndexByte(content, ')')
+	if start < 0 || end < start {
+		return stat, fmt.Errorf("invalid stat format")
+	}
+	stat.Comm = string(content[start+1 : end])
+
+	if stat.Pid, err = strconv.Atoi(strings.TrimSpace(string(content[:start]))); err != nil {
+		return stat, err
+	}
+
+	// fields[0] is field 3 (state) of proc(5)
+	fields := strings.Fields(string(content[end+1:]))
+	if len(fields) < 22 {
+		return stat, fmt.Errorf("invalid stat format: %d fields", len(fields))
+	}
+
+	stat.State = fields[0]
+	ints := []struct {
+		field int
+		value *int
+	}{
+		{1, &stat.PPid},
+		{2, &stat.Pgrp},
+		{3, &stat.Session},
+		{4, &stat.TtyNr},
+		{17, &stat.NumThreads},
+	}
+	for _, i := range ints {
+		if *i.value, err = strconv.Atoi(fields[i.field]); err != nil {
+			return stat, err
+		}
+	}
+
+	uints := []struct {
+		field int
+		value *uint64
+	}{
+		{11, &stat.UTime},
+		{12, &stat.STime},
+		{19, &stat.StartTime},
+		{20, &stat.VSize},
+	}
+	for _, u := range uints {
+		if *u.value, err = strconv.ParseUint(fields[u.field], 10, 64); err != nil {
+			return stat, err
+		}
+	}
+
+	stat.Rss, err = strconv.ParseInt(fields[21], 10, 64)
+	return stat, err
+}
+
+// Status reads and parses /proc/<pid>/status of the specified process ID (pid).
+func (F *FS) Status(pid Pid) (status ProcStatus, err error) {
+	f, err := os.Open(filepath.Join(F.procfsPath, pid.String(), "status"))
+	if err != nil {
+		return status, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			status.Name = value
+		case "State":
+			status.State = value
+		case "Pid":
+			status.Pid, err = strconv.Atoi(value)
+		case "PPid":
+			status.PPid, err = strconv.Atoi(value)
+		case "Uid":
+			err = parseIds(value, &status.Uids)
+		case "Gid":
+			err = parseIds(value, &status.Gids)
+		case "VmRSS":
+			var kb uint64
+			kb, err = strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+			status.VmRSS = kb * 1024
+		case "Threads":
+			status.Threads, err = strconv.Atoi(value)
+		case "NSpid":
+			for _, field := range strings.Fields(value) {
+				var nspid int
+				if nspid, err = strconv.Atoi(field); err != nil {
+					break
+				}
+				status.NSpid = append(status.NSpid, nspid)
+			}
+		}
+		if err != nil {
+			return status, fmt.Errorf("invalid status field %v: %w", key, err)
+		}
+	}
+
+	return status, scanner.Err()
+}
+
+// parseIds parses the four tab separated ids of the Uid and Gid fields of /proc/<pid>/status
+func parseIds(value string, ids *[4]uint32) error {
+	fields := strings.Fields(value)
+	if len(fields) != len(ids) {
+		return fmt.Errorf("expected %d ids, got %d", len(ids), len(fields))
+	}
+	for i, field := range fields {
+		id, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return err
+		}
+		ids[i] = uint32(id)
+	}
+	return nil
+}
+
+// IO reads and parses /proc/<pid>/io of the specified process ID (pid).
+func (F *FS) IO(pid Pid) (io ProcIO, err error) {
+	f, err := os.Open(filepath.Join(F.procfsPath, pid.String(), "io"))
+	if err != nil {
+		return io, err
+	}
+	defer f.Close()
+
+	fields := map[string]*uint64{
+		"rchar":       &io.ReadChars,
+		"wchar":       &io.WriteChars,
+		"syscr":       &io.ReadCalls,
+		"syscw":       &io.WriteCalls,
+		"read_bytes":  &io.ReadBytes,
+		"write_bytes": &io.WriteBytes,
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		field, ok := fields[key]
+		if !found || !ok {
+			continue
+		}
+		if *field, err = strconv.ParseUint(strings.TrimSpace(value), 10, 64); err != nil {
+			return io, fmt.Errorf("invalid io field %v: %w", key, err)
+		}
+	}
+	return io, scanner.Err()
+}
+
+// Cmdline returns the command line arguments of the specified process ID (pid).
+// Kernel threads and zombie processes have no arguments.
+func (F *FS) Cmdline(pid Pid) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(F.procfsPath, pid.String(), "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+
+	content = bytes.TrimRight(content, "\x00")
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(content), "\x00"), nil
+}
+
+// Pids returns the process IDs of all processes in the procfs.
+func (F *FS) Pids() (pids []Pid, err error) {
+	entries, err := os.ReadDir(F.procfsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		pids = append(pids, Pid(pid))
+	}
+	return pids, nil
+}
+
+// NsInode returns the inode number that identifies the namespace of the specified process ID (pid).
+// Processes in the same namespace have the same inode number.
+func (F *FS) NsInode(pid Pid, namespaceType specs.LinuxNamespaceType) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(F.NsPath(pid, namespaceType), &stat); err != nil {
+		return 0, err
+	}
+	return stat.Ino, nil
+}
+
+// BootTime returns the boot time of the system from /proc/stat.
+func (F *FS) BootTime() (time.Time, error) {
+	f, err := os.Open(filepath.Join(F.procfsPath, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+			btime, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(btime, 0), nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("btime not found")
+}
+
+// CapLastCap returns the highest capability supported by the kernel from /proc/sys/kernel/cap_last_cap.
+func (F *FS) CapLastCap() (int, error) {
+	data, err := os.ReadFile(filepath.Join(F.procfsPath, "sys/kernel/cap_last_cap"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// PidsInNamespace returns the process IDs of all processes that share the pid namespace
+// of the specified process ID (pid), including pid itself.
+func (F *FS) PidsInNamespace(pid Pid) (pids []Pid, err error) {
+	inode, err := F.NsInode(pid, specs.PIDNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := F.Pids()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range all {
+		// processes may exit while walking the procfs
+		if i, err := F.NsInode(p, specs.PIDNamespace); err == nil && i == inode {
+			pids = append(pids, p)
+		}
+	}
+	return pids, nil
+}
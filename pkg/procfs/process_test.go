@@ -0,0 +1,199 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, fs *FS, name, content string) {
+	err := os.WriteFile(filepath.Join(fs.procfsPath, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFS_Stat(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	content := "42 (sleep (1)) S 1 42 42 34816 42 4194304 101 0 0 0 7 3 0 0 20 0 1 0 12345 2289664 128 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n"
+	writeTestFile(t, fs, filepath.Join(testPidStr, "stat"), content)
+
+	stat, err := fs.Stat(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ProcStat{
+		Pid:        42,
+		Comm:       "sleep (1)",
+		State:      "S",
+		PPid:       1,
+		Pgrp:       42,
+		Session:    42,
+		TtyNr:      34816,
+		UTime:      7,
+		STime:      3,
+		NumThreads: 1,
+		StartTime:  12345,
+		VSize:      2289664,
+		Rss:        128,
+	}
+	if stat != expected {
+		t.Errorf("expected: %+v, actual: %+v", expected, stat)
+	}
+}
+
+func TestFS_Stat_Invalid(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	writeTestFile(t, fs, filepath.Join(testPidStr, "stat"), "42 (sleep) S 1\n")
+
+	if _, err := fs.Stat(testPid); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestFS_Status(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	content := "Name:\tsleep\nState:\tS (sleeping)\nPid:\t42\nPPid:\t1\n" +
+		"Uid:\t1000\t1000\t1000\t1000\nGid:\t100\t100\t100\t100\n" +
+		"VmRSS:\t     512 kB\nThreads:\t1\nNSpid:\t42\t3\n"
+	writeTestFile(t, fs, filepath.Join(testPidStr, "status"), content)
+
+	status, err := fs.Status(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ProcStatus{
+		Name:    "sleep",
+		State:   "S (sleeping)",
+		Pid:     42,
+		PPid:    1,
+		Uids:    [4]uint32{1000, 1000, 1000, 1000},
+		Gids:    [4]uint32{100, 100, 100, 100},
+		VmRSS:   512 * 1024,
+		Threads: 1,
+		NSpid:   []int{42, 3},
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("expected: %+v, actual: %+v", expected, status)
+	}
+}
+
+func TestFS_Cmdline(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	writeTestFile(t, fs, filepath.Join(testPidStr, "cmdline"), "sleep\x00infinity\x00")
+
+	args, err := fs.Cmdline(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"sleep", "infinity"}) {
+		t.Errorf("unexpected cmdline: %q", args)
+	}
+}
+
+func TestFS_Pids(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	writeTestFile(t, fs, "stat", "")
+	if err := os.Mkdir(filepath.Join(fs.procfsPath, "sys"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pids, err := fs.Pids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pids, []Pid{testPid}) {
+		t.Errorf("expected: [%d], actual: %v", testPid, pids)
+	}
+}
+
+func TestFS_BootTime(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	writeTestFile(t, fs, "stat", "cpu  1 2 3 4\nbtime 1700000000\nprocesses 100\n")
+
+	bootTime, err := fs.BootTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bootTime.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("unexpected boot time: %v", bootTime)
+	}
+}
+
+func TestFS_CapLastCap(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	if err := os.MkdirAll(filepath.Join(fs.procfsPath, "sys", "kernel"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, fs, filepath.Join("sys", "kernel", "cap_last_cap"), "40\n")
+
+	last, err := fs.CapLastCap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 40 {
+		t.Errorf("cap_last_cap, expected: 40, actual: %d", last)
+	}
+}
+
+func TestFS_PidsInNamespace(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	// processes in the same namespace share the inode of the ns file, hard links emulate this
+	for _, pid := range []string{testPidStr, "43", "44"} {
+		if err := os.MkdirAll(filepath.Join(fs.procfsPath, pid, "ns"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFile(t, fs, filepath.Join(testPidStr, "ns", "pid"), "")
+	writeTestFile(t, fs, filepath.Join("44", "ns", "pid"), "")
+	err := os.Link(filepath.Join(fs.procfsPath, testPidStr, "ns", "pid"), filepath.Join(fs.procfsPath, "43", "ns", "pid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pids, err := fs.PidsInNamespace(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pids, []Pid{testPid, 43}) {
+		t.Errorf("expected: [%d 43], actual: %v", testPid, pids)
+	}
+}
+
+func TestFS_IO(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	content := "rchar: 100\nwchar: 200\nsyscr: 3\nsyscw: 4\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n"
+	writeTestFile(t, fs, filepath.Join(testPidStr, "io"), content)
+
+	io, err := fs.IO(testPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ProcIO{ReadChars: 100, WriteChars: 200, ReadCalls: 3, WriteCalls: 4, ReadBytes: 4096, WriteBytes: 8192}
+	if io != expected {
+		t.Errorf("expected: %+v, actual: %+v", expected, io)
+	}
+}
@@ -2,6 +2,8 @@ package procfs
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"syscall"
 )
 
@@ -19,6 +21,17 @@ type FS struct {
 	procfsPath string
 }
 
+// RootPath returns the path of the root directory of the specified process ID (pid).
+func (F *FS) RootPath(pid Pid) string {
+	return filepath.Join(F.procfsPath, pid.String(), "root")
+}
+
+// FdPath returns the path of the file descriptor fd of the specified process ID (pid).
+// Opening or mounting onto the path acts on the file the descriptor refers to, not on the path it was opened with.
+func (F *FS) FdPath(pid Pid, fd uintptr) string {
+	return filepath.Join(F.procfsPath, pid.String(), "fd", strconv.FormatUint(uint64(fd), 10))
+}
+
 // IsProcessRunning checks if a process with the given PID is running.
 // Pid 0 is always false
 func IsProcessRunning(pid int) bool {
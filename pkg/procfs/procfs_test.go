@@ -0,0 +1,24 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFS_FdPath(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	link, err := os.Readlink(Root.FdPath(PidSelf, f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != name {
+		t.Errorf("link, expected: %v, actual: %v", name, link)
+	}
+}
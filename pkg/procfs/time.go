@@ -0,0 +1,39 @@
+package procfs
+
+import (
+	"fmt"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// timensOffsetsFileName is the name of the file with the clock offsets of the time namespace for the children
+// of a process, see time_namespaces(7).
+const timensOffsetsFileName = "timens_offsets"
+
+// timeNamespaceClocks are the clocks with an offset in a time namespace
+var timeNamespaceClocks = []string{"monotonic", "boottime"}
+
+// SetTimeOffsets writes the clock offsets of the time namespace that the children of the process ID (pid) enter.
+// The offsets can only be written before the first process entered the namespace.
+// All offsets are written at once, because the kernel only accepts a single write.
+func (F *FS) SetTimeOffsets(pid Pid, offsets map[string]specs.LinuxTimeOffset) error {
+	clocks := make([]string, 0, len(offsets))
+	for clock := range offsets {
+		if !slices.Contains(timeNamespaceClocks, clock) {
+			return fmt.Errorf("time offset of unsupported clock %v", clock)
+		}
+		clocks = append(clocks, clock)
+	}
+	slices.Sort(clocks)
+
+	var b strings.Builder
+	for _, clock := range clocks {
+		fmt.Fprintf(&b, "%v %d %d\n", clock, offsets[clock].Secs, offsets[clock].Nanosecs)
+	}
+
+	path := filepath.Join(F.procfsPath, pid.String(), timensOffsetsFileName)
+	return os.WriteFile(path, []byte(b.String()), mapFilePermissions)
+}
@@ -0,0 +1,40 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestFS_SetTimeOffsets(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	err := fs.SetTimeOffsets(testPid, map[string]specs.LinuxTimeOffset{
+		"monotonic": {Secs: 86400},
+		"boottime":  {Secs: -10, Nanosecs: 500},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offsets, err := os.ReadFile(filepath.Join(fs.procfsPath, testPidStr, "timens_offsets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "boottime -10 500\nmonotonic 86400 0\n"
+	if string(offsets) != expected {
+		t.Errorf("offsets, expected: %q, actual: %q", expected, string(offsets))
+	}
+}
+
+func TestFS_SetTimeOffsetsUnsupportedClock(t *testing.T) {
+	fs, deleteFs := newTestFs()
+	defer deleteFs()
+
+	if err := fs.SetTimeOffsets(testPid, map[string]specs.LinuxTimeOffset{"realtime": {Secs: 1}}); err == nil {
+		t.Error("expected error for realtime clock")
+	}
+}
@@ -35,7 +35,7 @@ func ReadJsonFile(path string, v any) error {
 func WriteJsonFile(path string, v any) error {
 	zap.L().Debug("writing json file", zap.String("path", path))
 	defer zap.L().Debug("done writing json file", zap.String("path", path))
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o711)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o711)
 	if err != nil {
 		return err
 	}